@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/textileio/textile-go/core"
+	"github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet"
+	"gopkg.in/abiosoft/ishell.v2"
+	"strconv"
+)
+
+func DefaultLocationPolicy(c *ishell.Context) {
+	if len(c.Args) > 0 {
+		policy, grid, err := parseLocationPolicy(c.Args)
+		if err != nil {
+			c.Err(err)
+			return
+		}
+		if err := core.Node.Wallet.SetLocationPolicy(policy, grid); err != nil {
+			c.Err(err)
+			return
+		}
+	}
+
+	policy, grid, err := core.Node.Wallet.LocationPolicy()
+	if err != nil {
+		c.Err(err)
+		return
+	}
+	blue := color.New(color.FgHiBlue).SprintFunc()
+	c.Println(blue(fmt.Sprintf("default location policy: %s", formatLocationPolicy(policy, grid))))
+}
+
+func ThreadLocationPolicy(c *ishell.Context) {
+	if len(c.Args) == 0 {
+		c.Err(errors.New("missing thread name"))
+		return
+	}
+	name := c.Args[0]
+
+	thrd := core.Node.Wallet.GetThreadByName(name)
+	if thrd == nil {
+		c.Err(errors.New(fmt.Sprintf("could not find thread: %s", name)))
+		return
+	}
+
+	if len(c.Args) > 1 {
+		if c.Args[1] == "default" {
+			if err := core.Node.Wallet.ResetThreadLocationPolicy(thrd.Id); err != nil {
+				c.Err(err)
+				return
+			}
+		} else {
+			policy, grid, err := parseLocationPolicy(c.Args[1:])
+			if err != nil {
+				c.Err(err)
+				return
+			}
+			if err := core.Node.Wallet.SetThreadLocationPolicy(thrd.Id, policy, grid); err != nil {
+				c.Err(err)
+				return
+			}
+		}
+	}
+
+	policy, grid, err := core.Node.Wallet.ThreadLocationPolicy(thrd.Id)
+	if err != nil {
+		c.Err(err)
+		return
+	}
+	blue := color.New(color.FgHiBlue).SprintFunc()
+	c.Println(blue(fmt.Sprintf("location policy for %s: %s", thrd.Name, formatLocationPolicy(policy, grid))))
+}
+
+// parseLocationPolicy reads a policy name and an optional grid size in degrees
+func parseLocationPolicy(args []string) (repo.LocationPolicy, float64, error) {
+	policy, err := wallet.ParseLocationPolicy(args[0])
+	if err != nil {
+		return policy, 0, err
+	}
+	var grid float64
+	if len(args) > 1 {
+		grid, err = strconv.ParseFloat(args[1], 64)
+		if err != nil {
+			return policy, 0, err
+		}
+	}
+	return policy, grid, nil
+}
+
+func formatLocationPolicy(policy repo.LocationPolicy, grid float64) string {
+	if policy == repo.LocationCoarsen {
+		return fmt.Sprintf("%s to %g degrees", policy.String(), grid)
+	}
+	return policy.String()
+}
@@ -8,12 +8,16 @@ import (
 	"github.com/mitchellh/go-homedir"
 	"github.com/textileio/textile-go/core"
 	"github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet"
 	"github.com/textileio/textile-go/wallet/model"
 	"github.com/textileio/textile-go/wallet/thread"
+	"github.com/textileio/textile-go/wallet/util"
 	"gopkg.in/abiosoft/ishell.v2"
-	"io/ioutil"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 func AddPhoto(c *ishell.Context) {
@@ -47,10 +51,12 @@ func AddPhoto(c *ishell.Context) {
 		return
 	}
 
-	// clean up
-	if err = os.Remove(added.RemoteRequest.PayloadPath); err != nil {
-		c.Err(err)
-		return
+	// pin to remote
+	if !added.Duplicate {
+		if err := core.Node.Wallet.QueueUpload(added.RemoteRequest); err != nil {
+			c.Err(err)
+			return
+		}
 	}
 
 	// parse thread
@@ -65,17 +71,84 @@ func AddPhoto(c *ishell.Context) {
 		c.Err(errors.New(fmt.Sprintf("could not find thread %s", threadName)))
 		return
 	}
-	tadded, err := thrd.AddPhoto(added.Id, caption, added.Key)
+	tadded, err := core.Node.Wallet.AddPhotoToThread(added.Id, added.Key, thrd, caption)
 	if err != nil {
 		c.Err(err)
 		return
 	}
+	if tadded.Duplicate {
+		c.Println("photo " + added.Id + " is already in thread " + thrd.Name + " with block " + tadded.Id)
+		return
+	}
+	if err := core.Node.Wallet.QueueUpload(tadded.RemoteRequest); err != nil {
+		c.Err(err)
+		return
+	}
 
 	// show user root id
 	cyan := color.New(color.FgCyan).SprintFunc()
+	if added.Duplicate {
+		c.Println(cyan("reused existing photo " + added.Id))
+	}
 	c.Println(cyan("added " + added.Id + " to thread " + thrd.Name + " with block " + tadded.Id))
 }
 
+func ImportPhotos(c *ishell.Context) {
+	if len(c.Args) == 0 {
+		c.Err(errors.New("missing directory path"))
+		return
+	}
+
+	// try to get path with home dir tilda
+	path, err := homedir.Expand(c.Args[0])
+	if err != nil {
+		path = c.Args[0]
+	}
+
+	// parse thread
+	threadName := "default"
+	if len(c.Args) > 1 {
+		threadName = c.Args[1]
+	}
+	thrd := core.Node.Wallet.GetThreadByName(threadName)
+	if thrd == nil {
+		c.Err(errors.New(fmt.Sprintf("could not find thread %s", threadName)))
+		return
+	}
+
+	// show progress while importing
+	progress := make(chan model.ImportUpdate)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		bar := c.ProgressBar()
+		bar.Start()
+		defer bar.Stop()
+		for update := range progress {
+			bar.Progress(update.Done * 100 / update.Total)
+			bar.Suffix(fmt.Sprintf(" %s %d/%d", update.Phase, update.Done, update.Total))
+		}
+	}()
+	result, err := core.Node.Wallet.ImportDirectory(path, thrd, wallet.ImportOptions{Progress: progress})
+	close(progress)
+	<-finished
+	if err != nil {
+		c.Err(err)
+		return
+	}
+
+	cyan := color.New(color.FgCyan).SprintFunc()
+	c.Println(cyan(fmt.Sprintf("imported %d of %d photos to thread %s, skipped %d duplicates",
+		result.Added, result.Total, thrd.Name, result.Duplicates)))
+	if len(result.Failed) > 0 {
+		red := color.New(color.FgRed).SprintFunc()
+		c.Println(red(fmt.Sprintf("%d failed:", len(result.Failed))))
+		for _, failed := range result.Failed {
+			c.Println(red(failed))
+		}
+	}
+}
+
 func SharePhoto(c *ishell.Context) {
 	if len(c.Args) == 0 {
 		c.Err(errors.New("missing photo id"))
@@ -91,13 +164,6 @@ func SharePhoto(c *ishell.Context) {
 	c.Print("caption (optional): ")
 	caption := c.ReadLine()
 
-	// get the original block
-	block, fromThread, err := getBlockAndThreadForTarget(id)
-	if err != nil {
-		c.Err(err)
-		return
-	}
-
 	// lookup destination thread
 	toThread := core.Node.Wallet.GetThreadByName(threadName)
 	if toThread == nil {
@@ -105,24 +171,116 @@ func SharePhoto(c *ishell.Context) {
 		return
 	}
 
-	// get the file key from the original block
-	key, err := fromThread.Decrypt(block.TargetKey)
+	// finally, add to destination
+	shared, err := core.Node.Wallet.SharePhoto(id, toThread, caption)
 	if err != nil {
 		c.Err(err)
 		return
 	}
+	if shared.Duplicate {
+		c.Println("photo " + id + " is already in thread " + toThread.Name + " with block " + shared.Id)
+		return
+	}
+	if err := core.Node.Wallet.QueueUpload(shared.RemoteRequest); err != nil {
+		c.Err(err)
+		return
+	}
 
-	// TODO: owner challenge
+	green := color.New(color.FgHiGreen).SprintFunc()
+	c.Println(green("shared " + id + " to thread " + toThread.Name + " (new id: " + shared.Id + ")"))
+}
 
-	// finally, add to destination
-	shared, err := toThread.AddPhoto(id, caption, key)
+func EditPhoto(c *ishell.Context) {
+	if len(c.Args) == 0 {
+		c.Err(errors.New("missing photo id"))
+		return
+	}
+	if len(c.Args) == 1 {
+		c.Err(errors.New("missing thread name"))
+		return
+	}
+	if len(c.Args) == 2 {
+		c.Err(errors.New("missing edits (rotate:<90|180|270>, crop:<x>,<y>,<width>,<height>, or flip:<h|v>)"))
+		return
+	}
+	id := c.Args[0]
+	threadName := c.Args[1]
+
+	var edits []model.Edit
+	for _, arg := range c.Args[2:] {
+		edit, err := util.ParseEdit(arg)
+		if err != nil {
+			c.Err(err)
+			return
+		}
+		edits = append(edits, edit)
+	}
+
+	thrd := core.Node.Wallet.GetThreadByName(threadName)
+	if thrd == nil {
+		c.Err(errors.New(fmt.Sprintf("could not find thread %s", threadName)))
+		return
+	}
+	edited, err := core.Node.Wallet.EditPhoto(id, thrd, edits)
 	if err != nil {
 		c.Err(err)
 		return
 	}
+	if edited.Duplicate {
+		c.Println("this version is already in thread " + thrd.Name + " with block " + edited.Id)
+		return
+	}
+	if err := core.Node.Wallet.QueueUpload(edited.RemoteRequest); err != nil {
+		c.Err(err)
+		return
+	}
 
 	green := color.New(color.FgHiGreen).SprintFunc()
-	c.Println(green("shared " + id + " to thread " + toThread.Name + " (new id: " + shared.Id + ")"))
+	c.Println(green("edited " + id + " in thread " + thrd.Name + " with block " + edited.Id))
+}
+
+func ListPhotoVersions(c *ishell.Context) {
+	if len(c.Args) == 0 {
+		c.Err(errors.New("missing photo id"))
+		return
+	}
+	id := c.Args[0]
+	threadName := "default"
+	if len(c.Args) > 1 {
+		threadName = c.Args[1]
+	}
+
+	thrd := core.Node.Wallet.GetThreadByName(threadName)
+	if thrd == nil {
+		c.Err(errors.New(fmt.Sprintf("could not find thread: %s", threadName)))
+		return
+	}
+
+	versions := thrd.Versions(id)
+	if len(versions) == 0 {
+		c.Println(fmt.Sprintf("no versions of %s found in: %s", id, threadName))
+		return
+	}
+	c.Println(fmt.Sprintf("found %v versions of %s in: %s", len(versions), id, threadName))
+
+	magenta := color.New(color.FgHiMagenta).SprintFunc()
+	for _, block := range versions {
+		editsb, err := thrd.GetVersionEdits(&block)
+		if err != nil {
+			c.Err(err)
+			return
+		}
+		var edits []model.Edit
+		if err := json.Unmarshal(editsb, &edits); err != nil {
+			c.Err(err)
+			return
+		}
+		var ops []string
+		for _, edit := range edits {
+			ops = append(ops, edit.String())
+		}
+		c.Println(magenta(fmt.Sprintf("id: %s, block: %s, edits: %s", block.Target, block.Id, strings.Join(ops, " "))))
+	}
 }
 
 func ListPhotos(c *ishell.Context) {
@@ -150,6 +308,69 @@ func ListPhotos(c *ishell.Context) {
 	}
 }
 
+func ListSimilarPhotos(c *ishell.Context) {
+	if len(c.Args) == 0 {
+		c.Err(errors.New("missing photo id"))
+		return
+	}
+	id := c.Args[0]
+	threshold, err := parseSimilarThreshold(c.Args[1:])
+	if err != nil {
+		c.Err(err)
+		return
+	}
+
+	similar, err := core.Node.Wallet.SimilarPhotos(id, threshold)
+	if err != nil {
+		c.Err(err)
+		return
+	}
+	if len(similar) == 0 {
+		c.Println(fmt.Sprintf("no photos similar to: %s", id))
+		return
+	}
+	c.Println(fmt.Sprintf("found %v photos similar to: %s", len(similar), id))
+
+	magenta := color.New(color.FgHiMagenta).SprintFunc()
+	for _, photo := range similar {
+		c.Println(magenta(fmt.Sprintf("id: %s, distance: %d", photo.Id, photo.Distance)))
+	}
+}
+
+func ListSimilarClusters(c *ishell.Context) {
+	threadName := "default"
+	if len(c.Args) > 0 {
+		threadName = c.Args[0]
+	}
+	threshold, err := parseSimilarThreshold(c.Args[1:])
+	if err != nil {
+		c.Err(err)
+		return
+	}
+
+	thrd := core.Node.Wallet.GetThreadByName(threadName)
+	if thrd == nil {
+		c.Err(errors.New(fmt.Sprintf("could not find thread: %s", threadName)))
+		return
+	}
+
+	clusters, err := core.Node.Wallet.SimilarPhotoClusters(thrd.Id, threshold)
+	if err != nil {
+		c.Err(err)
+		return
+	}
+	if len(clusters) == 0 {
+		c.Println(fmt.Sprintf("no similar photos found in: %s", threadName))
+		return
+	}
+	c.Println(fmt.Sprintf("found %v groups of similar photos in: %s", len(clusters), threadName))
+
+	magenta := color.New(color.FgHiMagenta).SprintFunc()
+	for i, cluster := range clusters {
+		c.Println(magenta(fmt.Sprintf("group %d: %s", i+1, strings.Join(cluster.Photos, ", "))))
+	}
+}
+
 func GetPhoto(c *ishell.Context) {
 	if len(c.Args) == 0 {
 		c.Err(errors.New("missing photo id"))
@@ -173,14 +394,21 @@ func GetPhoto(c *ishell.Context) {
 		return
 	}
 
-	file, err := thrd.GetFileData(fmt.Sprintf("%s/photo", id), block)
+	reader, err := thrd.GetFileReader(fmt.Sprintf("%s/photo", id), block)
 	if err != nil {
 		c.Err(err)
 		return
 	}
+	defer reader.Close()
 
 	path := filepath.Join(dest, id)
-	if err := ioutil.WriteFile(path, file, 0644); err != nil {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		c.Err(err)
+		return
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, reader); err != nil {
 		c.Err(err)
 		return
 	}
@@ -256,3 +484,11 @@ func getBlockAndThreadForTarget(id string) (*repo.Block, *thread.Thread, error)
 	}
 	return block, thrd, nil
 }
+
+// parseSimilarThreshold reads an optional hash distance, falling back to the default
+func parseSimilarThreshold(args []string) (int, error) {
+	if len(args) == 0 {
+		return wallet.DefaultSimilarThreshold, nil
+	}
+	return strconv.Atoi(args[0])
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/textileio/textile-go/core"
+	"gopkg.in/abiosoft/ishell.v2"
+	"time"
+)
+
+func EncryptRepo(c *ishell.Context) {
+	if core.Node.Wallet.Started() {
+		c.Err(errors.New("stop the node before encrypting"))
+		return
+	}
+	password, err := readNewPassword(c)
+	if err != nil {
+		c.Err(err)
+		return
+	}
+	if err := core.Node.Wallet.EncryptDatastore(password); err != nil {
+		c.Err(err)
+		return
+	}
+
+	green := color.New(color.FgHiGreen).SprintFunc()
+	c.Println(green("ok, datastore encrypted"))
+}
+
+func UnlockRepo(c *ishell.Context) {
+	if !core.Node.Wallet.Locked() {
+		c.Println("already unlocked")
+		return
+	}
+	c.Print("password: ")
+	password := c.ReadPassword()
+	if err := core.Node.Wallet.Unlock(password); err != nil {
+		c.Err(err)
+		return
+	}
+
+	green := color.New(color.FgHiGreen).SprintFunc()
+	c.Println(green("ok, datastore unlocked"))
+}
+
+func LockRepo(c *ishell.Context) {
+	if core.Node.Wallet.Started() {
+		c.Err(errors.New("stop the node before locking"))
+		return
+	}
+	if err := core.Node.Wallet.Lock(); err != nil {
+		c.Err(err)
+		return
+	}
+
+	green := color.New(color.FgHiGreen).SprintFunc()
+	c.Println(green("ok, datastore locked"))
+}
+
+func ChangeRepoPassword(c *ishell.Context) {
+	c.Print("current password: ")
+	current := c.ReadPassword()
+	password, err := readNewPassword(c)
+	if err != nil {
+		c.Err(err)
+		return
+	}
+	if err := core.Node.Wallet.ChangeDatastorePassword(current, password); err != nil {
+		c.Err(err)
+		return
+	}
+
+	green := color.New(color.FgHiGreen).SprintFunc()
+	c.Println(green("ok, datastore password changed"))
+}
+
+func CollectGarbage(c *ishell.Context) {
+	// optionally (re)schedule instead
+	if len(c.Args) > 0 {
+		if c.Args[0] == "off" {
+			core.Node.ScheduleGC(0)
+			c.Println("ok, scheduled gc disabled")
+			return
+		}
+		interval, err := time.ParseDuration(c.Args[0])
+		if err != nil {
+			c.Err(err)
+			return
+		}
+		core.Node.ScheduleGC(interval)
+		c.Printf("ok, gc scheduled every %s\n", interval.String())
+		return
+	}
+
+	res, err := core.Node.Wallet.GC()
+	if err != nil {
+		c.Err(err)
+		return
+	}
+
+	blue := color.New(color.FgHiBlue).SprintFunc()
+	c.Println(blue(fmt.Sprintf("unpinned %d, removed %d blocks, freed %d bytes", res.Unpinned, res.Removed, res.Freed)))
+}
+
+func readNewPassword(c *ishell.Context) (string, error) {
+	c.Print("new password: ")
+	password := c.ReadPassword()
+	if password == "" {
+		return "", errors.New("password must not be empty")
+	}
+	c.Print("confirm password: ")
+	if c.ReadPassword() != password {
+		return "", errors.New("passwords do not match")
+	}
+	return password, nil
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/textileio/textile-go/core"
+	"github.com/textileio/textile-go/repo"
+	"gopkg.in/abiosoft/ishell.v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchDateLayout is used by the from: and to: search filters
+const searchDateLayout = "2006-01-02"
+
+func Search(c *ishell.Context) {
+	if len(c.Args) == 0 {
+		c.Err(errors.New("missing search text or filters"))
+		return
+	}
+	query, err := parseSearchQuery(c.Args)
+	if err != nil {
+		c.Err(err)
+		return
+	}
+
+	entries, err := core.Node.Wallet.Search(query)
+	if err != nil {
+		c.Err(err)
+		return
+	}
+	if len(entries) == 0 {
+		c.Println("no photos found")
+		return
+	}
+	c.Println(fmt.Sprintf("found %v photos", len(entries)))
+
+	blue := color.New(color.FgHiBlue).SprintFunc()
+	for _, entry := range entries {
+		var name string
+		if thrd := core.Node.Wallet.GetThread(entry.ThreadId); thrd != nil {
+			name = thrd.Name
+		}
+		c.Println(blue(fmt.Sprintf("id: %s, block: %s, thread: %s, date: %s, caption: %s",
+			entry.Target, entry.BlockId, name, entry.Date.Format(time.RFC3339), entry.Caption)))
+	}
+}
+
+func RebuildSearchIndex(c *ishell.Context) {
+	count, err := core.Node.Wallet.RebuildSearchIndex()
+	if err != nil {
+		c.Err(err)
+		return
+	}
+	green := color.New(color.FgHiGreen).SprintFunc()
+	c.Println(green(fmt.Sprintf("indexed %d photos", count)))
+}
+
+// parseSearchQuery reads free text mixed with thread:, by:, from:, to:, and limit: filters
+func parseSearchQuery(args []string) (*repo.SearchQuery, error) {
+	query := &repo.SearchQuery{}
+	var text []string
+	for _, arg := range args {
+		parts := strings.SplitN(arg, ":", 2)
+		if len(parts) != 2 {
+			text = append(text, arg)
+			continue
+		}
+		var err error
+		switch parts[0] {
+		case "thread":
+			thrd := core.Node.Wallet.GetThreadByName(parts[1])
+			if thrd == nil {
+				return nil, errors.New(fmt.Sprintf("could not find thread: %s", parts[1]))
+			}
+			query.ThreadId = thrd.Id
+		case "by":
+			query.Username = parts[1]
+		case "from":
+			query.From, err = time.ParseInLocation(searchDateLayout, parts[1], time.Local)
+		case "to":
+			query.To, err = time.ParseInLocation(searchDateLayout, parts[1], time.Local)
+			query.To = query.To.AddDate(0, 0, 1) // inclusive
+		case "limit":
+			query.Limit, err = strconv.Atoi(parts[1])
+		default:
+			text = append(text, arg)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	query.Text = strings.Join(text, " ")
+	return query, nil
+}
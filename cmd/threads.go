@@ -7,6 +7,7 @@ import (
 	"github.com/textileio/textile-go/core"
 	"github.com/textileio/textile-go/wallet/thread"
 	"gopkg.in/abiosoft/ishell.v2"
+	"strconv"
 )
 
 func ListThreads(c *ishell.Context) {
@@ -34,14 +35,32 @@ func CreateThread(c *ishell.Context) {
 	}
 	name := c.Args[0]
 
-	c.Print("key pair mnemonic phrase (optional): ")
+	c.Print("key pair mnemonic phrase (optional, derived from master key if empty): ")
 	mnemonics := c.ReadLine()
-	var mnemonic *string
-	if mnemonics != "" {
-		mnemonic = &mnemonics
+
+	cyan := color.New(color.FgCyan).SprintFunc()
+	if mnemonics == "" {
+		thrd, index, mnem, err := core.Node.Wallet.AddNewThread(name)
+		if err != nil {
+			c.Err(err)
+			return
+		}
+
+		Subscribe(c, thrd)
+
+		c.Println(cyan(fmt.Sprintf("created thread #%s", name)))
+		if mnem != "" {
+			c.Println(cyan(fmt.Sprintf("mnemonic phrase: %s", mnem)))
+		} else {
+			c.Println(cyan(fmt.Sprintf("key derived from master key at index: %d", index)))
+		}
+		return
 	}
 
-	thrd, mnem, err := core.Node.Wallet.AddThreadWithMnemonic(name, mnemonic)
+	c.Print("mnemonic passphrase (optional): ")
+	passphrase := c.ReadPassword()
+
+	thrd, mnem, err := core.Node.Wallet.AddThreadWithMnemonic(name, &mnemonics, passphrase)
 	if err != nil {
 		c.Err(err)
 		return
@@ -49,11 +68,48 @@ func CreateThread(c *ishell.Context) {
 
 	Subscribe(c, thrd)
 
-	cyan := color.New(color.FgCyan).SprintFunc()
 	c.Println(cyan(fmt.Sprintf("created thread #%s", name)))
 	c.Println(cyan(fmt.Sprintf("mnemonic phrase: %s", mnem)))
 }
 
+func RestoreThread(c *ishell.Context) {
+	cyan := color.New(color.FgCyan).SprintFunc()
+	if len(c.Args) == 0 {
+		// without a name, bring back everything in the published thread index
+		thrds, err := core.Node.Wallet.RestoreThreads()
+		if err != nil {
+			c.Err(err)
+			return
+		}
+		for _, thrd := range thrds {
+			Subscribe(c, thrd)
+			c.Println(cyan(fmt.Sprintf("restored thread #%s, id: %s", thrd.Name, thrd.Id)))
+		}
+		c.Println(cyan(fmt.Sprintf("restored %d threads", len(thrds))))
+		return
+	}
+	if len(c.Args) == 1 {
+		c.Err(errors.New("missing thread index"))
+		return
+	}
+	name := c.Args[0]
+	index, err := strconv.Atoi(c.Args[1])
+	if err != nil {
+		c.Err(err)
+		return
+	}
+
+	thrd, err := core.Node.Wallet.RestoreThread(name, index)
+	if err != nil {
+		c.Err(err)
+		return
+	}
+
+	Subscribe(c, thrd)
+
+	c.Println(cyan(fmt.Sprintf("restored thread #%s, id: %s", name, thrd.Id)))
+}
+
 func EnableThread(c *ishell.Context) {
 	if len(c.Args) == 0 {
 		c.Err(errors.New("missing thread name"))
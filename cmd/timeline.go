@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/textileio/textile-go/core"
+	"github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet"
+	"gopkg.in/abiosoft/ishell.v2"
+	"strings"
+)
+
+func Timeline(c *ishell.Context) {
+	period := repo.TimelineMonth
+	var cursor string
+	var threads []string
+	for _, arg := range c.Args {
+		if strings.HasPrefix(arg, "thread:") {
+			name := strings.TrimPrefix(arg, "thread:")
+			thrd := core.Node.Wallet.GetThreadByName(name)
+			if thrd == nil {
+				c.Err(errors.New(fmt.Sprintf("could not find thread: %s", name)))
+				return
+			}
+			threads = append(threads, thrd.Id)
+			continue
+		}
+		if p, err := wallet.ParseTimelinePeriod(arg); err == nil {
+			period = p
+			continue
+		}
+		cursor = arg
+	}
+
+	page, err := core.Node.Wallet.Timeline(period, cursor, 0, 0, threads)
+	if err != nil {
+		c.Err(err)
+		return
+	}
+	if len(page.Groups) == 0 {
+		c.Println("no photos found")
+		return
+	}
+
+	blue := color.New(color.FgHiBlue).SprintFunc()
+	for _, group := range page.Groups {
+		var targets []string
+		for _, photo := range group.Photos {
+			targets = append(targets, photo.Target)
+		}
+		c.Println(blue(fmt.Sprintf("%s: %d photos, latest: %s", group.Key, group.Count, strings.Join(targets, ", "))))
+	}
+	if page.Next != "" {
+		green := color.New(color.FgHiGreen).SprintFunc()
+		c.Println(green(fmt.Sprintf("more with: timeline %s %s", period.String(), page.Next)))
+	}
+}
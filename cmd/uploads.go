@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fatih/color"
+	"github.com/textileio/textile-go/core"
+	"github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet/model"
+	"gopkg.in/abiosoft/ishell.v2"
+)
+
+func ListUploads(c *ishell.Context) {
+	uploads, err := core.Node.Wallet.Uploads()
+	if err != nil {
+		c.Err(err)
+		return
+	}
+	if len(uploads) == 0 {
+		c.Println("no queued uploads")
+		return
+	}
+
+	yellow := color.New(color.FgYellow).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+	for _, upload := range uploads {
+		line := fmt.Sprintf("%s: %s, %d attempts, added %s", upload.Id, upload.Status.String(),
+			upload.Attempts, upload.Date.Format("2006-01-02 15:04:05"))
+		if upload.Status == repo.UploadFailed {
+			c.Println(red(line))
+		} else {
+			c.Println(yellow(line))
+		}
+	}
+}
+
+func FlushUploads(c *ishell.Context) {
+	if err := core.Node.Wallet.FlushUploads(); err != nil {
+		c.Err(err)
+		return
+	}
+
+	green := color.New(color.FgHiGreen).SprintFunc()
+	c.Println(green("ok, flushed uploads"))
+}
+
+func RetryUpload(c *ishell.Context) {
+	if len(c.Args) == 0 {
+		c.Err(errors.New("missing upload id"))
+		return
+	}
+	if err := core.Node.Wallet.RetryUpload(c.Args[0]); err != nil {
+		c.Err(err)
+		return
+	}
+
+	green := color.New(color.FgHiGreen).SprintFunc()
+	c.Println(green("ok, upload queued"))
+}
+
+func SubscribeUploads(shell ishell.Actions) {
+	cyan := color.New(color.FgCyan).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+	datac := make(chan model.UploadUpdate, 10)
+	core.Node.Wallet.SubscribeUploads(datac)
+	go func() {
+		for update := range datac {
+			var msg string
+			switch update.Status {
+			case repo.UploadDone.String():
+				msg = cyan(fmt.Sprintf("\nupload %s complete\n", update.Id))
+			case repo.UploadFailed.String():
+				msg = red(fmt.Sprintf("\nupload %s failed: %s\n", update.Id, update.Error))
+			default:
+				if update.Error == "" {
+					continue
+				}
+				msg = fmt.Sprintf("\nupload %s attempt %d failed, will retry\n", update.Id, update.Attempts)
+			}
+			shell.ShowPrompt(false)
+			shell.Printf(msg)
+			shell.ShowPrompt(true)
+		}
+	}()
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/fatih/color"
+	"github.com/mitchellh/go-homedir"
+	"github.com/textileio/textile-go/core"
+	"gopkg.in/abiosoft/ishell.v2"
+)
+
+func AddWatch(c *ishell.Context) {
+	if len(c.Args) == 0 {
+		c.Err(errors.New("missing folder path"))
+		return
+	}
+
+	// try to get path with home dir tilda
+	path, err := homedir.Expand(c.Args[0])
+	if err != nil {
+		path = c.Args[0]
+	}
+
+	// parse thread
+	threadName := "default"
+	if len(c.Args) > 1 {
+		threadName = c.Args[1]
+	}
+
+	abs, err := core.Node.AddWatch(path, threadName)
+	if err != nil {
+		c.Err(err)
+		return
+	}
+
+	green := color.New(color.FgHiGreen).SprintFunc()
+	c.Println(green("ok, new photos in " + abs + " will be added to thread " + threadName))
+}
+
+func ListWatches(c *ishell.Context) {
+	watches, err := core.Node.Watches()
+	if err != nil {
+		c.Err(err)
+		return
+	}
+	if len(watches) == 0 {
+		c.Println("no watched folders")
+		return
+	}
+
+	magenta := color.New(color.FgHiMagenta).SprintFunc()
+	for _, watch := range watches {
+		name := watch.ThreadId
+		if thrd := core.Node.Wallet.GetThread(watch.ThreadId); thrd != nil {
+			name = thrd.Name
+		}
+		c.Println(magenta(watch.Path + " -> " + name))
+	}
+}
+
+func RemoveWatch(c *ishell.Context) {
+	if len(c.Args) == 0 {
+		c.Err(errors.New("missing folder path"))
+		return
+	}
+
+	// try to get path with home dir tilda
+	path, err := homedir.Expand(c.Args[0])
+	if err != nil {
+		path = c.Args[0]
+	}
+
+	if err := core.Node.RemoveWatch(path); err != nil {
+		c.Err(err)
+		return
+	}
+
+	green := color.New(color.FgHiGreen).SprintFunc()
+	c.Println(green("ok, stopped watching " + path))
+}
@@ -0,0 +1,567 @@
+package core
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet"
+	"github.com/textileio/textile-go/wallet/model"
+	"github.com/textileio/textile-go/wallet/thread"
+	"gx/ipfs/QmcZfnkapfECQGcLZaf9B79NRg7cRa9EnZh4LSbkCzwNvY/go-cid"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// apiPrefix is the path all rest api routes live under
+const apiPrefix = "/api/v0"
+
+// ApiTokenFile is the file in the repo holding the rest api token
+const ApiTokenFile = "api_token"
+
+// apiMaxMemory is how much of a multipart upload is held in memory before spilling to disk
+const apiMaxMemory = 32 << 20
+
+// DefaultApiMaxUpload is the default size limit of a photo upload request
+const DefaultApiMaxUpload = 64 << 20
+
+var errApiUnauthorized = errors.New("missing or invalid api token")
+var errApiMissingPhoto = errors.New("missing photo file")
+var errApiMissingThread = errors.New("missing thread")
+var errApiMissingName = errors.New("missing thread name")
+var errApiBadPhotoId = errors.New("invalid photo id")
+var errApiBadOffset = errors.New("invalid offset")
+var errApiTooLarge = errors.New("upload is too large")
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type apiStatus struct {
+	Version string `json:"version"`
+	Id      string `json:"id,omitempty"`
+	PeerId  string `json:"peer_id,omitempty"`
+	Started bool   `json:"started"`
+	Online  bool   `json:"online"`
+	Locked  bool   `json:"locked"`
+	Gateway string `json:"gateway"`
+}
+
+type apiProfile struct {
+	Id       string `json:"id"`
+	Username string `json:"username,omitempty"`
+	SignedIn bool   `json:"signed_in"`
+	PeerId   string `json:"peer_id,omitempty"`
+}
+
+type apiThread struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Head      string `json:"head,omitempty"`
+	Listening bool   `json:"listening"`
+}
+
+type apiAddThread struct {
+	Name       string `json:"name"`
+	Mnemonic   string `json:"mnemonic,omitempty"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+type apiAddedThread struct {
+	Thread   apiThread `json:"thread"`
+	Mnemonic string    `json:"mnemonic,omitempty"`
+	Index    *int      `json:"index,omitempty"`
+}
+
+type apiPhoto struct {
+	Block repo.Block           `json:"block"`
+	Meta  *model.PhotoMetadata `json:"meta,omitempty"`
+	Urls  map[string]string    `json:"urls"`
+}
+
+type apiSharePhoto struct {
+	Thread  string `json:"thread"`
+	Caption string `json:"caption,omitempty"`
+}
+
+type apiAddedPhoto struct {
+	Id        string `json:"id"`
+	BlockId   string `json:"block_id"`
+	Duplicate bool   `json:"duplicate"`
+}
+
+type apiItems struct {
+	Items interface{} `json:"items"`
+}
+
+// GetApiAddress returns the rest api's address, empty if the api is disabled
+func (t *TextileNode) GetApiAddress() string {
+	if t.api == nil {
+		return ""
+	}
+	return t.api.Addr
+}
+
+// GetApiToken returns the bearer token required by the rest api
+func (t *TextileNode) GetApiToken() string {
+	return t.apiToken
+}
+
+// ApiHandler returns the rest api's http handler, which requires the api token on every request
+func (t *TextileNode) ApiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(apiPrefix+"/status", t.apiAuth(t.apiStatus))
+	mux.HandleFunc(apiPrefix+"/profile", t.apiAuth(t.apiProfile))
+	mux.HandleFunc(apiPrefix+"/threads", t.apiAuth(t.apiThreads))
+	mux.HandleFunc(apiPrefix+"/threads/", t.apiAuth(t.apiThread))
+	mux.HandleFunc(apiPrefix+"/photos/", t.apiAuth(t.apiPhoto))
+	return mux
+}
+
+// startApi starts the rest api server in a goroutine
+func (t *TextileNode) startApi() {
+	go func() {
+		if err := t.api.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Errorf("api error: %s", err)
+		}
+		log.Info("rest api was shutdown")
+	}()
+	log.Infof("rest api listening at %s", t.api.Addr)
+}
+
+// apiAuth rejects requests without the api token as a bearer token
+func (t *TextileNode) apiAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if t.apiToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(t.apiToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeApiError(w, http.StatusUnauthorized, errApiUnauthorized)
+			return
+		}
+		log.Debugf("api request: %s %s", r.Method, r.URL.Path)
+		handler(w, r)
+	}
+}
+
+// apiStatus handles GET /status
+func (t *TextileNode) apiStatus(w http.ResponseWriter, r *http.Request) {
+	if !apiMethod(w, r, http.MethodGet) {
+		return
+	}
+	status := apiStatus{
+		Version: Version,
+		Started: t.Wallet.Started(),
+		Online:  t.Wallet.Online(),
+		Locked:  t.Wallet.Locked(),
+		Gateway: t.GetGatewayAddress(),
+	}
+	status.Id, _ = t.Wallet.GetId()
+	status.PeerId, _ = t.Wallet.GetIPFSPeerId()
+	writeJSON(w, http.StatusOK, status)
+}
+
+// apiProfile handles GET /profile
+func (t *TextileNode) apiProfile(w http.ResponseWriter, r *http.Request) {
+	if !apiMethod(w, r, http.MethodGet) {
+		return
+	}
+	id, err := t.Wallet.GetId()
+	if err != nil {
+		writeApiError(w, apiErrorStatus(err), err)
+		return
+	}
+	profile := apiProfile{Id: id}
+	profile.SignedIn, _ = t.Wallet.IsSignedIn()
+	if profile.SignedIn {
+		profile.Username, _ = t.Wallet.GetUsername()
+	}
+	profile.PeerId, _ = t.Wallet.GetIPFSPeerId()
+	writeJSON(w, http.StatusOK, profile)
+}
+
+// apiThreads handles GET and POST /threads
+func (t *TextileNode) apiThreads(w http.ResponseWriter, r *http.Request) {
+	if !apiMethod(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		threads := make([]apiThread, 0)
+		for _, thrd := range t.Wallet.Threads() {
+			threads = append(threads, newApiThread(thrd))
+		}
+		writeJSON(w, http.StatusOK, apiItems{Items: threads})
+		return
+	}
+
+	var req apiAddThread
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeApiError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Name == "" {
+		writeApiError(w, http.StatusBadRequest, errApiMissingName)
+		return
+	}
+	var added apiAddedThread
+	var thrd *thread.Thread
+	var err error
+	if req.Mnemonic == "" {
+		var index int
+		thrd, index, added.Mnemonic, err = t.Wallet.AddNewThread(req.Name)
+		if index >= 0 {
+			added.Index = &index
+		}
+	} else {
+		thrd, added.Mnemonic, err = t.Wallet.AddThreadWithMnemonic(req.Name, &req.Mnemonic, req.Passphrase)
+	}
+	if err != nil {
+		writeApiError(w, apiErrorStatus(err), err)
+		return
+	}
+	subscribeThread(thrd)
+	added.Thread = newApiThread(thrd)
+	writeJSON(w, http.StatusCreated, added)
+}
+
+// apiThread handles routes under /threads/{thread}, where thread is an id or name
+func (t *TextileNode) apiThread(w http.ResponseWriter, r *http.Request) {
+	parts := apiPathParts(r, "/threads/")
+	thrd := t.Wallet.GetThread(parts[0])
+	if thrd == nil {
+		thrd = t.Wallet.GetThreadByName(parts[0])
+	}
+	if thrd == nil {
+		writeApiError(w, http.StatusNotFound, wallet.ErrThreadNotFound)
+		return
+	}
+	switch {
+	case len(parts) == 1:
+		t.apiThreadRoot(w, r, thrd)
+	case len(parts) == 2 && parts[1] == "peers":
+		if !apiMethod(w, r, http.MethodGet) {
+			return
+		}
+		writeJSON(w, http.StatusOK, apiItems{Items: append(make([]string, 0), thrd.Peers()...)})
+	case len(parts) == 2 && parts[1] == "publish":
+		if !apiMethod(w, r, http.MethodPost) {
+			return
+		}
+		if err := thrd.PostHead(); err != nil {
+			writeApiError(w, apiErrorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, newApiThread(thrd))
+	case len(parts) == 2 && parts[1] == "photos":
+		t.apiThreadPhotos(w, r, thrd)
+	default:
+		writeApiError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+// apiThreadRoot handles GET and DELETE /threads/{thread}
+func (t *TextileNode) apiThreadRoot(w http.ResponseWriter, r *http.Request, thrd *thread.Thread) {
+	if !apiMethod(w, r, http.MethodGet, http.MethodDelete) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, newApiThread(thrd))
+		return
+	}
+	if err := t.RemoveThread(thrd.Id); err != nil {
+		writeApiError(w, apiErrorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiThreadPhotos handles GET /threads/{thread}/photos, paged with offset and limit,
+// and POST with a multipart photo file and optional caption
+func (t *TextileNode) apiThreadPhotos(w http.ResponseWriter, r *http.Request, thrd *thread.Thread) {
+	if !apiMethod(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+	if r.Method == http.MethodGet {
+		limit := -1
+		if l := r.URL.Query().Get("limit"); l != "" {
+			var err error
+			limit, err = strconv.Atoi(l)
+			if err != nil {
+				writeApiError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		// offsets are block ids in this thread
+		offset := r.URL.Query().Get("offset")
+		if offset != "" {
+			if block, err := t.Wallet.GetBlock(offset); err != nil || block.ThreadPubKey != thrd.Id {
+				writeApiError(w, http.StatusBadRequest, errApiBadOffset)
+				return
+			}
+		}
+		blocks := append(make([]repo.Block, 0), thrd.Blocks(offset, limit)...)
+		writeJSON(w, http.StatusOK, apiItems{Items: blocks})
+		return
+	}
+
+	// uploads are spilled to disk, so they're capped
+	r.Body = http.MaxBytesReader(w, r.Body, t.apiMaxUpload)
+	if err := r.ParseMultipartForm(apiMaxMemory); err != nil {
+		if r.ContentLength > t.apiMaxUpload || apiBodyTooLarge(err) {
+			writeApiError(w, http.StatusRequestEntityTooLarge, errApiTooLarge)
+			return
+		}
+		writeApiError(w, http.StatusBadRequest, err)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	file, header, err := r.FormFile("photo")
+	if err != nil {
+		writeApiError(w, http.StatusBadRequest, errApiMissingPhoto)
+		return
+	}
+	defer file.Close()
+
+	// photos are added from disk, keeping the name since meta data uses its extension
+	dir, err := ioutil.TempDir(filepath.Join(t.Wallet.GetRepoPath(), "tmp"), "upload")
+	if err != nil {
+		writeApiError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Base(header.Filename)
+	if name == "." || name == string(filepath.Separator) {
+		name = "photo"
+	}
+	path := filepath.Join(dir, name)
+	if err := writeUpload(path, file); err != nil {
+		writeApiError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	added, err := t.Wallet.AddPhoto(path)
+	if err != nil {
+		writeApiError(w, apiErrorStatus(err), err)
+		return
+	}
+	if !added.Duplicate {
+		t.Wallet.QueueUploadOrDiscard(added.RemoteRequest)
+	}
+	tadded, err := t.Wallet.AddPhotoToThread(added.Id, added.Key, thrd, r.FormValue("caption"))
+	if err != nil {
+		writeApiError(w, apiErrorStatus(err), err)
+		return
+	}
+	if !tadded.Duplicate {
+		t.Wallet.QueueUploadOrDiscard(tadded.RemoteRequest)
+	}
+	writeJSON(w, http.StatusCreated, apiAddedPhoto{Id: added.Id, BlockId: tadded.Id, Duplicate: tadded.Duplicate})
+}
+
+// apiPhoto handles GET /photos/{id} and POST /photos/{id}/share, where id is a photo target
+func (t *TextileNode) apiPhoto(w http.ResponseWriter, r *http.Request) {
+	parts := apiPathParts(r, "/photos/")
+	if len(parts) > 0 {
+		// photo ids are target cids
+		if _, err := cid.Decode(parts[0]); err != nil {
+			writeApiError(w, http.StatusBadRequest, errApiBadPhotoId)
+			return
+		}
+	}
+	switch {
+	case len(parts) == 1:
+		if !apiMethod(w, r, http.MethodGet) {
+			return
+		}
+		t.apiGetPhoto(w, parts[0])
+	case len(parts) == 2 && parts[1] == "share":
+		if !apiMethod(w, r, http.MethodPost) {
+			return
+		}
+		t.apiSharePhoto(w, r, parts[0])
+	default:
+		writeApiError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+// apiGetPhoto writes a photo's block and meta data, with signed gateway urls for its files
+func (t *TextileNode) apiGetPhoto(w http.ResponseWriter, id string) {
+	block, err := t.Wallet.GetBlockByTarget(id)
+	if err != nil {
+		writeApiError(w, http.StatusNotFound, err)
+		return
+	}
+	thrd := t.Wallet.GetThread(block.ThreadPubKey)
+	if thrd == nil {
+		writeApiError(w, http.StatusNotFound, wallet.ErrThreadNotFound)
+		return
+	}
+	meta, err := thrd.GetPhotoMetaData(id, block)
+	if err != nil {
+		writeApiError(w, http.StatusNotFound, err)
+		return
+	}
+	photo := apiPhoto{Block: *block, Meta: meta, Urls: make(map[string]string)}
+	for _, name := range []string{"photo", "thumb", "meta"} {
+		url, err := t.SignGatewayTargetURL(id, name, DefaultGatewayURLTTL)
+		if err != nil {
+			writeApiError(w, apiErrorStatus(err), err)
+			return
+		}
+		photo.Urls[name] = url
+	}
+	writeJSON(w, http.StatusOK, photo)
+}
+
+// apiSharePhoto adds an existing photo to another thread
+func (t *TextileNode) apiSharePhoto(w http.ResponseWriter, r *http.Request, id string) {
+	var req apiSharePhoto
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeApiError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Thread == "" {
+		writeApiError(w, http.StatusBadRequest, errApiMissingThread)
+		return
+	}
+	thrd := t.Wallet.GetThread(req.Thread)
+	if thrd == nil {
+		thrd = t.Wallet.GetThreadByName(req.Thread)
+	}
+	if thrd == nil {
+		writeApiError(w, http.StatusNotFound, wallet.ErrThreadNotFound)
+		return
+	}
+	if _, err := t.Wallet.GetBlockByTarget(id); err != nil {
+		writeApiError(w, http.StatusNotFound, err)
+		return
+	}
+	shared, err := t.Wallet.SharePhoto(id, thrd, req.Caption)
+	if err != nil {
+		writeApiError(w, apiErrorStatus(err), err)
+		return
+	}
+	if !shared.Duplicate {
+		t.Wallet.QueueUploadOrDiscard(shared.RemoteRequest)
+	}
+	writeJSON(w, http.StatusCreated, apiAddedPhoto{Id: id, BlockId: shared.Id, Duplicate: shared.Duplicate})
+}
+
+// newApiThread returns the api representation of a thread
+func newApiThread(thrd *thread.Thread) apiThread {
+	head, _ := thrd.GetHead()
+	return apiThread{
+		Id:        thrd.Id,
+		Name:      thrd.Name,
+		Head:      head,
+		Listening: thrd.Listening(),
+	}
+}
+
+// subscribeThread listens for updates in a thread added through the api
+func subscribeThread(thrd *thread.Thread) {
+	datac := make(chan thread.Update)
+	go thrd.Subscribe(datac)
+	go func() {
+		for update := range datac {
+			log.Debugf("new photo %s in thread %s", update.Id, update.Thread)
+		}
+	}()
+}
+
+// loadApiToken reads the rest api token from the repo, creating it on first use
+func loadApiToken(repoPath string) (string, error) {
+	path := filepath.Join(repoPath, ApiTokenFile)
+	token, err := ioutil.ReadFile(path)
+	if err == nil && len(strings.TrimSpace(string(token))) > 0 {
+		return strings.TrimSpace(string(token)), nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	encoded := hex.EncodeToString(secret)
+	if err := ioutil.WriteFile(path, []byte(encoded), 0600); err != nil {
+		return "", err
+	}
+	return encoded, nil
+}
+
+// writeUpload copies an uploaded file to disk
+func writeUpload(path string, file io.Reader) error {
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, file); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// apiBodyTooLarge returns whether err is from reading past a MaxBytesReader's limit,
+// which has no exported error to compare with
+func apiBodyTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "request body too large")
+}
+
+// apiPathParts splits the path after a route prefix
+func apiPathParts(r *http.Request, route string) []string {
+	rest := strings.TrimPrefix(r.URL.Path, apiPrefix+route)
+	return strings.Split(strings.Trim(rest, "/"), "/")
+}
+
+// apiMethod responds with 405 unless the request uses one of the methods
+func apiMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeApiError(w, http.StatusMethodNotAllowed, errors.New(fmt.Sprintf("method %s not allowed", r.Method)))
+	return false
+}
+
+// apiErrorStatus maps wallet errors to http status codes
+func apiErrorStatus(err error) int {
+	switch err {
+	case wallet.ErrStopped, wallet.ErrOffline, wallet.ErrLocked:
+		return http.StatusServiceUnavailable
+	case wallet.ErrThreadExists:
+		return http.StatusConflict
+	case wallet.ErrThreadNotFound:
+		return http.StatusNotFound
+	case image.ErrFormat:
+		return http.StatusUnsupportedMediaType
+	}
+	return http.StatusInternalServerError
+}
+
+// writeJSON writes a json response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		log.Errorf("error writing api response: %s", err)
+	}
+}
+
+// writeApiError writes a json error response
+func writeApiError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
@@ -1,12 +1,14 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"github.com/op/go-logging"
 	"github.com/textileio/textile-go/crypto"
 	"github.com/textileio/textile-go/wallet"
 	"gopkg.in/natefinch/lumberjack.v2"
 	"gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/repo/fsrepo"
+	"mime"
 	"net/http"
 	"os"
 	"path"
@@ -29,16 +31,25 @@ var Node *TextileNode
 
 // TextileNode is the main node interface for textile functionality
 type TextileNode struct {
-	Wallet  *wallet.Wallet
-	gateway *http.Server
-	mux     sync.Mutex
+	Wallet         *wallet.Wallet
+	gateway        *http.Server
+	gatewayRawKeys bool
+	api            *http.Server
+	apiToken       string
+	apiMaxUpload   int64
+	gcStop         chan struct{}
+	watcher        *folderWatcher
+	watchMux       sync.Mutex
+	mux            sync.Mutex
 }
 
 // NodeConfig is used to configure the node
 type NodeConfig struct {
-	LogLevel     logging.Level
-	LogFiles     bool
-	WalletConfig wallet.Config
+	LogLevel       logging.Level
+	LogFiles       bool
+	GatewayRawKeys bool  // allow decrypting with a raw file key in the query string
+	ApiMaxUpload   int64 // largest photo upload the rest api accepts in bytes, DefaultApiMaxUpload if zero
+	WalletConfig   wallet.Config
 }
 
 // NewNode creates a new TextileNode
@@ -78,8 +89,23 @@ func NewNode(config NodeConfig) (*TextileNode, error) {
 
 	// finally, construct our node
 	node := &TextileNode{
-		Wallet:  wall,
-		gateway: gateway,
+		Wallet:         wall,
+		gateway:        gateway,
+		gatewayRawKeys: config.GatewayRawKeys,
+	}
+	gateway.Handler = node.GatewayHandler()
+
+	// setup the rest api for other local processes, which mobile nodes don't need
+	if !config.WalletConfig.IsMobile {
+		node.apiToken, err = loadApiToken(config.WalletConfig.RepoPath)
+		if err != nil {
+			return nil, err
+		}
+		node.apiMaxUpload = config.ApiMaxUpload
+		if node.apiMaxUpload <= 0 {
+			node.apiMaxUpload = DefaultApiMaxUpload
+		}
+		node.api = &http.Server{Addr: wall.GetApiAddress(), Handler: node.ApiHandler()}
 	}
 
 	return node, nil
@@ -94,6 +120,11 @@ func (t *TextileNode) StartWallet() (online chan struct{}, err error) {
 		return nil, err
 	}
 
+	// import photos dropped into watched folders
+	if err := t.startWatching(); err != nil {
+		log.Errorf("error starting folder watches: %s", err)
+	}
+
 	// construct decrypting http gateway
 	var gwpErrc <-chan error
 	gwpErrc, err = t.startGateway()
@@ -116,6 +147,11 @@ func (t *TextileNode) StartWallet() (online chan struct{}, err error) {
 		}
 	}()
 
+	// serve the rest api
+	if t.api != nil {
+		t.startApi()
+	}
+
 	return online, nil
 }
 
@@ -130,7 +166,14 @@ func (t *TextileNode) StopWallet() error {
 		log.Errorf("error shutting down gateway: %s", err)
 		return err
 	}
+	if t.api != nil {
+		if err := t.api.Shutdown(cgCtx); err != nil {
+			log.Errorf("error shutting down rest api: %s", err)
+			return err
+		}
+	}
 
+	t.stopWatching()
 	if err := t.Wallet.Stop(); err != nil {
 		return err
 	}
@@ -172,83 +215,126 @@ func (t *TextileNode) StartPublishing() {
 	}
 }
 
+// ScheduleGC runs a repo gc every interval while the wallet is started, zero disables it
+func (t *TextileNode) ScheduleGC(interval time.Duration) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.gcStop != nil {
+		close(t.gcStop)
+		t.gcStop = nil
+	}
+	if interval <= 0 {
+		log.Info("scheduled gc disabled")
+		return
+	}
+	stop := make(chan struct{})
+	t.gcStop = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !t.Wallet.Started() {
+					continue
+				}
+				if _, err := t.Wallet.GC(); err != nil {
+					log.Errorf("scheduled gc failed: %s", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+	log.Infof("scheduled gc every %s", interval.String())
+}
+
+// RemoveThread stops watching folders for a thread and removes it
+func (t *TextileNode) RemoveThread(id string) error {
+	watches, err := t.Watches()
+	if err != nil {
+		return err
+	}
+	for _, watch := range watches {
+		if watch.ThreadId != id {
+			continue
+		}
+		if err := t.RemoveWatch(watch.Path); err != nil {
+			return err
+		}
+	}
+	return t.Wallet.RemoveThread(id)
+}
+
 // GetGatewayAddress returns the gateway's address
 func (t *TextileNode) GetGatewayAddress() string {
 	return t.gateway.Addr
 }
 
-// registerGatewayHandler registers a handler for the gateway
-func (t *TextileNode) registerGatewayHandler() {
-	defer func() {
-		if recover() != nil {
-			log.Debug("gateway handler already registered")
-		}
-	}()
-	// NOTE: always returning 404 in the event of an error seems most secure as it doesn't reveal existence
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		log.Debugf("gateway request: %s", r.URL.RequestURI())
-		parsed, contentType := parsePath(r.URL.Path)
-
-		// look for block id
-		blockId := r.URL.Query()["block"]
-		if blockId != nil {
-			block, err := t.Wallet.GetBlock(blockId[0])
-			if err != nil {
-				log.Errorf("error finding block %s: %s", blockId[0], err)
-				return
-			}
-			thrd := t.Wallet.GetThread(block.Id)
-			if thrd == nil {
-				log.Errorf("could not find thread for block: %s", block.Id)
-				return
-			}
-			file, err := thrd.GetFileData(parsed, block)
-			if err != nil {
-				log.Errorf("error decrypting path %s: %s", parsed, err)
-				w.WriteHeader(404)
-				return
-			}
-			if contentType != "" {
-				w.Header().Set("Content-Type", contentType)
-			}
-			w.Write(file)
-			return
-		}
+// GatewayHandler returns the decrypting gateway's http handler
+// NOTE: content errors respond with 404 as it doesn't reveal whether the content exists
+func (t *TextileNode) GatewayHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/threads/", gatewayMethods(t.serveThreadFile))
+	mux.HandleFunc("/targets/", gatewayMethods(t.serveTargetFile))
+	mux.HandleFunc("/", gatewayMethods(t.serveIPFSPath))
+	return mux
+}
 
-		// get raw file
-		file, err := t.Wallet.GetDataAtPath(parsed)
-		if err != nil {
-			log.Errorf("error getting raw path %s: %s", parsed, err)
-			w.WriteHeader(404)
-			return
-		}
+// serveIPFSPath serves content under an ipfs path, decrypting it if a raw key is provided
+func (t *TextileNode) serveIPFSPath(w http.ResponseWriter, r *http.Request) {
+	log.Debugf("gateway request: %s", r.URL.RequestURI())
+	parsed, contentType := parsePath(r.URL.Path)
+
+	// raw keys are opt-in since they leak into logs and history
+	key := r.URL.Query()["key"]
+	if key != nil && !t.gatewayRawKeys {
+		log.Errorf("raw key requested for %s, but raw keys are disabled", parsed)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
 
-		// if key is provided, try to decrypt the file with it
-		key := r.URL.Query()["key"]
-		if key != nil {
-			plain, err := crypto.DecryptAES(file, []byte(key[0]))
-			if err != nil {
-				log.Errorf("error decrypting %s: %s", parsed, err)
-				w.WriteHeader(404)
-				return
-			}
-			if contentType != "" {
-				w.Header().Set("Content-Type", contentType)
-			}
-			w.Write(plain)
+	etag, err := t.gatewayETag(parsed)
+	if err != nil {
+		log.Errorf("error resolving path %s: %s", parsed, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	// only decrypted content is private
+	cache := gatewayPublicCacheControl
+	if key != nil {
+		cache = gatewayCacheControl(0)
+	}
+	if notModified(w, r, etag, cache) {
+		return
+	}
+
+	// get raw file
+	file, err := t.Wallet.GetDataAtPath(parsed)
+	if err != nil {
+		log.Errorf("error getting raw path %s: %s", parsed, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// if key is provided, try to decrypt the file with it
+	if key != nil {
+		plain, err := crypto.DecryptAES(file, []byte(key[0]))
+		if err != nil {
+			log.Errorf("error decrypting %s: %s", parsed, err)
+			w.WriteHeader(http.StatusNotFound)
 			return
 		}
+		serveGatewayContent(w, r, etag, cache, contentType, bytes.NewReader(plain))
+		return
+	}
 
-		// lastly, just return the raw bytes (standard gateway)
-		w.Write(file)
-	})
+	// lastly, just return the raw bytes (standard gateway)
+	serveGatewayContent(w, r, etag, cache, contentType, bytes.NewReader(file))
 }
 
 // startGateway starts the secure HTTP gatway server
 func (t *TextileNode) startGateway() (<-chan error, error) {
-	// try to register our handler
-	t.registerGatewayHandler()
-
 	// Start the HTTPS server in a goroutine
 	errc := make(chan error)
 	go func() {
@@ -260,19 +346,28 @@ func (t *TextileNode) startGateway() (<-chan error, error) {
 	return errc, nil
 }
 
+// photoContentTypes covers every photo extension we add, since the system mime table may not
+var photoContentTypes = map[string]string{
+	"jpg":  "image/jpeg",
+	"jpeg": "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+	"webp": "image/webp",
+	"tif":  "image/tiff",
+	"tiff": "image/tiff",
+	"bmp":  "image/bmp",
+}
+
+// parsePath strips an extension from a path, returning the content type it names
 func parsePath(path string) (parsed string, contentType string) {
 	parts := strings.Split(path, ".")
 	parsed = parts[0]
 	if len(parts) == 1 {
 		return parsed, ""
 	}
-	switch parts[1] {
-	case "jpg", "jpeg":
-		contentType = "image/jpeg"
-	case "png":
-		contentType = "image/png"
-	case "gif":
-		contentType = "image/gif"
+	ext := strings.ToLower(parts[len(parts)-1])
+	if contentType, ok := photoContentTypes[ext]; ok {
+		return parsed, contentType
 	}
-	return parsed, contentType
+	return parsed, mime.TypeByExtension("." + ext)
 }
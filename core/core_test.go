@@ -1,12 +1,24 @@
 package core_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"github.com/op/go-logging"
 	. "github.com/textileio/textile-go/core"
 	util "github.com/textileio/textile-go/util/testing"
 	"github.com/textileio/textile-go/wallet"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 var node *TextileNode
@@ -14,8 +26,9 @@ var node *TextileNode
 func TestNewNode(t *testing.T) {
 	os.RemoveAll("testdata/.ipfs")
 	config := NodeConfig{
-		LogLevel: logging.DEBUG,
-		LogFiles: false,
+		LogLevel:       logging.DEBUG,
+		LogFiles:       false,
+		GatewayRawKeys: true,
 		WalletConfig: wallet.Config{
 			RepoPath:   "testdata/.ipfs",
 			CentralAPI: util.CentralApiURL,
@@ -50,6 +63,371 @@ func TestTextileNode_GetGatewayAddress(t *testing.T) {
 	}
 }
 
+func TestTextileNode_AddWatch(t *testing.T) {
+	thrd, _, err := node.Wallet.AddThreadWithMnemonic("default", nil, "")
+	if err != nil {
+		t.Errorf("add thread failed: %s", err)
+		return
+	}
+	dir, err := ioutil.TempDir("", "textile_watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := node.AddWatch(dir, "default"); err != nil {
+		t.Errorf("add watch failed: %s", err)
+		return
+	}
+	if _, err := node.AddWatch(dir, "default"); err != wallet.ErrWatchExists {
+		t.Errorf("add watch again returned wrong error: %v", err)
+	}
+
+	// drop a photo in
+	photo, err := ioutil.ReadFile("../wallet/testdata/image.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "image.jpg"), photo, 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second * 20)
+	for len(thrd.Blocks("", -1)) == 0 {
+		if time.Now().After(deadline) {
+			t.Error("watched photo was not added to thread")
+			break
+		}
+		time.Sleep(time.Millisecond * 500)
+	}
+
+	if err := node.RemoveWatch(dir); err != nil {
+		t.Errorf("remove watch failed: %s", err)
+	}
+	watches, err := node.Watches()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(watches) != 0 {
+		t.Error("watch was not removed")
+	}
+}
+
+func TestTextileNode_GatewayCaching(t *testing.T) {
+	thrd := node.Wallet.GetThreadByName("default")
+	if thrd == nil {
+		t.Error("could not find default thread")
+		return
+	}
+	blocks := thrd.Blocks("", 1)
+	if len(blocks) == 0 {
+		t.Error("default thread has no photos")
+		return
+	}
+	key, err := thrd.GetFileKey(&blocks[0])
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	url := fmt.Sprintf("http://%s/ipfs/%s/thumb?key=%s", node.GetGatewayAddress(), blocks[0].Target, key)
+
+	res, err := http.Get(url)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	etag := res.Header.Get("ETag")
+	if res.StatusCode != http.StatusOK || etag == "" || !strings.HasPrefix(res.Header.Get("Cache-Control"), "private") {
+		t.Errorf("gateway got bad response: %d, %v", res.StatusCode, res.Header)
+		return
+	}
+	if res.Header.Get("Content-Length") != strconv.Itoa(len(body)) || res.Header.Get("Content-Type") != "image/jpeg" {
+		t.Errorf("gateway got bad content headers: %v", res.Header)
+	}
+
+	// conditional requests skip the body
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("If-None-Match", etag)
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotModified {
+		t.Errorf("gateway with matching etag got %d", res.StatusCode)
+	}
+
+	// ranges are over the decrypted content
+	req, _ = http.NewRequest("GET", url, nil)
+	req.Header.Set("Range", "bytes=0-9")
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	part, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusPartialContent || !bytes.Equal(part, body[:10]) {
+		t.Errorf("gateway range got %d with %d bytes", res.StatusCode, len(part))
+	}
+	if res.Header.Get("Content-Range") != fmt.Sprintf("bytes 0-9/%d", len(body)) {
+		t.Errorf("gateway range got bad content range: %s", res.Header.Get("Content-Range"))
+	}
+}
+
+func TestTextileNode_SignGatewayURL(t *testing.T) {
+	thrd := node.Wallet.GetThreadByName("default")
+	if thrd == nil {
+		t.Error("could not find default thread")
+		return
+	}
+	blocks := thrd.Blocks("", 1)
+	if len(blocks) == 0 {
+		t.Error("default thread has no photos")
+		return
+	}
+	if _, err := node.SignGatewayURL(blocks[0].Id, "thumb", 0); err != ErrBadGatewayURLTTL {
+		t.Errorf("sign with zero ttl returned wrong error: %v", err)
+	}
+	signed, err := node.SignGatewayURL(blocks[0].Id, "thumb", DefaultGatewayURLTTL)
+	if err != nil {
+		t.Errorf("sign gateway url failed: %s", err)
+		return
+	}
+	status := func(url string) int {
+		res, err := http.Get(url)
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+		res.Body.Close()
+		return res.StatusCode
+	}
+	if code := status(signed); code != http.StatusOK {
+		t.Errorf("signed gateway url got %d", code)
+	}
+
+	// block requests must be signed for the same path
+	unsigned := signed[:strings.Index(signed, "?")]
+	if code := status(unsigned); code != http.StatusForbidden {
+		t.Errorf("unsigned gateway url got %d", code)
+	}
+	if code := status(strings.Replace(signed, "/thumb?", "/photo?", 1)); code != http.StatusForbidden {
+		t.Errorf("gateway url signed for another path got %d", code)
+	}
+	if code := status(signed + "0"); code != http.StatusForbidden {
+		t.Errorf("gateway url with bad signature got %d", code)
+	}
+
+	// expired urls are rejected
+	expiring, err := node.SignGatewayURL(blocks[0].Id, "thumb", time.Second)
+	if err != nil {
+		t.Errorf("sign gateway url failed: %s", err)
+		return
+	}
+	time.Sleep(time.Second * 2)
+	if code := status(expiring); code != http.StatusForbidden {
+		t.Errorf("expired gateway url got %d", code)
+	}
+}
+
+func TestTextileNode_GatewayRoutes(t *testing.T) {
+	thrd := node.Wallet.GetThreadByName("default")
+	if thrd == nil {
+		t.Error("could not find default thread")
+		return
+	}
+	blocks := thrd.Blocks("", 1)
+	if len(blocks) == 0 {
+		t.Error("default thread has no photos")
+		return
+	}
+	handler := node.GatewayHandler()
+	serve := func(method string, url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(method, url, nil))
+		return rec
+	}
+
+	// thread routes decrypt with the thread key and sniff the content type
+	thumb, err := node.SignGatewayURL(blocks[0].Id, "thumb", DefaultGatewayURLTTL)
+	if err != nil {
+		t.Errorf("sign gateway url failed: %s", err)
+		return
+	}
+	if !strings.Contains(thumb, fmt.Sprintf("/threads/%s/blocks/%s/thumb?", thrd.Id, blocks[0].Id)) {
+		t.Errorf("signed gateway url has wrong path: %s", thumb)
+	}
+	rec := serve("GET", thumb)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/jpeg" || rec.Body.Len() == 0 {
+		t.Errorf("thread route got %d, %v", rec.Code, rec.Header())
+	}
+	if rec.Header().Get("Cache-Control") != "private, no-cache" || rec.Header().Get("ETag") == "" {
+		t.Errorf("thread route should revalidate since it serves the latest version: %v", rec.Header())
+	}
+	meta, err := node.SignGatewayURL(blocks[0].Id, "meta", DefaultGatewayURLTTL)
+	if err != nil {
+		t.Errorf("sign gateway url failed: %s", err)
+		return
+	}
+	rec = serve("GET", meta)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" || !json.Valid(rec.Body.Bytes()) {
+		t.Errorf("thread route meta got %d, %v", rec.Code, rec.Header())
+	}
+
+	// target routes find a block to decrypt with
+	photo, err := node.SignGatewayTargetURL(blocks[0].Target, "photo", DefaultGatewayURLTTL)
+	if err != nil {
+		t.Errorf("sign gateway target url failed: %s", err)
+		return
+	}
+	rec = serve("GET", photo)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("target route got %d, %v", rec.Code, rec.Header())
+	}
+	if cache := rec.Header().Get("Cache-Control"); !strings.HasPrefix(cache, "private") || strings.Contains(cache, "31536000") {
+		t.Errorf("target route should only be cached privately until the url expires: %s", cache)
+	}
+	if _, err := node.SignGatewayTargetURL("QmNotATarget", "photo", DefaultGatewayURLTTL); err == nil {
+		t.Error("sign gateway target url for missing target should fail")
+	}
+
+	// bad requests
+	if rec := serve("POST", thumb); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("thread route post got %d", rec.Code)
+	}
+	if rec := serve("GET", strings.Split(thumb, "?")[0]); rec.Code != http.StatusForbidden {
+		t.Errorf("unsigned thread route got %d", rec.Code)
+	}
+	if rec := serve("GET", strings.Replace(photo, "/photo?", "/thumb?", 1)); rec.Code != http.StatusForbidden {
+		t.Errorf("target route signed for another file got %d", rec.Code)
+	}
+	if rec := serve("GET", fmt.Sprintf("/threads/%s/blocks/%s/pk", thrd.Id, blocks[0].Id)); rec.Code != http.StatusNotFound {
+		t.Errorf("thread route for unknown file got %d", rec.Code)
+	}
+	if rec := serve("GET", fmt.Sprintf("/threads/%s", thrd.Id)); rec.Code != http.StatusNotFound {
+		t.Errorf("incomplete thread route got %d", rec.Code)
+	}
+}
+
+func TestTextileNode_Api(t *testing.T) {
+	handler := node.ApiHandler()
+	call := func(method string, path string, body io.Reader, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v0"+path, body)
+		req.Header.Set("Authorization", "Bearer "+node.GetApiToken())
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// every request needs the token
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v0/status", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("api without token got %d", rec.Code)
+	}
+	if len(node.GetApiToken()) == 0 || len(node.GetApiAddress()) == 0 {
+		t.Error("api should have a token and address")
+	}
+
+	// status and profile
+	rec = call("GET", "/status", nil, "")
+	var status map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil || rec.Code != http.StatusOK {
+		t.Errorf("api status got %d: %s", rec.Code, rec.Body.String())
+	} else if status["started"] != true || status["version"] != Version {
+		t.Errorf("api status is wrong: %v", status)
+	}
+	if rec := call("POST", "/status", nil, ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("api status post got %d", rec.Code)
+	}
+	if rec := call("GET", "/profile", nil, ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"id"`) {
+		t.Errorf("api profile got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// threads
+	rec = call("POST", "/threads", strings.NewReader(`{"name":"api"}`), "application/json")
+	if rec.Code != http.StatusCreated {
+		t.Errorf("api add thread got %d: %s", rec.Code, rec.Body.String())
+		return
+	}
+	if rec := call("POST", "/threads", strings.NewReader(`{"name":"api"}`), "application/json"); rec.Code != http.StatusConflict {
+		t.Errorf("api add existing thread got %d", rec.Code)
+	}
+	if rec := call("GET", "/threads", nil, ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"name":"api"`) {
+		t.Errorf("api list threads got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := call("GET", "/threads/api/peers", nil, ""); rec.Code != http.StatusOK {
+		t.Errorf("api thread peers got %d", rec.Code)
+	}
+	if rec := call("POST", "/threads/api/publish", nil, ""); rec.Code != http.StatusOK {
+		t.Errorf("api publish thread got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// photos
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	part, err := form.CreateFormFile("photo", "image.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	photo, err := ioutil.ReadFile("../wallet/testdata/image.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(photo)
+	form.WriteField("caption", "from the api")
+	form.Close()
+	rec = call("POST", "/threads/api/photos", &buf, form.FormDataContentType())
+	var added map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &added); err != nil || rec.Code != http.StatusCreated {
+		t.Errorf("api add photo got %d: %s", rec.Code, rec.Body.String())
+		return
+	}
+	id, _ := added["id"].(string)
+	req := httptest.NewRequest("POST", "/api/v0/threads/api/photos", strings.NewReader("--x--"))
+	req.ContentLength = DefaultApiMaxUpload + 1
+	req.Header.Set("Authorization", "Bearer "+node.GetApiToken())
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("api add photo over the upload limit got %d", rec.Code)
+	}
+	if rec := call("GET", "/threads/api/photos?limit=10", nil, ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), id) {
+		t.Errorf("api list photos got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = call("GET", "/photos/"+id, nil, "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/targets/"+id+"/thumb?") {
+		t.Errorf("api get photo got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = call("POST", "/photos/"+id+"/share", strings.NewReader(`{"thread":"default"}`), "application/json")
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"block_id"`) {
+		t.Errorf("api share photo got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := call("POST", "/photos/"+id+"/share", strings.NewReader(`{"thread":"nope"}`), "application/json"); rec.Code != http.StatusNotFound {
+		t.Errorf("api share photo to missing thread got %d", rec.Code)
+	}
+	if rec := call("GET", "/photos/x'%20or%20'1'='1", nil, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("api get photo with a bad id got %d", rec.Code)
+	}
+	if rec := call("GET", "/threads/api/photos?offset=x')%20or%20('1'='1", nil, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("api list photos with a bad offset got %d", rec.Code)
+	}
+
+	// removing a thread
+	if rec := call("DELETE", "/threads/api", nil, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("api remove thread got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := call("GET", "/threads/api", nil, ""); rec.Code != http.StatusNotFound {
+		t.Errorf("api get removed thread got %d", rec.Code)
+	}
+}
+
 func TestTextileNode_Stop(t *testing.T) {
 	err := node.StopWallet()
 	if err != nil {
@@ -0,0 +1,315 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet/thread"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gatewayMaxAge is the longest gateway content may be cached, in seconds
+const gatewayMaxAge = 31536000
+
+// gatewayPublicCacheControl is sent with raw ipfs content, which never changes under its path
+const gatewayPublicCacheControl = "public, max-age=31536000, immutable"
+
+// gatewayRevalidateCacheControl is sent with decrypted content that may change under its url
+const gatewayRevalidateCacheControl = "private, no-cache"
+
+// DefaultGatewayURLTTL is how long signed gateway urls are valid for by default
+const DefaultGatewayURLTTL = time.Hour
+
+var ErrBadGatewayURLTTL = errors.New("gateway url ttl must be positive")
+var ErrGatewayURLExpired = errors.New("gateway url is expired")
+var ErrBadGatewaySignature = errors.New("gateway url signature is missing or invalid")
+
+// SignGatewayURL returns a gateway url for a file of a block's target in its thread,
+// which the gateway decrypts with the thread key until the ttl passes
+func (t *TextileNode) SignGatewayURL(blockId string, file string, ttl time.Duration) (string, error) {
+	block, err := t.Wallet.GetBlock(blockId)
+	if err != nil {
+		return "", err
+	}
+	upath := fmt.Sprintf("/threads/%s/blocks/%s/%s", block.ThreadPubKey, block.Id, strings.Trim(file, "/"))
+	return t.signGatewayPath(upath, ttl)
+}
+
+// SignGatewayTargetURL returns a gateway url for a file of a target, which the gateway
+// decrypts with the key of a local block referencing it until the ttl passes
+func (t *TextileNode) SignGatewayTargetURL(target string, file string, ttl time.Duration) (string, error) {
+	if _, err := t.Wallet.GetBlockByTarget(target); err != nil {
+		return "", err
+	}
+	upath := fmt.Sprintf("/targets/%s/%s", target, strings.Trim(file, "/"))
+	return t.signGatewayPath(upath, ttl)
+}
+
+// signGatewayPath returns a gateway url for a path that expires after the ttl
+func (t *TextileNode) signGatewayPath(upath string, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		return "", ErrBadGatewayURLTTL
+	}
+	secret, err := t.Wallet.GatewaySecret()
+	if err != nil {
+		return "", err
+	}
+	parsed, _ := parsePath(upath)
+	exp := time.Now().Add(ttl).Unix()
+
+	query := url.Values{}
+	query.Set("exp", strconv.FormatInt(exp, 10))
+	query.Set("sig", hex.EncodeToString(gatewaySignature(secret, parsed, exp)))
+	return fmt.Sprintf("http://%s%s?%s", t.GetGatewayAddress(), upath, query.Encode()), nil
+}
+
+// verifyGatewayURL checks that a request's signature covers its path and has not expired,
+// returning the url's expiry
+func (t *TextileNode) verifyGatewayURL(r *http.Request, parsed string) (int64, error) {
+	query := r.URL.Query()
+	exp, err := strconv.ParseInt(query.Get("exp"), 10, 64)
+	if err != nil {
+		return 0, ErrBadGatewaySignature
+	}
+	sig, err := hex.DecodeString(query.Get("sig"))
+	if err != nil || len(sig) == 0 {
+		return 0, ErrBadGatewaySignature
+	}
+	secret, err := t.Wallet.GatewaySecret()
+	if err != nil {
+		return 0, err
+	}
+	if !hmac.Equal(sig, gatewaySignature(secret, parsed, exp)) {
+		return 0, ErrBadGatewaySignature
+	}
+	if time.Now().Unix() > exp {
+		return 0, ErrGatewayURLExpired
+	}
+	return exp, nil
+}
+
+// gatewaySignature returns the mac of a gateway path and expiry
+func gatewaySignature(secret []byte, parsed string, exp int64) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(fmt.Sprintf("%s\n%d", parsed, exp)))
+	return mac.Sum(nil)
+}
+
+// serveThreadFile serves a file at /threads/{thread}/blocks/{block}/{file}, decrypted with
+// the thread's key. Photo blocks serve their latest version.
+func (t *TextileNode) serveThreadFile(w http.ResponseWriter, r *http.Request) {
+	log.Debugf("gateway request: %s", r.URL.RequestURI())
+	parsed, _ := parsePath(r.URL.Path)
+	parts := strings.Split(strings.Trim(parsed, "/"), "/")
+	if len(parts) != 5 || parts[2] != "blocks" || !t.gatewayFile(parts[4]) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	exp, err := t.verifyGatewayURL(r, parsed)
+	if err != nil {
+		log.Errorf("error verifying gateway url %s: %s", r.URL.Path, err)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	thrd := t.Wallet.GetThread(parts[1])
+	if thrd == nil {
+		log.Errorf("could not find thread: %s", parts[1])
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	block, err := t.Wallet.GetBlock(parts[3])
+	if err != nil || block.ThreadPubKey != thrd.Id {
+		log.Errorf("could not find block %s in thread %s", parts[3], thrd.Id)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	cache := gatewayCacheControl(exp)
+	switch block.Type {
+	case repo.PhotoBlock:
+		// a later edit changes what the url serves, so caches must revalidate with the etag
+		if versions := thrd.Versions(block.Target); len(versions) > 0 {
+			block = &versions[0]
+		}
+		cache = gatewayRevalidateCacheControl
+	case repo.VersionBlock:
+	default:
+		log.Errorf("block %s has no files", block.Id)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	t.serveBlockFile(w, r, thrd, block, block.Target, parts[4], cache)
+}
+
+// serveTargetFile serves a file at /targets/{id}/{file}, decrypted with the key of
+// a local block referencing the target
+func (t *TextileNode) serveTargetFile(w http.ResponseWriter, r *http.Request) {
+	log.Debugf("gateway request: %s", r.URL.RequestURI())
+	parsed, _ := parsePath(r.URL.Path)
+	parts := strings.Split(strings.Trim(parsed, "/"), "/")
+	if len(parts) != 3 || !t.gatewayFile(parts[2]) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	exp, err := t.verifyGatewayURL(r, parsed)
+	if err != nil {
+		log.Errorf("error verifying gateway url %s: %s", r.URL.Path, err)
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	block, err := t.Wallet.GetBlockByTarget(parts[1])
+	if err != nil {
+		log.Errorf("error finding block for target %s: %s", parts[1], err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	thrd := t.Wallet.GetThread(block.ThreadPubKey)
+	if thrd == nil {
+		log.Errorf("could not find thread for block: %s", block.Id)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	t.serveBlockFile(w, r, thrd, block, parts[1], parts[2], gatewayCacheControl(exp))
+}
+
+// serveBlockFile decrypts and serves a photo file or rendition of a target with a block's key
+func (t *TextileNode) serveBlockFile(w http.ResponseWriter, r *http.Request, thrd *thread.Thread, block *repo.Block, target string, name string, cache string) {
+	// content is immutable under its etag, so a match means we can skip decrypting
+	etag, err := t.gatewayETag(fmt.Sprintf("%s/%s", target, name), fmt.Sprintf("%s/photo", target))
+	if err != nil {
+		log.Errorf("error resolving %s/%s: %s", target, name, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if notModified(w, r, etag, cache) {
+		return
+	}
+
+	reader, err := thrd.GetRendition(target, name, block)
+	if err != nil {
+		log.Errorf("error decrypting %s/%s: %s", target, name, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	defer reader.Close()
+
+	// the reader seeks by chunk, so byte ranges only decrypt what they cover
+	head := make([]byte, 512)
+	n, err := io.ReadFull(reader, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		log.Errorf("error decrypting %s/%s: %s", target, name, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		log.Errorf("error seeking %s/%s: %s", target, name, err)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	serveGatewayContent(w, r, etag, cache, gatewayContentType(name, head[:n]), reader)
+}
+
+// gatewayFile returns whether a name is a photo file or rendition the gateway decrypts
+func (t *TextileNode) gatewayFile(name string) bool {
+	switch name {
+	case "photo", "thumb", "meta":
+		return true
+	}
+	for _, rendition := range t.Wallet.Renditions() {
+		if rendition.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// gatewayContentType sniffs the type of decrypted content, since photos and thumbnails
+// may be any image format regardless of the requested extension
+func gatewayContentType(name string, data []byte) string {
+	if name == "meta" {
+		return "application/json"
+	}
+	return http.DetectContentType(data)
+}
+
+// gatewayMethods responds with 405 to anything but reads
+func gatewayMethods(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// gatewayETag returns a strong etag from the cid of the first path that resolves.
+// Later paths are fallbacks for content that may not exist, e.g. renditions.
+func (t *TextileNode) gatewayETag(paths ...string) (string, error) {
+	var err error
+	for _, p := range paths {
+		var id string
+		id, err = t.Wallet.ResolvePath(p)
+		if err == nil {
+			return fmt.Sprintf(`"%s"`, id), nil
+		}
+	}
+	return "", err
+}
+
+// gatewayCacheControl returns the cache header for decrypted content, which only the requester
+// may cache and only until its signed url expires. A zero expiry never does.
+func gatewayCacheControl(exp int64) string {
+	age := int64(gatewayMaxAge)
+	if exp > 0 && exp-time.Now().Unix() < age {
+		age = exp - time.Now().Unix()
+	}
+	if age < 0 {
+		age = 0
+	}
+	return fmt.Sprintf("private, max-age=%d, immutable", age)
+}
+
+// notModified responds with 304 if the request's If-None-Match matches the etag
+func notModified(w http.ResponseWriter, r *http.Request, etag string, cache string) bool {
+	match := r.Header.Get("If-None-Match")
+	if match == "" || !etagMatches(match, etag) {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cache)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// serveGatewayContent writes content with its etag, cache headers, and length,
+// handling conditional and byte range requests
+func serveGatewayContent(w http.ResponseWriter, r *http.Request, etag string, cache string, contentType string, content io.ReadSeeker) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cache)
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	http.ServeContent(w, r, "", time.Time{}, content)
+}
+
+// etagMatches returns whether an If-None-Match list matches an etag, using weak comparison
+func etagMatches(list string, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet/util"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// watchDebounce is how long a new file must be quiet before it's imported
+const watchDebounce = time.Second * 2
+
+// folderWatcher imports new photos dropped into watched folders
+type folderWatcher struct {
+	watcher *fsnotify.Watcher
+	threads map[string]string
+	timers  map[string]*time.Timer
+	ready   chan string
+	done    chan struct{}
+	mux     sync.Mutex
+}
+
+// AddWatch starts importing new photos in a folder to a thread
+func (t *TextileNode) AddWatch(path string, threadName string) (string, error) {
+	thrd := t.Wallet.GetThreadByName(threadName)
+	if thrd == nil {
+		return "", errors.New(fmt.Sprintf("could not find thread: %s", threadName))
+	}
+	abs, err := t.Wallet.AddWatch(path, thrd)
+	if err != nil {
+		return "", err
+	}
+	t.watchMux.Lock()
+	defer t.watchMux.Unlock()
+	if t.watcher != nil {
+		if err := t.watcher.add(abs, thrd.Id); err != nil {
+			if _, rerr := t.Wallet.RemoveWatch(abs); rerr != nil {
+				log.Errorf("error removing failed watch %s: %s", abs, rerr)
+			}
+			return "", err
+		}
+	}
+	log.Infof("watching %s for thread %s", abs, thrd.Name)
+	return abs, nil
+}
+
+// Watches lists watched folders
+func (t *TextileNode) Watches() ([]repo.Watch, error) {
+	return t.Wallet.Watches()
+}
+
+// RemoveWatch stops importing photos from a folder
+func (t *TextileNode) RemoveWatch(path string) error {
+	abs, err := t.Wallet.RemoveWatch(path)
+	if err != nil {
+		return err
+	}
+	t.watchMux.Lock()
+	defer t.watchMux.Unlock()
+	if t.watcher != nil {
+		t.watcher.remove(abs)
+	}
+	log.Infof("stopped watching %s", abs)
+	return nil
+}
+
+// startWatching watches all persisted folders
+func (t *TextileNode) startWatching() error {
+	t.watchMux.Lock()
+	defer t.watchMux.Unlock()
+	if t.watcher != nil {
+		return nil
+	}
+	watches, err := t.Wallet.Watches()
+	if err != nil {
+		return err
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	fw := &folderWatcher{
+		watcher: fsw,
+		threads: make(map[string]string),
+		timers:  make(map[string]*time.Timer),
+		ready:   make(chan string, 100),
+		done:    make(chan struct{}),
+	}
+	for _, watch := range watches {
+		if err := fw.add(watch.Path, watch.ThreadId); err != nil {
+			log.Warningf("error watching %s: %s", watch.Path, err)
+		}
+	}
+	go fw.run()
+	go t.importWatched(fw)
+	t.watcher = fw
+	return nil
+}
+
+// stopWatching stops all folder watches
+func (t *TextileNode) stopWatching() {
+	t.watchMux.Lock()
+	defer t.watchMux.Unlock()
+	if t.watcher == nil {
+		return
+	}
+	t.watcher.close()
+	t.watcher = nil
+}
+
+// importWatched adds ready photos to their folder's thread, one at a time
+func (t *TextileNode) importWatched(fw *folderWatcher) {
+	for {
+		select {
+		case path := <-fw.ready:
+			threadId := fw.threadFor(path)
+			if threadId == "" {
+				continue
+			}
+			thrd := t.Wallet.GetThread(threadId)
+			if thrd == nil {
+				log.Warningf("could not find thread %s for watched photo %s", threadId, path)
+				continue
+			}
+			added, err := t.Wallet.AddPhoto(path)
+			if err != nil {
+				log.Errorf("error adding watched photo %s: %s", path, err)
+				continue
+			}
+			if !added.Duplicate {
+				t.Wallet.QueueUploadOrDiscard(added.RemoteRequest)
+			}
+			shared, err := t.Wallet.AddPhotoToThread(added.Id, added.Key, thrd, "")
+			if err != nil {
+				log.Errorf("error adding watched photo %s to thread %s: %s", path, thrd.Name, err)
+				continue
+			}
+			if !shared.Duplicate {
+				t.Wallet.QueueUploadOrDiscard(shared.RemoteRequest)
+			}
+			log.Infof("added watched photo %s to thread %s", path, thrd.Name)
+		case <-fw.done:
+			return
+		}
+	}
+}
+
+func (fw *folderWatcher) add(path string, threadId string) error {
+	fw.mux.Lock()
+	defer fw.mux.Unlock()
+	if err := fw.watcher.Add(path); err != nil {
+		return err
+	}
+	fw.threads[path] = threadId
+	return nil
+}
+
+func (fw *folderWatcher) remove(path string) {
+	fw.mux.Lock()
+	defer fw.mux.Unlock()
+	fw.watcher.Remove(path)
+	delete(fw.threads, path)
+}
+
+func (fw *folderWatcher) threadFor(path string) string {
+	fw.mux.Lock()
+	defer fw.mux.Unlock()
+	return fw.threads[filepath.Dir(path)]
+}
+
+func (fw *folderWatcher) close() {
+	fw.mux.Lock()
+	defer fw.mux.Unlock()
+	close(fw.done)
+	for _, timer := range fw.timers {
+		timer.Stop()
+	}
+	fw.watcher.Close()
+}
+
+// run handles watcher events until closed
+func (fw *folderWatcher) run() {
+	for {
+		select {
+		case event, ok := <-fw.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+				continue
+			}
+			name := filepath.Base(event.Name)
+			if strings.HasPrefix(name, ".") || !util.PhotoExtensions[strings.ToLower(filepath.Ext(name))] {
+				continue
+			}
+			fw.debounce(event.Name)
+		case err, ok := <-fw.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Errorf("folder watcher error: %s", err)
+		case <-fw.done:
+			return
+		}
+	}
+}
+
+// debounce waits for a file to stop changing before marking it ready
+func (fw *folderWatcher) debounce(path string) {
+	fw.mux.Lock()
+	defer fw.mux.Unlock()
+	if timer, ok := fw.timers[path]; ok {
+		timer.Reset(watchDebounce)
+		return
+	}
+	fw.timers[path] = time.AfterFunc(watchDebounce, func() {
+		fw.mux.Lock()
+		delete(fw.timers, path)
+		fw.mux.Unlock()
+
+		// still being written
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			return
+		}
+		if time.Since(info.ModTime()) < watchDebounce {
+			fw.debounce(path)
+			return
+		}
+		select {
+		case fw.ready <- path:
+		case <-fw.done:
+		}
+	})
+}
@@ -0,0 +1,392 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// StreamChunkSize is the plaintext size of each chunk in an encrypted stream
+const StreamChunkSize = 64 * 1024
+
+// maxStreamChunkSize bounds the chunk size we'll accept from a stream header
+const maxStreamChunkSize = 16 * 1024 * 1024
+
+// stream header is magic (4) | salt (16) | chunk size (4)
+const (
+	streamSaltSize   = 16
+	streamHeaderSize = 4 + streamSaltSize + 4
+)
+
+var streamMagic = []byte{'T', 'X', 'S', 1}
+
+var ErrInvalidStream = errors.New("invalid encrypted stream")
+
+// NewAESEncryptWriter returns a writer that encrypts to w in authenticated chunks.
+// Each stream uses a subkey derived from key and a random salt, and the final chunk
+// is marked so truncation is detected. Close must be called to write the final chunk.
+func NewAESEncryptWriter(w io.Writer, key []byte) (io.WriteCloser, error) {
+	salt := make([]byte, streamSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := streamAEAD(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, streamHeaderSize)
+	header = append(header, streamMagic...)
+	header = append(header, salt...)
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, StreamChunkSize)
+	header = append(header, size...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		buf:    make([]byte, 0, StreamChunkSize),
+	}, nil
+}
+
+// NewAESDecryptReader returns a reader that decrypts r, which may be a stream from
+// NewAESEncryptWriter or a single-shot ciphertext from EncryptAES
+func NewAESDecryptReader(r io.Reader, key []byte) (io.Reader, error) {
+	if len(key) != 44 {
+		return nil, errors.New("invalid key")
+	}
+	header := make([]byte, streamHeaderSize)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if !isAESStream(header[:n]) {
+		return openLegacy(header[:n], r, key)
+	}
+	chunkSize := binary.BigEndian.Uint32(header[streamHeaderSize-4:])
+	if chunkSize == 0 || chunkSize > maxStreamChunkSize {
+		return openLegacy(header, r, key)
+	}
+	aead, err := streamAEAD(key, header[4:4+streamSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	d := &decryptReader{
+		r:         r,
+		aead:      aead,
+		header:    header,
+		chunkSize: int(chunkSize),
+	}
+
+	// a legacy ciphertext could start with our magic by chance, in which case
+	// the first chunk won't open and we fall back to single-shot decryption
+	raw, err := d.readChunk()
+	if err == ErrInvalidStream {
+		return openLegacy(append(header, raw...), r, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// NewAESDecryptReadSeeker returns a reader like NewAESDecryptReader that can also seek,
+// opening only the chunks covering what's read. Single-shot ciphertexts are decrypted whole.
+func NewAESDecryptReadSeeker(r io.ReadSeeker, key []byte) (io.ReadSeeker, error) {
+	if len(key) != 44 {
+		return nil, errors.New("invalid key")
+	}
+	header := make([]byte, streamHeaderSize)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	legacy := func() (io.ReadSeeker, error) {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		plain, err := openLegacy(nil, r, key)
+		if err != nil {
+			return nil, err
+		}
+		return plain.(io.ReadSeeker), nil
+	}
+	if !isAESStream(header[:n]) {
+		return legacy()
+	}
+	chunkSize := binary.BigEndian.Uint32(header[streamHeaderSize-4:])
+	if chunkSize == 0 || chunkSize > maxStreamChunkSize {
+		return legacy()
+	}
+	aead, err := streamAEAD(key, header[4:4+streamSaltSize])
+	if err != nil {
+		return nil, err
+	}
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	// every chunk but the last is full, and the last holds at least its tag
+	full := int64(chunkSize) + int64(aead.Overhead())
+	body := end - streamHeaderSize
+	chunks := (body + full - 1) / full
+	if chunks == 0 || body-(chunks-1)*full < int64(aead.Overhead()) {
+		return legacy()
+	}
+	d := &decryptReadSeeker{
+		r:         r,
+		aead:      aead,
+		header:    header,
+		chunkSize: int64(chunkSize),
+		chunks:    chunks,
+		size:      body - chunks*int64(aead.Overhead()),
+		index:     -1,
+	}
+
+	// as with NewAESDecryptReader, fall back when the first chunk won't open
+	if err := d.openChunk(0); err == ErrInvalidStream {
+		return legacy()
+	} else if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// EncryptAESStream encrypts everything from r to w in the chunked stream format
+func EncryptAESStream(r io.Reader, w io.Writer, key []byte) error {
+	ew, err := NewAESEncryptWriter(w, key)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(ew, r); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	buf     []byte
+	counter uint64
+	closed  bool
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New("write to closed stream")
+	}
+	n := 0
+	for len(p) > 0 {
+		// a full chunk is only sealed once more data arrives, so close always seals the last one
+		if len(e.buf) == StreamChunkSize {
+			if err := e.seal(false); err != nil {
+				return n, err
+			}
+		}
+		take := StreamChunkSize - len(e.buf)
+		if take > len(p) {
+			take = len(p)
+		}
+		e.buf = append(e.buf, p[:take]...)
+		p = p[take:]
+		n += take
+	}
+	return n, nil
+}
+
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+func (e *encryptWriter) seal(last bool) error {
+	ct := e.aead.Seal(nil, streamNonce(e.counter, last), e.buf, e.header)
+	if _, err := e.w.Write(ct); err != nil {
+		return err
+	}
+	e.counter++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+type decryptReader struct {
+	r         io.Reader
+	aead      cipher.AEAD
+	header    []byte
+	chunkSize int
+	counter   uint64
+	plain     []byte
+	done      bool
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if _, err := d.readChunk(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+// readChunk opens the next chunk into plain, returning the raw chunk
+func (d *decryptReader) readChunk() ([]byte, error) {
+	raw := make([]byte, d.chunkSize+d.aead.Overhead())
+	n, err := io.ReadFull(d.r, raw)
+	raw = raw[:n]
+	short := err == io.EOF || err == io.ErrUnexpectedEOF
+	if err != nil && !short {
+		return raw, err
+	}
+
+	// a short chunk must be the last, a full one may be
+	if !short {
+		if plain, err := d.aead.Open(nil, streamNonce(d.counter, false), raw, d.header); err == nil {
+			d.plain = plain
+			d.counter++
+			return raw, nil
+		}
+	}
+	plain, err := d.aead.Open(nil, streamNonce(d.counter, true), raw, d.header)
+	if err != nil {
+		return raw, ErrInvalidStream
+	}
+	d.plain = plain
+	d.counter++
+	d.done = true
+
+	// nothing may follow the last chunk
+	if !short {
+		extra := make([]byte, 1)
+		if n, _ := io.ReadFull(d.r, extra); n > 0 {
+			return raw, ErrInvalidStream
+		}
+	}
+	return raw, nil
+}
+
+type decryptReadSeeker struct {
+	r         io.ReadSeeker
+	aead      cipher.AEAD
+	header    []byte
+	chunkSize int64
+	chunks    int64
+	size      int64
+	offset    int64
+	index     int64
+	plain     []byte
+}
+
+func (d *decryptReadSeeker) Read(p []byte) (int, error) {
+	if d.offset >= d.size {
+		return 0, io.EOF
+	}
+	index := d.offset / d.chunkSize
+	if index != d.index {
+		if err := d.openChunk(index); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain[d.offset-index*d.chunkSize:])
+	d.offset += int64(n)
+	return n, nil
+}
+
+func (d *decryptReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += d.offset
+	case io.SeekEnd:
+		offset += d.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	d.offset = offset
+	return offset, nil
+}
+
+// openChunk reads and opens the chunk at index into plain
+func (d *decryptReadSeeker) openChunk(index int64) error {
+	full := d.chunkSize + int64(d.aead.Overhead())
+	if _, err := d.r.Seek(streamHeaderSize+index*full, io.SeekStart); err != nil {
+		return err
+	}
+	raw := make([]byte, full)
+	n, err := io.ReadFull(d.r, raw)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	last := index == d.chunks-1
+	plain, err := d.aead.Open(nil, streamNonce(uint64(index), last), raw[:n], d.header)
+	if err != nil {
+		return ErrInvalidStream
+	}
+	d.plain = plain
+	d.index = index
+	return nil
+}
+
+// streamAEAD derives a per-stream subkey from the key and salt
+func streamAEAD(key []byte, salt []byte) (cipher.AEAD, error) {
+	if len(key) != 44 {
+		return nil, errors.New("invalid key")
+	}
+	mac := hmac.New(sha256.New, key[:32])
+	mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// streamNonce is a big endian chunk counter followed by a last chunk flag
+func streamNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// isAESStream returns whether or not data begins with a stream header
+func isAESStream(data []byte) bool {
+	return len(data) >= streamHeaderSize && bytes.Equal(data[:len(streamMagic)], streamMagic)
+}
+
+// openLegacy decrypts a single-shot ciphertext whose first bytes were already read
+func openLegacy(head []byte, r io.Reader, key []byte) (io.Reader, error) {
+	rest, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := openAES(append(head, rest...), key)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(plain), nil
+}
@@ -1,10 +1,12 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"errors"
 	"github.com/segmentio/ksuid"
+	"io/ioutil"
 )
 
 // GenerateAESKey returns 44 random bytes, 32 for the key and 12 for a nonce.
@@ -39,10 +41,30 @@ func EncryptAES(bytes []byte, key []byte) ([]byte, error) {
 }
 
 // DecryptAES uses key (:32 key, 32:12 nonce) to perform AES-256 GCM decryption on bytes.
+// Chunked streams from NewAESEncryptWriter are detected and decrypted as well.
 func DecryptAES(bytes []byte, key []byte) ([]byte, error) {
 	if len(key) != 44 {
 		return nil, errors.New("invalid key")
 	}
+	if isAESStream(bytes) {
+		if plain, err := decryptAESStream(bytes, key); err == nil {
+			return plain, nil
+		}
+	}
+	return openAES(bytes, key)
+}
+
+// decryptAESStream decrypts a chunked stream held in memory
+func decryptAESStream(data []byte, key []byte) ([]byte, error) {
+	r, err := NewAESDecryptReader(bytes.NewReader(data), key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// openAES performs single-shot AES-256 GCM decryption
+func openAES(bytes []byte, key []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key[:32])
 	if err != nil {
 		return nil, err
@@ -1,6 +1,10 @@
 package crypto
 
 import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"io/ioutil"
 	"testing"
 )
 
@@ -45,3 +49,160 @@ func TestDecryptAES(t *testing.T) {
 		t.Error("decrypt AES with bad key succeeded")
 	}
 }
+
+func TestAESStream(t *testing.T) {
+	key, err := GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize*3 + 7} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+		var ciph bytes.Buffer
+		if err := EncryptAESStream(bytes.NewReader(plain), &ciph, key); err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewAESDecryptReader(bytes.NewReader(ciph.Bytes()), key)
+		if err != nil {
+			t.Fatalf("decrypt stream of %d bytes failed: %s", size, err)
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("read stream of %d bytes failed: %s", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("decrypt stream of %d bytes got bad plaintext", size)
+		}
+		got, err = DecryptAES(ciph.Bytes(), key)
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Errorf("decrypt AES of %d byte stream failed: %v", size, err)
+		}
+	}
+}
+
+func TestAESStreamSeek(t *testing.T) {
+	key, err := GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{0, 1, StreamChunkSize, StreamChunkSize*3 + 7} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+		var ciph bytes.Buffer
+		if err := EncryptAESStream(bytes.NewReader(plain), &ciph, key); err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewAESDecryptReadSeeker(bytes.NewReader(ciph.Bytes()), key)
+		if err != nil {
+			t.Fatalf("decrypt stream of %d bytes failed: %s", size, err)
+		}
+		end, err := r.Seek(0, io.SeekEnd)
+		if err != nil || end != int64(size) {
+			t.Fatalf("seek to end of %d byte stream got %d: %v", size, end, err)
+		}
+
+		// read a range spanning a chunk boundary, then start over from the beginning
+		start := size / 2
+		if start > StreamChunkSize-3 {
+			start = StreamChunkSize - 3
+		}
+		if _, err := r.Seek(int64(start), io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		part := make([]byte, 6)
+		n, _ := io.ReadFull(r, part)
+		if !bytes.Equal(part[:n], plain[start:start+n]) {
+			t.Errorf("ranged read of %d byte stream got bad plaintext", size)
+		}
+		r.Seek(0, io.SeekStart)
+		got, err := ioutil.ReadAll(r)
+		if err != nil || !bytes.Equal(got, plain) {
+			t.Errorf("read of %d byte stream after seeking failed: %v", size, err)
+		}
+	}
+
+	// legacy ciphertexts can seek too
+	ciph, err := EncryptAES(symmetricTestData.plaintext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewAESDecryptReadSeeker(bytes.NewReader(ciph), key)
+	if err != nil {
+		t.Fatalf("decrypt legacy ciphertext failed: %s", err)
+	}
+	r.Seek(4, io.SeekStart)
+	got, err := ioutil.ReadAll(r)
+	if err != nil || !bytes.Equal(got, symmetricTestData.plaintext[4:]) {
+		t.Errorf("seek in legacy ciphertext failed: %v", err)
+	}
+
+	// the last chunk must still be marked as such
+	plain := make([]byte, StreamChunkSize*2+100)
+	var stream bytes.Buffer
+	if err := EncryptAESStream(bytes.NewReader(plain), &stream, key); err != nil {
+		t.Fatal(err)
+	}
+	truncated := stream.Bytes()[:stream.Len()-116]
+	if r, err = NewAESDecryptReadSeeker(bytes.NewReader(truncated), key); err == nil {
+		_, err = ioutil.ReadAll(r)
+	}
+	if err == nil {
+		t.Error("truncated stream should not decrypt")
+	}
+}
+
+func TestAESStreamTampered(t *testing.T) {
+	key, err := GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := make([]byte, StreamChunkSize*2+100)
+	var ciph bytes.Buffer
+	if err := EncryptAESStream(bytes.NewReader(plain), &ciph, key); err != nil {
+		t.Fatal(err)
+	}
+	data := ciph.Bytes()
+
+	// drop the last chunk
+	truncated := data[:len(data)-116]
+	r, err := NewAESDecryptReader(bytes.NewReader(truncated), key)
+	if err == nil {
+		_, err = ioutil.ReadAll(r)
+	}
+	if err == nil {
+		t.Error("truncated stream should not decrypt")
+	}
+
+	// flip a bit in the second chunk
+	flipped := append([]byte{}, data...)
+	flipped[len(flipped)-200] ^= 1
+	r, err = NewAESDecryptReader(bytes.NewReader(flipped), key)
+	if err == nil {
+		_, err = ioutil.ReadAll(r)
+	}
+	if err == nil {
+		t.Error("tampered stream should not decrypt")
+	}
+}
+
+func TestAESStreamLegacy(t *testing.T) {
+	key, err := GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciph, err := EncryptAES(symmetricTestData.plaintext, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewAESDecryptReader(bytes.NewReader(ciph), key)
+	if err != nil {
+		t.Fatalf("decrypt legacy ciphertext failed: %s", err)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, symmetricTestData.plaintext) {
+		t.Error("decrypt legacy ciphertext got bad plaintext")
+	}
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/asticode/go-astilectron"
 	"github.com/asticode/go-astilectron-bootstrap"
 	"github.com/asticode/go-astilog"
 	"github.com/skip2/go-qrcode"
-	"math/rand"
+	"github.com/textileio/textile-go/wallet"
 )
 
 // handleMessages handles messages
@@ -17,32 +19,94 @@ func handleMessages(iw *astilectron.Window, m bootstrap.MessageIn) (payload inte
 		astilog.Info("PAIRING STARTED")
 		astilog.Info("GENERATING QR CODE")
 
-		// create a random confirmation code
-		code := fmt.Sprintf("%04d", rand.Int63n(1e4))
-
 		// get our own public key
-		pk, err := textile.Wallet.GetIPFSPubKeyString()
+		pk, err := textile.Wallet.GetIPFSPubKey()
+		if err != nil {
+			astilog.Errorf("public key generation failed: %s", err)
+			return nil, err
+		}
+		pks, err := textile.Wallet.GetIPFSPubKeyString()
 		if err != nil {
 			astilog.Errorf("public key generation failed: %s", err)
 			return nil, err
 		}
 
+		// create a pairing request with a random confirmation code
+		req, err := wallet.NewPairingRequest(pk, wallet.DefaultPairingTTL)
+		if err != nil {
+			astilog.Errorf("pairing request failed: %s", err)
+			return nil, err
+		}
+
 		// create a qr code
-		url := fmt.Sprintf("https://www.textile.io/clients?code=%s&key=%s", code, pk)
+		url := fmt.Sprintf("https://www.textile.io/clients?code=%s&key=%s", req.Code, pks)
 		png, err := qrcode.Encode(url, qrcode.Medium, 256)
 		if err != nil {
 			astilog.Errorf("qr generation failed: %s", err)
 			return nil, err
 		}
 
+		// sub to own peer id for pairing setup and wait
+		go func() {
+			pairing, err := textile.Wallet.WaitForInvite(req)
+			if err != nil {
+				astilog.Errorf("pairing failed: %s", err)
+				sendData(iw, "onboard.failed", map[string]interface{}{
+					"error": err.Error(),
+				})
+				return
+			}
+			setPendingPairing(pairing)
+
+			// ask the user to compare codes with the phone
+			sendData(iw, "onboard.confirm", map[string]interface{}{
+				"sas": pairing.SAS,
+			})
+		}()
+
 		// pass the qr code and info back to app
 		return map[string]interface{}{
-			"png":  base64.StdEncoding.EncodeToString(png),
-			"code": code,
-			"url":  url,
-			"key":  pk,
+			"png":     base64.StdEncoding.EncodeToString(png),
+			"code":    req.Code,
+			"url":     url,
+			"key":     pks,
+			"expires": req.Expires.Unix(),
 		}, nil
 
+	case "pair.confirm":
+		astilog.Info("GOT PAIRING CONFIRMATION")
+		pairing := takePendingPairing()
+		if pairing == nil {
+			return nil, errors.New("no pairing to confirm")
+		}
+
+		// the user has to see the same code on both devices
+		var confirmed bool
+		if err := json.Unmarshal(m.Payload, &confirmed); err != nil {
+			return nil, err
+		}
+		if !confirmed {
+			astilog.Info("PAIRING REJECTED")
+			return map[string]interface{}{}, nil
+		}
+		thrd, err := textile.Wallet.AcceptPairing(pairing)
+		if err != nil {
+			astilog.Errorf("pairing failed: %s", err)
+			return nil, err
+		}
+		mobileThread = thrd
+
+		// let the app know we're done pairing
+		sendData(iw, "onboard.complete", map[string]interface{}{})
+
+		// and that we're ready to go
+		sendData(iw, "sync.ready", map[string]interface{}{
+			"html": getPhotosHTML(),
+		})
+
+		// return empty response
+		return map[string]interface{}{}, nil
+
 	case "sync.start":
 		astilog.Info("GOT START SYNC MESSAGE")
 
@@ -4,11 +4,35 @@ import (
 	"fmt"
 	"github.com/asticode/go-astilectron"
 	"github.com/asticode/go-astilog"
+	"github.com/textileio/textile-go/core"
+	"github.com/textileio/textile-go/wallet"
 	"github.com/textileio/textile-go/wallet/thread"
+	"sync"
 )
 
 var mobileThread *thread.Thread
 
+// pendingPairing is a verified invite waiting for the user to compare codes,
+// set by the invite goroutine and taken by the message handler
+var pendingPairing *wallet.Pairing
+var pendingPairingMux sync.Mutex
+
+// setPendingPairing holds a verified invite until the user confirms it
+func setPendingPairing(pairing *wallet.Pairing) {
+	pendingPairingMux.Lock()
+	defer pendingPairingMux.Unlock()
+	pendingPairing = pairing
+}
+
+// takePendingPairing returns and clears the pending invite, if any
+func takePendingPairing() *wallet.Pairing {
+	pendingPairingMux.Lock()
+	defer pendingPairingMux.Unlock()
+	pairing := pendingPairing
+	pendingPairing = nil
+	return pairing
+}
+
 func start(_ *astilectron.Astilectron, iw *astilectron.Window, _ *astilectron.Menu, _ *astilectron.Tray, _ *astilectron.Menu) error {
 	astilog.Info("TEXTILE STARTED")
 
@@ -32,26 +56,6 @@ func start(_ *astilectron.Astilectron, iw *astilectron.Window, _ *astilectron.Me
 	} else {
 		// otherwise, start onboaring
 		astilog.Info("COULD NOT FIND MOBILE THREAD")
-		astilog.Info("STARTING PAIRING")
-
-		go func() {
-			// sub to own peer id for pairing setup and wait
-			textile.Wallet.WaitForInvite()
-
-			mobileThread := textile.Wallet.GetThreadByName("mobile")
-			if mobileThread == nil {
-				astilog.Error("failed to create mobile album")
-				return
-			}
-
-			// let the app know we're done pairing
-			sendMessage(iw, "onboard.complete")
-
-			// and that we're ready to go
-			sendData(iw, "sync.ready", map[string]interface{}{
-				"html": getPhotosHTML(),
-			})
-		}()
 		sendMessage(iw, "onboard.start")
 	}
 
@@ -80,9 +84,21 @@ func joinRoom(iw *astilectron.Window) error {
 func getPhotosHTML() string {
 	var html string
 	for _, block := range mobileThread.Blocks("", -1) {
-		ph := fmt.Sprintf("%s/ipfs/%s/photo?block=%s", gateway, block.Target, block.Id)
-		th := fmt.Sprintf("%s/ipfs/%s/thumb?block=%s", gateway, block.Target, block.Id)
-		md := fmt.Sprintf("%s/ipfs/%s/meta?block=%s", gateway, block.Target, block.Id)
+		ph, err := textile.SignGatewayURL(block.Id, "photo", core.DefaultGatewayURLTTL)
+		if err != nil {
+			astilog.Errorf("sign photo url failed: %s", err)
+			continue
+		}
+		th, err := textile.SignGatewayURL(block.Id, "thumb", core.DefaultGatewayURLTTL)
+		if err != nil {
+			astilog.Errorf("sign thumb url failed: %s", err)
+			continue
+		}
+		md, err := textile.SignGatewayURL(block.Id, "meta", core.DefaultGatewayURLTTL)
+		if err != nil {
+			astilog.Errorf("sign meta url failed: %s", err)
+			continue
+		}
 		img := fmt.Sprintf("<img src=\"%s\" />", th)
 		html += fmt.Sprintf("<div id=\"%s\" class=\"grid-item\" ondragstart=\"imageDragStart(event);\" draggable=\"true\" data-url=\"%s\" data-meta=\"%s\">%s</div>", block.Id, ph, md, img)
 	}
@@ -30,6 +30,20 @@ let textile = {
     })
   },
 
+  confirmPairing: function (confirmed) {
+    console.debug("SENDING MESSAGE:", "pair.confirm")
+    astilectron.sendMessage({name: "pair.confirm", payload: confirmed}, function (message) {
+      if (message.name === "error") {
+        asticode.notifier.error("Pairing failed")
+        textile.pair()
+        return
+      }
+      if (!confirmed) {
+        textile.pair()
+      }
+    })
+  },
+
   start: function () {
 /** @namespace astilectron.sendMessage **/
     astilectron.sendMessage({name: "sync.start", payload: ""}, function (message) {
@@ -86,10 +100,22 @@ let textile = {
           textile.pair()
           break
 
+        // phone sent a valid invite, the user has to compare codes before we join
+        case "onboard.confirm":
+          textile.confirmPairing(window.confirm("Does your phone show " + message.sas + "?"))
+          break
+
         // done onboarding, we should now have a room subscription
         case "onboard.complete":
+          asticode.notifier.info("Paired!")
           hideOnboarding()
           break
+
+        // pairing expired or was rejected, start over
+        case "onboard.failed":
+          asticode.notifier.error("Pairing failed: " + message.error)
+          textile.pair()
+          break
       }
     })
   },
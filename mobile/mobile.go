@@ -1,19 +1,21 @@
 package mobile
 
 import (
+	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/op/go-logging"
 	"github.com/textileio/textile-go/central/models"
 	tcore "github.com/textileio/textile-go/core"
-	"github.com/textileio/textile-go/crypto"
-	"github.com/textileio/textile-go/net"
 	"github.com/textileio/textile-go/repo"
 	"github.com/textileio/textile-go/wallet"
+	"github.com/textileio/textile-go/wallet/model"
 	"github.com/textileio/textile-go/wallet/thread"
-	"gx/ipfs/QmZoWKhxUmZ2seW4BzX6fJkNR8hh9PsGModr7q171yq2SS/go-libp2p-peer"
 	libp2pc "gx/ipfs/QmaPbCnUMBohSGo3KnxEa2bHqyJVVeEEcwtqJAYxerieBo/go-libp2p-crypto"
+	"io"
+	"time"
 )
 
 var log = logging.MustGetLogger("mobile")
@@ -44,14 +46,19 @@ type Messenger interface {
 type Wrapper struct {
 	RepoPath  string
 	messenger Messenger
+	uploads   chan model.UploadUpdate
 }
 
 // NodeConfig is used to configure the mobile node
 type NodeConfig struct {
-	RepoPath      string
-	CentralApiURL string
-	LogLevel      string
-	LogFiles      bool
+	RepoPath          string
+	CentralApiURL     string
+	LogLevel          string
+	LogFiles          bool
+	DatastorePassword string
+	MasterMnemonic    string
+	MasterPassphrase  string
+	UploadChunkSize   int64
 }
 
 // NewNode is the mobile entry point for creating a node
@@ -70,19 +77,48 @@ type Blocks struct {
 	Items []repo.Block `json:"items"`
 }
 
+// SearchResults is a wrapper around a list of search entries
+type SearchResults struct {
+	Items []repo.SearchEntry `json:"items"`
+}
+
+// PhotoLocations is a wrapper around a list of photo locations
+type PhotoLocations struct {
+	Items []repo.PhotoLocation `json:"items"`
+}
+
+// SimilarPhotos is a wrapper around a list of similar photos
+type SimilarPhotos struct {
+	Items []model.SimilarPhoto `json:"items"`
+}
+
+// PhotoClusters is a wrapper around a list of photo clusters
+type PhotoClusters struct {
+	Items []model.PhotoCluster `json:"items"`
+}
+
 // Create a gomobile compatible wrapper around TextileNode
 func (m *Mobile) NewNode(config *NodeConfig, messenger Messenger) (*Wrapper, error) {
 	ll, err := logging.LogLevel(config.LogLevel)
 	if err != nil {
 		ll = logging.INFO
 	}
+	// an empty mnemonic creates a new one, see Mnemonic
+	var mnemonic *string
+	if config.MasterMnemonic != "" {
+		mnemonic = &config.MasterMnemonic
+	}
 	cconfig := tcore.NodeConfig{
 		LogLevel: ll,
 		LogFiles: config.LogFiles,
 		WalletConfig: wallet.Config{
-			RepoPath:   config.RepoPath,
-			CentralAPI: config.CentralApiURL,
-			IsMobile:   true,
+			RepoPath:          config.RepoPath,
+			CentralAPI:        config.CentralApiURL,
+			IsMobile:          true,
+			DatastorePassword: config.DatastorePassword,
+			MasterMnemonic:    mnemonic,
+			MasterPassphrase:  config.MasterPassphrase,
+			UploadChunkSize:   config.UploadChunkSize,
 		},
 	}
 	node, err := tcore.NewNode(cconfig)
@@ -104,6 +140,9 @@ func (w *Wrapper) Start() error {
 		return err
 	}
 
+	// pass upload progress to the UI
+	w.subscribeUploads()
+
 	go func() {
 		<-online
 		// join existing threads
@@ -129,6 +168,37 @@ func (w *Wrapper) Stop() error {
 	return nil
 }
 
+// IsLocked returns whether or not the datastore is waiting for a password
+func (w *Wrapper) IsLocked() bool {
+	return tcore.Node.Wallet.Locked()
+}
+
+// Unlock opens an encrypted datastore, call before Start
+func (w *Wrapper) Unlock(password string) error {
+	return tcore.Node.Wallet.Unlock(password)
+}
+
+// Lock stops the node and closes an encrypted datastore
+func (w *Wrapper) Lock() error {
+	if err := w.Stop(); err != nil {
+		return err
+	}
+	return tcore.Node.Wallet.Lock()
+}
+
+// EncryptDatastore stops the node and encrypts the datastore with password
+func (w *Wrapper) EncryptDatastore(password string) error {
+	if err := w.Stop(); err != nil {
+		return err
+	}
+	return tcore.Node.Wallet.EncryptDatastore(password)
+}
+
+// ChangeDatastorePassword re-keys an encrypted datastore, the current password must be given
+func (w *Wrapper) ChangeDatastorePassword(current string, password string) error {
+	return tcore.Node.Wallet.ChangeDatastorePassword(current, password)
+}
+
 // SignUpWithEmail creates an email based registration and calls core signup
 func (w *Wrapper) SignUpWithEmail(username string, password string, email string, referral string) error {
 	// build registration
@@ -170,6 +240,11 @@ func (w *Wrapper) GetId() (string, error) {
 	return tcore.Node.Wallet.GetId()
 }
 
+// Mnemonic returns the master mnemonic phrase of a newly created repo, empty otherwise
+func (w *Wrapper) Mnemonic() string {
+	return tcore.Node.Wallet.Mnemonic()
+}
+
 // GetIPFSPeerId returns the wallet's ipfs peer id
 func (w *Wrapper) GetIPFSPeerId() (string, error) {
 	return tcore.Node.Wallet.GetIPFSPeerId()
@@ -185,21 +260,40 @@ func (w *Wrapper) GetAccessToken() (string, error) {
 	return tcore.Node.Wallet.GetAccessToken()
 }
 
-// AddThread adds a new thread with the given name
-func (w *Wrapper) AddThread(name string, mnemonic string) error {
-	var mnem *string
+// AddThread adds a new thread with the given name,
+// deriving its key from the master seed, if the repo has one, when no mnemonic is given.
+// The passphrase is optional and only used with a mnemonic.
+func (w *Wrapper) AddThread(name string, mnemonic string, passphrase string) error {
+	var err error
 	if mnemonic != "" {
-		mnem = &mnemonic
+		_, _, err = tcore.Node.Wallet.AddThreadWithMnemonic(name, &mnemonic, passphrase)
+	} else {
+		_, _, _, err = tcore.Node.Wallet.AddNewThread(name)
+	}
+	if err == wallet.ErrThreadExists {
+		return nil
 	}
-	_, _, err := tcore.Node.Wallet.AddThreadWithMnemonic(name, mnem)
+	return err
+}
+
+// RestoreThread re-adds a thread whose key was derived from the master seed at index
+func (w *Wrapper) RestoreThread(name string, index int) error {
+	_, err := tcore.Node.Wallet.RestoreThread(name, index)
 	if err == wallet.ErrThreadExists {
 		return nil
 	}
 	return err
 }
 
-// AddPhoto adds a photo by path and shares it to the default thread
-func (w *Wrapper) AddPhoto(path string, threadName string, caption string) (*net.MultipartRequest, error) {
+// RestoreThreads re-adds every derived thread in the published thread index
+func (w *Wrapper) RestoreThreads() error {
+	_, err := tcore.Node.Wallet.RestoreThreads()
+	return err
+}
+
+// AddPhoto adds a photo by path and shares it to the default thread,
+// a photo that was already added returns the existing result marked as a duplicate, without a remote request
+func (w *Wrapper) AddPhoto(path string, threadName string, caption string) (*model.AddResult, error) {
 	thrd := tcore.Node.Wallet.GetThreadByName(threadName)
 	if thrd == nil {
 		return nil, errors.New(fmt.Sprintf("could not find thread: %s", threadName))
@@ -208,51 +302,104 @@ func (w *Wrapper) AddPhoto(path string, threadName string, caption string) (*net
 	if err != nil {
 		return nil, err
 	}
-	shared, err := thrd.AddPhoto(added.Id, caption, added.Key)
+	shared, err := tcore.Node.Wallet.AddPhotoToThread(added.Id, added.Key, thrd, caption)
 	if err != nil {
 		return nil, err
 	}
 
-	// pin to remote
-	if err = shared.RemoteRequest.Send(tcore.Node.Wallet.GetCentralAPI()); err != nil {
-		return nil, err
+	// pin to remote when online
+	if !shared.Duplicate {
+		if err = tcore.Node.Wallet.QueueUpload(shared.RemoteRequest); err != nil {
+			return nil, err
+		}
 	}
 
 	// let the OS handle the large upload
-	return added.RemoteRequest, nil
+	return added, nil
 }
 
 // SharePhoto adds an existing photo to a new thread
 func (w *Wrapper) SharePhoto(id string, threadName string, caption string) (string, error) {
-	block, err := tcore.Node.Wallet.GetBlockByTarget(id)
+	toThread := tcore.Node.Wallet.GetThreadByName(threadName)
+	if toThread == nil {
+		return "", errors.New(fmt.Sprintf("could not find thread named %s", threadName))
+	}
+	shared, err := tcore.Node.Wallet.SharePhoto(id, toThread, caption)
 	if err != nil {
 		return "", err
 	}
-	fromThread := tcore.Node.Wallet.GetThread(block.ThreadPubKey)
-	if fromThread == nil {
-		return "", errors.New(fmt.Sprintf("could not find thread %s", block.ThreadPubKey))
+
+	// pin to remote when online
+	if !shared.Duplicate {
+		if err = tcore.Node.Wallet.QueueUpload(shared.RemoteRequest); err != nil {
+			return "", err
+		}
 	}
-	toThread := tcore.Node.Wallet.GetThreadByName(threadName)
-	if toThread == nil {
+
+	return shared.Id, nil
+}
+
+// EditPhoto adds a version of a photo in a thread with json encoded edits applied,
+// e.g. [{"op":"rotate","angle":90},{"op":"flip","axis":"h"}], returning the version's block id
+func (w *Wrapper) EditPhoto(id string, threadName string, edits string) (string, error) {
+	thrd := tcore.Node.Wallet.GetThreadByName(threadName)
+	if thrd == nil {
 		return "", errors.New(fmt.Sprintf("could not find thread named %s", threadName))
 	}
-	key, err := fromThread.Decrypt(block.TargetKey)
+	var list []model.Edit
+	if err := json.Unmarshal([]byte(edits), &list); err != nil {
+		return "", err
+	}
+	edited, err := tcore.Node.Wallet.EditPhoto(id, thrd, list)
 	if err != nil {
 		return "", err
 	}
 
-	// TODO: owner challenge
-	shared, err := toThread.AddPhoto(id, caption, key)
+	// pin to remote when online
+	if !edited.Duplicate {
+		if err = tcore.Node.Wallet.QueueUpload(edited.RemoteRequest); err != nil {
+			return "", err
+		}
+	}
+
+	return edited.Id, nil
+}
+
+// GetPhotoVersions returns the version blocks of a photo in a thread with json encoding, newest first
+func (w *Wrapper) GetPhotoVersions(id string, threadName string) (string, error) {
+	thrd := tcore.Node.Wallet.GetThreadByName(threadName)
+	if thrd == nil {
+		return "", errors.New(fmt.Sprintf("thread not found: %s", threadName))
+	}
+	jsonb, err := json.Marshal(&Blocks{thrd.Versions(id)})
 	if err != nil {
+		log.Errorf("error marshaling json: %s", err)
 		return "", err
 	}
+	return string(jsonb), nil
+}
 
-	// pin to remote
-	if err = shared.RemoteRequest.Send(tcore.Node.Wallet.GetCentralAPI()); err != nil {
-		return "", err
+// SetLocationPolicy sets how photo locations are shared to threads by default (keep, coarsen, strip),
+// grid is the coarsening size in degrees, or zero for the default
+func (w *Wrapper) SetLocationPolicy(policy string, grid float64) error {
+	lp, err := wallet.ParseLocationPolicy(policy)
+	if err != nil {
+		return err
 	}
+	return tcore.Node.Wallet.SetLocationPolicy(lp, grid)
+}
 
-	return shared.Id, nil
+// SetThreadLocationPolicy sets how photo locations are shared to a thread (keep, coarsen, strip)
+func (w *Wrapper) SetThreadLocationPolicy(threadName string, policy string, grid float64) error {
+	thrd := tcore.Node.Wallet.GetThreadByName(threadName)
+	if thrd == nil {
+		return errors.New(fmt.Sprintf("thread not found: %s", threadName))
+	}
+	lp, err := wallet.ParseLocationPolicy(policy)
+	if err != nil {
+		return err
+	}
+	return tcore.Node.Wallet.SetThreadLocationPolicy(thrd.Id, lp, grid)
 }
 
 // GetPhotoBlocks returns thread photo blocks with json encoding
@@ -277,6 +424,110 @@ func (w *Wrapper) GetPhotoBlocks(offsetId string, limit int, threadName string)
 	return string(jsonb), nil
 }
 
+// Search returns photos matching text in captions and meta data with json encoding,
+// optionally filtered by thread name, username, and a from / to range of unix seconds
+func (w *Wrapper) Search(text string, threadName string, username string, from int64, to int64, limit int) (string, error) {
+	query := &repo.SearchQuery{Text: text, Username: username, Limit: limit}
+	if threadName != "" {
+		thrd := tcore.Node.Wallet.GetThreadByName(threadName)
+		if thrd == nil {
+			return "", errors.New(fmt.Sprintf("thread not found: %s", threadName))
+		}
+		query.ThreadId = thrd.Id
+	}
+	if from > 0 {
+		query.From = time.Unix(from, 0)
+	}
+	if to > 0 {
+		query.To = time.Unix(to, 0)
+	}
+	entries, err := tcore.Node.Wallet.Search(query)
+	if err != nil {
+		return "", err
+	}
+	jsonb, err := json.Marshal(&SearchResults{entries})
+	if err != nil {
+		log.Errorf("error marshaling json: %s", err)
+		return "", err
+	}
+	return string(jsonb), nil
+}
+
+// SimilarPhotos returns photos that look like a photo with json encoding, closest first
+func (w *Wrapper) SimilarPhotos(id string, threshold int) (string, error) {
+	similar, err := tcore.Node.Wallet.SimilarPhotos(id, threshold)
+	if err != nil {
+		return "", err
+	}
+	jsonb, err := json.Marshal(&SimilarPhotos{similar})
+	if err != nil {
+		log.Errorf("error marshaling json: %s", err)
+		return "", err
+	}
+	return string(jsonb), nil
+}
+
+// PhotosInBounds returns photo locations inside a bounding box with json encoding,
+// optionally limited to a thread
+func (w *Wrapper) PhotosInBounds(minLat float64, minLon float64, maxLat float64, maxLon float64, threadName string) (string, error) {
+	threads, err := threadIdsForName(threadName)
+	if err != nil {
+		return "", err
+	}
+	locations, err := tcore.Node.Wallet.PhotosInBounds(minLat, minLon, maxLat, maxLon, threads)
+	if err != nil {
+		return "", err
+	}
+	jsonb, err := json.Marshal(&PhotoLocations{locations})
+	if err != nil {
+		log.Errorf("error marshaling json: %s", err)
+		return "", err
+	}
+	return string(jsonb), nil
+}
+
+// PhotoClusters returns photo counts per map tile inside a bounding box with json encoding,
+// optionally limited to a thread
+func (w *Wrapper) PhotoClusters(zoom int, minLat float64, minLon float64, maxLat float64, maxLon float64, threadName string) (string, error) {
+	threads, err := threadIdsForName(threadName)
+	if err != nil {
+		return "", err
+	}
+	clusters, err := tcore.Node.Wallet.PhotoClusters(zoom, minLat, minLon, maxLat, maxLon, threads)
+	if err != nil {
+		return "", err
+	}
+	jsonb, err := json.Marshal(&PhotoClusters{clusters})
+	if err != nil {
+		log.Errorf("error marshaling json: %s", err)
+		return "", err
+	}
+	return string(jsonb), nil
+}
+
+// Timeline returns photos grouped by the day, month, or year they were taken with json encoding,
+// optionally limited to a thread. Pass the result's next cursor to get older groups.
+func (w *Wrapper) Timeline(period string, cursor string, limit int, threadName string) (string, error) {
+	tp, err := wallet.ParseTimelinePeriod(period)
+	if err != nil {
+		return "", err
+	}
+	threads, err := threadIdsForName(threadName)
+	if err != nil {
+		return "", err
+	}
+	page, err := tcore.Node.Wallet.Timeline(tp, cursor, limit, 0, threads)
+	if err != nil {
+		return "", err
+	}
+	jsonb, err := json.Marshal(page)
+	if err != nil {
+		log.Errorf("error marshaling json: %s", err)
+		return "", err
+	}
+	return string(jsonb), nil
+}
+
 // GetBlockData calls GetBlockDataBase64 on a thread
 func (w *Wrapper) GetBlockData(id string, path string) (string, error) {
 	block, err := tcore.Node.Wallet.GetBlock(id)
@@ -311,9 +562,51 @@ func (w *Wrapper) GetFileData(id string, path string) (string, error) {
 	return thrd.GetFileDataBase64(fmt.Sprintf("%s/%s", id, path), block)
 }
 
-// PairDevice publishes this node's secret key to another node,
-// which is listening at it's own peer id
-func (w *Wrapper) PairDevice(pkb64 string) (string, error) {
+// GetRendition returns a named thumbnail rendition of a photo as base64
+func (w *Wrapper) GetRendition(id string, name string) (string, error) {
+	block, err := tcore.Node.Wallet.GetBlockByTarget(id)
+	if err != nil {
+		log.Errorf("could not find block for target %s: %s", id, err)
+		return "", err
+	}
+	thrd := tcore.Node.Wallet.GetThread(block.ThreadPubKey)
+	if thrd == nil {
+		err := errors.New(fmt.Sprintf("could not find thread: %s", block.ThreadPubKey))
+		log.Error(err.Error())
+		return "", err
+	}
+	reader, err := thrd.GetRendition(id, name, block)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	// encode as it's decrypted
+	var buf bytes.Buffer
+	encoder := base64.NewEncoder(base64.StdEncoding, &buf)
+	if _, err := io.Copy(encoder, reader); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// SignGatewayURL returns a short-lived gateway url for a file of a block's target,
+// a zero ttl in seconds uses the default
+func (w *Wrapper) SignGatewayURL(blockId string, file string, ttl int) (string, error) {
+	duration := tcore.DefaultGatewayURLTTL
+	if ttl > 0 {
+		duration = time.Second * time.Duration(ttl)
+	}
+	return tcore.Node.SignGatewayURL(blockId, file, duration)
+}
+
+// PairDevice sends an invite to join the default thread to another node,
+// which is listening at it's own peer id with a pairing request for code.
+// Returns the short authentication string to compare with the other device.
+func (w *Wrapper) PairDevice(pkb64 string, code string) (string, error) {
 	if !tcore.Node.Wallet.Online() {
 		return "", wallet.ErrOffline
 	}
@@ -335,31 +628,57 @@ func (w *Wrapper) PairDevice(pkb64 string) (string, error) {
 		log.Error(err.Error())
 		return "", err
 	}
-	// encypt thread secret key with the desktop's pub key
-	secret, err := defaultThread.PrivKey.Bytes()
-	if err != nil {
-		return "", err
-	}
-	secretcypher, err := crypto.Encrypt(pk, secret)
+
+	sas, err := tcore.Node.Wallet.PairDevice(pk, code, defaultThread)
 	if err != nil {
+		log.Errorf("pairing failed: %s", err)
 		return "", err
 	}
+	log.Info("paired with device")
 
-	// get the topic to pair with from the pub key
-	peerID, err := peer.IDFromPublicKey(pk)
+	return sas, nil
+}
+
+// GetUploads returns queued and failed uploads with json encoding
+func (w *Wrapper) GetUploads() (string, error) {
+	uploads, err := tcore.Node.Wallet.Uploads()
 	if err != nil {
 		return "", err
 	}
-	topic := peerID.Pretty()
-
-	// finally, publish the encrypted phrase
-	err = tcore.Node.Wallet.Publish(topic, secretcypher)
+	if uploads == nil {
+		uploads = make([]repo.Upload, 0)
+	}
+	jsonb, err := json.Marshal(uploads)
 	if err != nil {
 		return "", err
 	}
-	log.Infof("published key phrase to device: %s", topic)
+	return string(jsonb), nil
+}
 
-	return topic, nil
+// RetryUpload puts a failed upload back in the queue
+func (w *Wrapper) RetryUpload(id string) error {
+	return tcore.Node.Wallet.RetryUpload(id)
+}
+
+// subscribeUploads passes upload updates to messenger, once per wrapper
+func (w *Wrapper) subscribeUploads() {
+	if w.uploads != nil {
+		return
+	}
+	w.uploads = make(chan model.UploadUpdate, 10)
+	tcore.Node.Wallet.SubscribeUploads(w.uploads)
+	go func() {
+		for update := range w.uploads {
+			w.messenger.Notify(newEvent("onUploadUpdate", map[string]interface{}{
+				"id":       update.Id,
+				"status":   update.Status,
+				"attempts": update.Attempts,
+				"sent":     update.Sent,
+				"total":    update.Total,
+				"error":    update.Error,
+			}))
+		}
+	}()
 }
 
 // subscribe to thread and pass updates to messenger
@@ -382,3 +701,15 @@ func (w *Wrapper) subscribe(thrd *thread.Thread) {
 		}
 	}()
 }
+
+// threadIdsForName returns the id of a named thread, or nil for all threads if name is empty
+func threadIdsForName(name string) ([]string, error) {
+	if name == "" {
+		return nil, nil
+	}
+	thrd := tcore.Node.Wallet.GetThreadByName(name)
+	if thrd == nil {
+		return nil, errors.New(fmt.Sprintf("thread not found: %s", name))
+	}
+	return []string{thrd.Id}, nil
+}
@@ -118,35 +118,46 @@ func TestWrapper_GetAccessToken(t *testing.T) {
 }
 
 func TestWrapper_AddThread(t *testing.T) {
-	if err := wrapper.AddThread("default", ""); err != nil {
+	if err := wrapper.AddThread("default", "", ""); err != nil {
 		t.Errorf("add thread failed: %s", err)
 	}
 }
 
 func TestWrapper_AddThreadAgain(t *testing.T) {
-	if err := wrapper.AddThread("default", ""); err != nil {
+	if err := wrapper.AddThread("default", "", ""); err != nil {
 		t.Errorf("add thread again failed: %s", err)
 	}
 }
 
 func TestWrapper_AddPhoto(t *testing.T) {
-	mr, err := wrapper.AddPhoto("testdata/image.jpg", "default", "howdy")
+	added, err := wrapper.AddPhoto("testdata/image.jpg", "default", "howdy")
 	if err != nil {
 		t.Errorf("add photo failed: %s", err)
 		return
 	}
-	if len(mr.Boundary) == 0 {
+	if added.Duplicate || added.RemoteRequest == nil || len(added.RemoteRequest.Boundary) == 0 {
 		t.Errorf("add photo got bad hash")
+		return
 	}
-	addedPhotoId = mr.Boundary
-	err = os.Remove("testdata/.ipfs/tmp/" + mr.Boundary)
+	addedPhotoId = added.RemoteRequest.Boundary
+	err = os.Remove("testdata/.ipfs/tmp/" + added.RemoteRequest.Boundary)
 	if err != nil {
 		t.Errorf("error unlinking test multipart file: %s", err)
 	}
+
+	// adding it again returns the existing photo
+	again, err := wrapper.AddPhoto("testdata/image.jpg", "default", "howdy")
+	if err != nil {
+		t.Errorf("add duplicate photo failed: %s", err)
+		return
+	}
+	if !again.Duplicate || again.Id != addedPhotoId {
+		t.Error("add duplicate photo should return the existing photo")
+	}
 }
 
 func TestWrapper_SharePhoto(t *testing.T) {
-	err := wrapper.AddThread("test", "")
+	err := wrapper.AddThread("test", "", "")
 	if err != nil {
 		t.Errorf("add test thread failed: %s", err)
 		return
@@ -216,7 +227,7 @@ func TestWrapper_GetFileData(t *testing.T) {
 //	}
 //	ps := base64.StdEncoding.EncodeToString(pb)
 //
-//	_, err = wrapper.PairDevice(ps)
+//	_, err = wrapper.PairDevice(ps, "0000")
 //	if err != nil {
 //		t.Errorf("pair device failed: %s", err)
 //	}
@@ -0,0 +1,45 @@
+package net
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBody bounds how much of a failed response is kept
+const maxErrorBody = 1024
+
+// HTTPError is returned when the server responds with an unexpected status
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("upload failed: %s", e.Status)
+	}
+	return fmt.Sprintf("upload failed: %s: %s", e.Status, e.Body)
+}
+
+// Temporary returns whether or not the request may succeed if retried,
+// unauthorized is included because the access token may be refreshed
+func (e *HTTPError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return e.StatusCode >= 500
+}
+
+func newHTTPError(resp *http.Response) *HTTPError {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return &HTTPError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       strings.TrimSpace(string(body)),
+	}
+}
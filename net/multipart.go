@@ -1,15 +1,52 @@
 package net
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 var nl = "\r\n"
 
+// StatusResumeIncomplete is returned by the server while a chunked upload is missing ranges
+const StatusResumeIncomplete = 308
+
+// DefaultChunkSize is a reasonable chunk size for flaky mobile connections
+const DefaultChunkSize int64 = 1 << 20
+
+var ErrUploadStalled = errors.New("upload is not making progress")
+var ErrResumeUnsupported = errors.New("server does not support resumable uploads")
+
+// ProgressFunc is called as payload bytes are sent
+type ProgressFunc func(sent int64, total int64)
+
+// SendOptions configures how a payload is sent
+type SendOptions struct {
+	// Token is sent as a bearer token if set
+	Token string
+	// ChunkSize splits the payload into resumable ranges, zero sends it in one request.
+	// Servers which don't answer the range probe with StatusResumeIncomplete get one request.
+	ChunkSize int64
+	// Progress is called as bytes are sent
+	Progress ProgressFunc
+	// Client defaults to http.DefaultClient
+	Client *http.Client
+}
+
+func (o *SendOptions) client() *http.Client {
+	if o.Client != nil {
+		return o.Client
+	}
+	return http.DefaultClient
+}
+
 type MultipartRequest struct {
 	Boundary    string
 	PayloadPath string
@@ -21,6 +58,11 @@ func (m *MultipartRequest) Init(dir string, boundary string) {
 }
 
 func (m *MultipartRequest) AddFile(b []byte, fname string) error {
+	return m.AddFileReader(bytes.NewReader(b), fname)
+}
+
+// AddFileReader appends a file part with everything from r, without holding it in memory
+func (m *MultipartRequest) AddFileReader(r io.Reader, fname string) error {
 	// create file if not yet exists
 	file, err := os.OpenFile(m.PayloadPath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
 	if err != nil {
@@ -41,7 +83,7 @@ func (m *MultipartRequest) AddFile(b []byte, fname string) error {
 	if _, err = file.Write([]byte(header)); err != nil {
 		return err
 	}
-	if _, err = file.Write(b); err != nil {
+	if _, err = io.Copy(file, r); err != nil {
 		return err
 	}
 	if _, err = file.Write([]byte(nl)); err != nil {
@@ -71,29 +113,160 @@ func (m *MultipartRequest) Finish() error {
 	return nil
 }
 
-func (m *MultipartRequest) Send(url string) error {
+// Send posts the payload to url, in chunks if opts.ChunkSize is set
+func (m *MultipartRequest) Send(url string, opts *SendOptions) error {
+	if opts == nil {
+		opts = &SendOptions{}
+	}
+
 	// open file, will error if not first inited
 	file, err := os.Open(m.PayloadPath)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	total := info.Size()
+
+	if opts.ChunkSize > 0 && total > opts.ChunkSize {
+		offset, err := m.queryOffset(url, total, opts)
+		if err == nil {
+			return m.sendChunked(url, file, offset, total, opts)
+		}
+		if err != ErrResumeUnsupported {
+			return err
+		}
+	}
+	req, err := m.newRequest(url, &progressReader{r: file, total: total, progress: opts.Progress}, opts)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = total
+	resp, err := opts.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newHTTPError(resp)
+	}
+	return nil
+}
+
+// sendChunked uploads the payload in ranges, resuming from what the server already has
+func (m *MultipartRequest) sendChunked(url string, file *os.File, offset int64, total int64, opts *SendOptions) error {
+	var done bool
+	for !done {
+		if offset >= total {
+			return ErrUploadStalled
+		}
+		end := offset + opts.ChunkSize
+		if end > total {
+			end = total
+		}
+		section := io.NewSectionReader(file, offset, end-offset)
+		body := &progressReader{r: section, sent: offset, total: total, progress: opts.Progress}
+		req, err := m.newRequest(url, body, opts)
+		if err != nil {
+			return err
+		}
+		req.ContentLength = end - offset
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, end-1, total))
+		next, fin, err := m.doChunk(req, end == total, opts)
+		if err != nil {
+			return err
+		}
+		if !fin && next <= offset {
+			return ErrUploadStalled
+		}
+		offset, done = next, fin
+	}
+	return nil
+}
+
+// queryOffset asks the server how much of the payload it has already received,
+// only a StatusResumeIncomplete reply means the server speaks the range protocol
+func (m *MultipartRequest) queryOffset(url string, total int64, opts *SendOptions) (int64, error) {
+	req, err := m.newRequest(url, nil, opts)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", total))
+	next, _, err := m.doChunk(req, false, opts)
+	return next, err
+}
+
+// doChunk sends a chunk request, returning the next offset and whether the upload is complete,
+// a success reply only completes the upload if the request carried the final range
+func (m *MultipartRequest) doChunk(req *http.Request, final bool, opts *SendOptions) (int64, bool, error) {
+	resp, err := opts.client().Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		io.Copy(ioutil.Discard, resp.Body)
+		if !final {
+			return 0, false, ErrResumeUnsupported
+		}
+		return 0, true, nil
+	case resp.StatusCode == StatusResumeIncomplete:
+		io.Copy(ioutil.Discard, resp.Body)
+		next, err := parseRange(resp.Header.Get("Range"))
+		return next, false, err
+	default:
+		return 0, false, newHTTPError(resp)
+	}
+}
+
+// newRequest creates a post request for the payload with auth headers
+func (m *MultipartRequest) newRequest(url string, body io.Reader, opts *SendOptions) (*http.Request, error) {
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
 
 	// include boundary in header
-	req, err := http.NewRequest("POST", url, file)
 	req.Header.Set("Content-Type", fmt.Sprintf("multipart/form-data; boundary=%s", m.Boundary))
+	if opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.Token)
+	}
+	return req, nil
+}
 
-	// make the request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// parseRange returns the offset following a "bytes=0-N" range header, no header means nothing was received
+func parseRange(header string) (int64, error) {
+	if header == "" {
+		return 0, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	if len(parts) != 2 {
+		return 0, errors.New(fmt.Sprintf("invalid range header: %s", header))
+	}
+	last, err := strconv.ParseInt(parts[1], 10, 64)
 	if err != nil {
-		return err
+		return 0, errors.New(fmt.Sprintf("invalid range header: %s", header))
 	}
-	defer resp.Body.Close()
+	return last + 1, nil
+}
 
-	fmt.Println("response Status:", resp.Status)
-	body, _ := ioutil.ReadAll(resp.Body)
-	fmt.Println("response Body:", string(body))
+// progressReader reports bytes read from the underlying reader
+type progressReader struct {
+	r        io.Reader
+	sent     int64
+	total    int64
+	progress ProgressFunc
+}
 
-	return nil
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.sent += int64(n)
+	if n > 0 && p.progress != nil {
+		p.progress(p.sent, p.total)
+	}
+	return n, err
 }
@@ -1,21 +1,210 @@
 package net_test
 
 import (
+	"bytes"
+	"fmt"
+	. "github.com/textileio/textile-go/net"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
 	"testing"
 )
 
+var dir string
+var request *MultipartRequest
+
 func TestMultipartRequest_Init(t *testing.T) {
-	// TODO
+	var err error
+	dir, err = ioutil.TempDir("", "textile_net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request = &MultipartRequest{}
+	request.Init(dir, "boundary")
+	if request.Boundary != "boundary" {
+		t.Errorf("init boundary failed: %s", request.Boundary)
+	}
+	if !strings.HasPrefix(request.PayloadPath, dir) {
+		t.Errorf("init payload path failed: %s", request.PayloadPath)
+	}
 }
 
 func TestMultipartRequest_AddFile(t *testing.T) {
-	// TODO
+	if err := request.AddFile(bytes.Repeat([]byte("a"), 1000), "photo"); err != nil {
+		t.Errorf("add file failed: %s", err)
+	}
+	if err := request.AddFile(bytes.Repeat([]byte("b"), 1000), "thumb"); err != nil {
+		t.Errorf("add file failed: %s", err)
+	}
 }
 
 func TestMultipartRequest_Finish(t *testing.T) {
-	// TODO
+	if err := request.Finish(); err != nil {
+		t.Errorf("finish failed: %s", err)
+	}
+	payload, err := ioutil.ReadFile(request.PayloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(payload, []byte("--boundary--\r\n")) {
+		t.Error("finish did not write footer")
+	}
 }
 
 func TestMultipartRequest_Send(t *testing.T) {
-	// TODO
+	payload, err := ioutil.ReadFile(request.PayloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("Content-Type") != "multipart/form-data; boundary=boundary" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if !bytes.Equal(body, payload) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	err = request.Send(ts.URL, &SendOptions{
+		Token: "token",
+		Progress: func(s int64, total int64) {
+			sent = s
+		},
+	})
+	if err != nil {
+		t.Errorf("send failed: %s", err)
+	}
+	if sent != int64(len(payload)) {
+		t.Errorf("progress reported %d of %d bytes", sent, len(payload))
+	}
+}
+
+func TestMultipartRequest_SendStatusError(t *testing.T) {
+	status := http.StatusUnauthorized
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, "nope")
+	}))
+	defer ts.Close()
+
+	err := request.Send(ts.URL, nil)
+	herr, ok := err.(*HTTPError)
+	if !ok {
+		t.Fatalf("send returned wrong error: %v", err)
+	}
+	if herr.StatusCode != status || herr.Body != "nope" || !herr.Temporary() {
+		t.Errorf("send returned bad error: %s", herr)
+	}
+
+	status = http.StatusBadRequest
+	err = request.Send(ts.URL, nil)
+	if herr, ok := err.(*HTTPError); !ok || herr.Temporary() {
+		t.Errorf("send returned wrong error for bad request: %v", err)
+	}
+}
+
+func TestMultipartRequest_SendChunked(t *testing.T) {
+	payload, err := ioutil.ReadFile(request.PayloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := len(payload)
+
+	// the server drops the connection once, mid-upload
+	var received []byte
+	dropped := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cr := r.Header.Get("Content-Range")
+		if cr == fmt.Sprintf("bytes */%d", total) {
+			if len(received) > 0 {
+				w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(received)-1))
+			}
+			w.WriteHeader(StatusResumeIncomplete)
+			return
+		}
+		var start, end, size int
+		if _, err := fmt.Sscanf(cr, "bytes %d-%d/%d", &start, &end, &size); err != nil || start != len(received) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if !dropped && start > 0 {
+			dropped = true
+			hj, _ := w.(http.Hijacker)
+			conn, _, _ := hj.Hijack()
+			conn.Close()
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		received = append(received, body...)
+		if len(received) == size {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		w.Header().Set("Range", "bytes=0-"+strconv.Itoa(len(received)-1))
+		w.WriteHeader(StatusResumeIncomplete)
+	}))
+	defer ts.Close()
+
+	opts := &SendOptions{ChunkSize: 512}
+	if err := request.Send(ts.URL, opts); err == nil {
+		t.Error("send should fail when the connection drops")
+	}
+	if len(received) != 512 {
+		t.Errorf("server received %d bytes before drop", len(received))
+	}
+	var first int64 = -1
+	opts.Progress = func(sent int64, total int64) {
+		if first == -1 {
+			first = sent
+		}
+	}
+	if err := request.Send(ts.URL, opts); err != nil {
+		t.Errorf("resumed send failed: %s", err)
+	}
+	if first <= 512 {
+		t.Errorf("resumed send started over, first progress at %d", first)
+	}
+	if !bytes.Equal(received, payload) {
+		t.Error("server received corrupt payload")
+	}
+}
+
+func TestMultipartRequest_SendChunkedUnsupported(t *testing.T) {
+	payload, err := ioutil.ReadFile(request.PayloadPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the server ignores ranges and treats every request as a whole upload
+	var received [][]byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = append(received, body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	if err := request.Send(ts.URL, &SendOptions{ChunkSize: 512}); err != nil {
+		t.Errorf("send to a server without range support failed: %s", err)
+	}
+	if len(received) != 2 || len(received[0]) != 0 {
+		t.Fatalf("expected an empty probe and one full request, got %d requests", len(received))
+	}
+	if !bytes.Equal(received[1], payload) {
+		t.Error("server received a partial payload")
+	}
+	os.RemoveAll(dir)
 }
@@ -186,6 +186,15 @@ func addressesConfig(isMobile bool) native.Addresses {
 	}
 }
 
+// ApiAddressKey is the config key of the rest api's address, which is not
+// go-ipfs's own api multiaddr under Addresses.API
+const ApiAddressKey = "Textile.API"
+
+// NewApiAddress returns a local address on a random port for the rest api
+func NewApiAddress() string {
+	return fmt.Sprintf("127.0.0.1:%d", getRandomPort())
+}
+
 // DefaultDatastoreConfig is an internal function exported to aid in testing.
 func defaultDatastoreConfig() native.Datastore {
 	return native.Datastore{
@@ -10,6 +10,18 @@ type Datastore interface {
 	Profile() ProfileStore
 	Threads() ThreadStore
 	Blocks() BlockStore
+	Uploads() UploadStore
+	PhotoHashes() PhotoHashStore
+	PhotoVariants() PhotoVariantStore
+	PerceptualHashes() PerceptualHashStore
+	Watches() WatchStore
+	ThreadLocations() ThreadLocationStore
+	Search() SearchStore
+	PhotoLocations() PhotoLocationStore
+	Timeline() TimelineStore
+	PhotoVersions() PhotoVersionStore
+	ThreadIndexes() ThreadIndexStore
+	Rekey(password string) error
 	Ping() error
 	Close()
 }
@@ -27,6 +39,10 @@ type ConfigStore interface {
 	GetCreationDate() (time.Time, error)
 	GetVersion() (string, error)
 	IsEncrypted() bool
+	GetLocationPolicy() (LocationPolicy, float64, error)
+	SetLocationPolicy(policy LocationPolicy, grid float64) error
+	GetGatewaySecret() ([]byte, error)
+	SetGatewaySecret(secret []byte) error
 }
 
 type ProfileStore interface {
@@ -37,6 +53,12 @@ type ProfileStore interface {
 	GetSecret() ([]byte, error)
 	GetUsername() (string, error)
 	GetTokens() (accessToken string, refreshToken string, err error)
+	GetThreadIndex() (int, error)
+	SetThreadIndex(index int) error
+	GetSeed() ([]byte, error)
+	SetSeed(seed []byte) error
+	GetPublishedThreadIndex() (string, error)
+	SetPublishedThreadIndex(id string) error
 }
 
 type ThreadStore interface {
@@ -58,3 +80,89 @@ type BlockStore interface {
 	List(offsetId string, limit int, query string) []Block
 	Delete(id string) error
 }
+
+type UploadStore interface {
+	Queryable
+	Add(upload *Upload) error
+	Get(id string) *Upload
+	List(query string) []Upload
+	Update(upload *Upload) error
+	Delete(id string) error
+}
+
+type PhotoHashStore interface {
+	Queryable
+	Add(hash *PhotoHash) error
+	Get(hash string) *PhotoHash
+	GetByTarget(target string) *PhotoHash
+	DeleteByTarget(target string) error
+}
+
+type PhotoVariantStore interface {
+	Queryable
+	Add(variant *PhotoVariant) error
+	Get(target string, policy LocationPolicy, grid float64) *PhotoVariant
+	DeleteByTarget(target string) error
+}
+
+type PerceptualHashStore interface {
+	Queryable
+	Add(hash *PerceptualHash) error
+	Get(target string) *PerceptualHash
+	List(targets []string) []PerceptualHash
+	Delete(target string) error
+}
+
+type WatchStore interface {
+	Queryable
+	Add(watch *Watch) error
+	Get(path string) *Watch
+	List() []Watch
+	Delete(path string) error
+}
+
+type ThreadLocationStore interface {
+	Queryable
+	Set(location *ThreadLocation) error
+	Get(threadId string) *ThreadLocation
+	Delete(threadId string) error
+}
+
+type ThreadIndexStore interface {
+	Queryable
+	Add(index *ThreadIndex) error
+	Get(threadId string) *ThreadIndex
+	List() []ThreadIndex
+	Delete(threadId string) error
+}
+
+type SearchStore interface {
+	Queryable
+	Add(entry *SearchEntry) error
+	Search(query *SearchQuery) []SearchEntry
+	Delete(blockId string) error
+}
+
+type PhotoLocationStore interface {
+	Queryable
+	Add(location *PhotoLocation) error
+	InBounds(minLat float64, minLon float64, maxLat float64, maxLon float64, threadIds []string) []PhotoLocation
+	Delete(blockId string) error
+}
+
+type TimelineStore interface {
+	Queryable
+	Add(entry *TimelineEntry) error
+	Groups(period TimelinePeriod, before string, limit int, threadIds []string) []TimelineGroup
+	List(from time.Time, to time.Time, limit int, threadIds []string) []TimelineEntry
+	Delete(blockId string) error
+}
+
+type PhotoVersionStore interface {
+	Queryable
+	Add(version *PhotoVersion) error
+	GetByTarget(threadId string, target string) *PhotoVersion
+	Latest(threadId string, original string) *PhotoVersion
+	List(threadId string, original string) []PhotoVersion
+	Delete(blockId string) error
+}
@@ -52,7 +52,7 @@ func (c *BlockDB) Add(block *repo.Block) error {
 func (c *BlockDB) Get(id string) *repo.Block {
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	ret := c.handleQuery("select * from blocks where id='" + id + "';")
+	ret := c.handleQuery("select * from blocks where id=?;", id)
 	if len(ret) == 0 {
 		return nil
 	}
@@ -62,7 +62,7 @@ func (c *BlockDB) Get(id string) *repo.Block {
 func (c *BlockDB) GetByTarget(target string) *repo.Block {
 	c.lock.Lock()
 	defer c.lock.Unlock()
-	ret := c.handleQuery("select * from blocks where target='" + target + "';")
+	ret := c.handleQuery("select * from blocks where target=?;", target)
 	if len(ret) == 0 {
 		return nil
 	}
@@ -73,12 +73,14 @@ func (c *BlockDB) List(offsetId string, limit int, query string) []repo.Block {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	var stm string
+	var args []interface{}
 	if offsetId != "" {
 		q := ""
 		if query != "" {
 			q = query + " and "
 		}
-		stm = "select * from blocks where " + q + "date<(select date from blocks where id='" + offsetId + "') order by date desc limit " + strconv.Itoa(limit) + " ;"
+		stm = "select * from blocks where " + q + "date<(select date from blocks where id=?) order by date desc limit " + strconv.Itoa(limit) + " ;"
+		args = append(args, offsetId)
 	} else {
 		q := ""
 		if query != "" {
@@ -86,7 +88,7 @@ func (c *BlockDB) List(offsetId string, limit int, query string) []repo.Block {
 		}
 		stm = "select * from blocks " + q + "order by date desc limit " + strconv.Itoa(limit) + ";"
 	}
-	return c.handleQuery(stm)
+	return c.handleQuery(stm, args...)
 }
 
 func (c *BlockDB) Delete(id string) error {
@@ -96,9 +98,9 @@ func (c *BlockDB) Delete(id string) error {
 	return err
 }
 
-func (c *BlockDB) handleQuery(stm string) []repo.Block {
+func (c *BlockDB) handleQuery(stm string, args ...interface{}) []repo.Block {
 	var ret []repo.Block
-	rows, err := c.db.Query(stm)
+	rows, err := c.db.Query(stm, args...)
 	if err != nil {
 		log.Errorf("error in db query: %s", err)
 		return nil
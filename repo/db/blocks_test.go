@@ -67,6 +67,18 @@ func TestBlockDB_Get(t *testing.T) {
 	}
 }
 
+func TestBlockDB_GetQuoted(t *testing.T) {
+	if block := bdb.Get("x' or '1'='1"); block != nil {
+		t.Error("quoted id matched a block")
+	}
+	if block := bdb.GetByTarget("x' or '1'='1"); block != nil {
+		t.Error("quoted target matched a block")
+	}
+	if blocks := bdb.List("x') or ('1'='1", -1, ""); len(blocks) != 0 {
+		t.Error("quoted offset matched blocks")
+	}
+}
+
 func TestBlockDB_List(t *testing.T) {
 	setupBlockDB()
 	key, err := crypto.GenerateAESKey()
@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"github.com/textileio/textile-go/repo"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -82,6 +83,102 @@ func (c *ConfigDB) GetVersion() (string, error) {
 	return sv, nil
 }
 
+// GetLocationPolicy returns the default location policy for threads, keep if not set
+func (c *ConfigDB) GetLocationPolicy() (repo.LocationPolicy, float64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stmt, err := c.db.Prepare("select value from config where key=?")
+	if err != nil {
+		return repo.LocationKeep, 0, err
+	}
+	defer stmt.Close()
+	var policy, grid string
+	if err := stmt.QueryRow("location_policy").Scan(&policy); err == sql.ErrNoRows {
+		return repo.LocationKeep, 0, nil
+	} else if err != nil {
+		return repo.LocationKeep, 0, err
+	}
+	if err := stmt.QueryRow("location_grid").Scan(&grid); err != nil {
+		return repo.LocationKeep, 0, err
+	}
+	pi, err := strconv.Atoi(policy)
+	if err != nil {
+		return repo.LocationKeep, 0, err
+	}
+	gf, err := strconv.ParseFloat(grid, 64)
+	if err != nil {
+		return repo.LocationKeep, 0, err
+	}
+	return repo.LocationPolicy(pi), gf, nil
+}
+
+// SetLocationPolicy sets the default location policy for threads
+func (c *ConfigDB) SetLocationPolicy(policy repo.LocationPolicy, grid float64) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("insert or replace into config(key, value) values(?,?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec("location_policy", strconv.Itoa(int(policy)))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = stmt.Exec("location_grid", strconv.FormatFloat(grid, 'f', -1, 64))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+// GetGatewaySecret returns the key used to sign gateway urls, nil if not set
+func (c *ConfigDB) GetGatewaySecret() ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stmt, err := c.db.Prepare("select value from config where key=?")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	var secret []byte
+	if err := stmt.QueryRow("gateway_secret").Scan(&secret); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// SetGatewaySecret sets the key used to sign gateway urls
+func (c *ConfigDB) SetGatewaySecret(secret []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("insert or replace into config(key, value) values(?,?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec("gateway_secret", secret)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
 func (c *ConfigDB) IsEncrypted() bool {
 	c.lock.Lock()
 	defer c.lock.Unlock()
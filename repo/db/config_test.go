@@ -1,6 +1,7 @@
 package db
 
 import (
+	"github.com/textileio/textile-go/repo"
 	"os"
 	"path"
 	"testing"
@@ -50,6 +51,46 @@ func TestConfigDB_GetVersion(t *testing.T) {
 	}
 }
 
+func TestConfigDB_LocationPolicy(t *testing.T) {
+	policy, _, err := testDB.config.GetLocationPolicy()
+	if err != nil {
+		t.Error(err)
+	}
+	if policy != repo.LocationKeep {
+		t.Error("default location policy should be keep")
+	}
+	if err := testDB.config.SetLocationPolicy(repo.LocationCoarsen, 0.25); err != nil {
+		t.Error(err)
+	}
+	policy, grid, err := testDB.config.GetLocationPolicy()
+	if err != nil {
+		t.Error(err)
+	}
+	if policy != repo.LocationCoarsen || grid != 0.25 {
+		t.Errorf("location policy mismatch: %s, %f", policy, grid)
+	}
+}
+
+func TestConfigDB_GatewaySecret(t *testing.T) {
+	secret, err := testDB.config.GetGatewaySecret()
+	if err != nil {
+		t.Error(err)
+	}
+	if secret != nil {
+		t.Error("gateway secret should not be set")
+	}
+	if err := testDB.config.SetGatewaySecret([]byte("shh")); err != nil {
+		t.Error(err)
+	}
+	secret, err = testDB.config.GetGatewaySecret()
+	if err != nil {
+		t.Error(err)
+	}
+	if string(secret) != "shh" {
+		t.Errorf("gateway secret mismatch: %s", secret)
+	}
+}
+
 func TestConfigDB_IsEncrypted(t *testing.T) {
 	encrypted := testDB.Config().IsEncrypted()
 	if encrypted {
@@ -2,45 +2,73 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	_ "github.com/mutecomm/go-sqlcipher"
 	"github.com/op/go-logging"
 	"github.com/textileio/textile-go/repo"
+	"os"
 	"path"
+	"strings"
 	"sync"
 )
 
 var log = logging.MustGetLogger("db")
 
+var ErrAlreadyEncrypted = errors.New("datastore is already encrypted")
+var ErrEmptyPassword = errors.New("password must not be empty")
+
 type SQLiteDatastore struct {
 	config  repo.ConfigStore
 	profile repo.ProfileStore
 	threads repo.ThreadStore
 	blocks  repo.BlockStore
+	uploads repo.UploadStore
+	hashes  repo.PhotoHashStore
+	pvars   repo.PhotoVariantStore
+	phashes repo.PerceptualHashStore
+	watches repo.WatchStore
+	tlocs   repo.ThreadLocationStore
+	search  repo.SearchStore
+	plocs   repo.PhotoLocationStore
+	tline   repo.TimelineStore
+	pvers   repo.PhotoVersionStore
+	tidxs   repo.ThreadIndexStore
 	db      *sql.DB
 	lock    *sync.Mutex
 }
 
 func Create(repoPath, password string) (*SQLiteDatastore, error) {
-	var dbPath string
-	dbPath = path.Join(repoPath, "datastore", "mainnet.db")
-	conn, err := sql.Open("sqlite3", dbPath)
+	dbPath := datastorePath(repoPath)
+	conn, err := open(dbPath, password)
 	if err != nil {
 		return nil, err
 	}
-	if password != "" {
-		p := "pragma key='" + password + "';"
-		conn.Exec(p)
-	}
 	mux := new(sync.Mutex)
 	sqliteDB := &SQLiteDatastore{
 		config:  NewConfigStore(conn, mux, dbPath),
 		profile: NewProfileStore(conn, mux),
 		threads: NewThreadStore(conn, mux),
 		blocks:  NewBlockStore(conn, mux),
+		uploads: NewUploadStore(conn, mux),
+		hashes:  NewPhotoHashStore(conn, mux),
+		pvars:   NewPhotoVariantStore(conn, mux),
+		phashes: NewPerceptualHashStore(conn, mux),
+		watches: NewWatchStore(conn, mux),
+		tlocs:   NewThreadLocationStore(conn, mux),
+		search:  NewSearchStore(conn, mux),
+		plocs:   NewPhotoLocationStore(conn, mux),
+		tline:   NewTimelineStore(conn, mux),
+		pvers:   NewPhotoVersionStore(conn, mux),
+		tidxs:   NewThreadIndexStore(conn, mux),
 		db:      conn,
 		lock:    mux,
 	}
 
+	// bring older repos up to date, will fail quietly if locked
+	if err := migrateDatabaseTables(conn); err != nil {
+		log.Debugf("could not migrate datastore: %s", err)
+	}
+
 	return sqliteDB, nil
 }
 
@@ -68,22 +96,81 @@ func (d *SQLiteDatastore) Blocks() repo.BlockStore {
 	return d.blocks
 }
 
+func (d *SQLiteDatastore) Uploads() repo.UploadStore {
+	return d.uploads
+}
+
+func (d *SQLiteDatastore) PhotoHashes() repo.PhotoHashStore {
+	return d.hashes
+}
+
+func (d *SQLiteDatastore) PhotoVariants() repo.PhotoVariantStore {
+	return d.pvars
+}
+
+func (d *SQLiteDatastore) PerceptualHashes() repo.PerceptualHashStore {
+	return d.phashes
+}
+
+func (d *SQLiteDatastore) Watches() repo.WatchStore {
+	return d.watches
+}
+
+func (d *SQLiteDatastore) ThreadLocations() repo.ThreadLocationStore {
+	return d.tlocs
+}
+
+func (d *SQLiteDatastore) Search() repo.SearchStore {
+	return d.search
+}
+
+func (d *SQLiteDatastore) PhotoLocations() repo.PhotoLocationStore {
+	return d.plocs
+}
+
+func (d *SQLiteDatastore) Timeline() repo.TimelineStore {
+	return d.tline
+}
+
+func (d *SQLiteDatastore) PhotoVersions() repo.PhotoVersionStore {
+	return d.pvers
+}
+
+func (d *SQLiteDatastore) ThreadIndexes() repo.ThreadIndexStore {
+	return d.tidxs
+}
+
 func (d *SQLiteDatastore) Copy(dbPath string, password string) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	var cp string
-	stmt := "select name from sqlite_master where type='table'"
+	stmt := "select name, sql from sqlite_master where type='table'"
 	rows, err := d.db.Query(stmt)
 	if err != nil {
 		log.Errorf("error in copy: %s", err)
 		return err
 	}
-	var tables []string
+	var all, virtual []string
 	for rows.Next() {
-		var name string
-		if err := rows.Scan(&name); err != nil {
+		var name, schema string
+		if err := rows.Scan(&name, &schema); err != nil {
 			return err
 		}
+		all = append(all, name)
+		if strings.HasPrefix(strings.ToLower(schema), "create virtual table") {
+			virtual = append(virtual, name)
+		}
+	}
+
+	// virtual tables are copied through their module, skip their shadow tables
+	var tables []string
+outer:
+	for _, name := range all {
+		for _, vt := range virtual {
+			if strings.HasPrefix(name, vt+"_") {
+				continue outer
+			}
+		}
 		tables = append(tables, name)
 	}
 	if password == "" {
@@ -106,6 +193,17 @@ func (d *SQLiteDatastore) Copy(dbPath string, password string) error {
 	return nil
 }
 
+// Rekey changes the password of an encrypted database
+func (d *SQLiteDatastore) Rekey(password string) error {
+	if password == "" {
+		return ErrEmptyPassword
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	_, err := d.db.Exec("pragma rekey='" + escapePassword(password) + "';")
+	return err
+}
+
 func (d *SQLiteDatastore) InitTables(password string) error {
 	return initDatabaseTables(d.db, password)
 }
@@ -113,7 +211,7 @@ func (d *SQLiteDatastore) InitTables(password string) error {
 func initDatabaseTables(db *sql.DB, password string) error {
 	var sqlStmt string
 	if password != "" {
-		sqlStmt = "PRAGMA key = '" + password + "';"
+		sqlStmt = "PRAGMA key = '" + escapePassword(password) + "';"
 	}
 	sqlStmt += `
 	create table config (key text primary key not null, value blob);
@@ -128,5 +226,104 @@ func initDatabaseTables(db *sql.DB, password string) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return migrateDatabaseTables(db)
+}
+
+// migrateDatabaseTables creates tables added after a repo may have been initialized
+func migrateDatabaseTables(db *sql.DB) error {
+	sqlStmt := `
+    create table if not exists uploads (id text primary key not null, path text not null, target text not null, attempts integer not null, status integer not null, date integer not null, updated integer not null);
+    create index if not exists index_upload_status on uploads (status);
+    create table if not exists photo_hashes (hash text primary key not null, target text not null, key blob not null, date integer not null);
+    create index if not exists index_photo_hash_target on photo_hashes (target);
+    create table if not exists watches (path text primary key not null, thread text not null, date integer not null);
+    create table if not exists thread_locations (thread text primary key not null, policy integer not null, grid real not null);
+    create virtual table if not exists search using fts4(id, thread, target, caption, name, username, camera, date, created, notindexed=id, notindexed=thread, notindexed=target, notindexed=date, notindexed=created);
+    create table if not exists photo_locations (id text primary key not null, thread text not null, target text not null, lat real not null, lon real not null, date integer not null);
+    create index if not exists index_photo_location_lat_lon on photo_locations (lat, lon);
+    create table if not exists timeline (id text primary key not null, thread text not null, target text not null, taken integer not null, added integer not null);
+    create index if not exists index_timeline_taken on timeline (taken);
+    create table if not exists perceptual_hashes (target text primary key not null, hash integer not null, date integer not null);
+    create table if not exists photo_versions (id text primary key not null, thread text not null, original text not null, target text not null, date integer not null);
+    create index if not exists index_photo_version_thread_original_date on photo_versions (thread, original, date);
+    create table if not exists thread_indexes (thread text primary key not null, idx integer not null);
+    create table if not exists photo_variants (target text not null, policy integer not null, grid real not null, variant text not null, date integer not null, primary key (target, policy, grid));
+    create index if not exists index_photo_variant_variant on photo_variants (variant);
+	`
+	_, err := db.Exec(sqlStmt)
+	return err
+}
+
+// Encrypt migrates a plaintext datastore into a new database encrypted with password
+func Encrypt(repoPath, password string) error {
+	if password == "" {
+		return ErrEmptyPassword
+	}
+	dbPath := datastorePath(repoPath)
+	plain, err := Create(repoPath, "")
+	if err != nil {
+		return err
+	}
+	defer plain.Close()
+	if plain.Config().IsEncrypted() {
+		return ErrAlreadyEncrypted
+	}
+
+	// create an empty encrypted database with the same tables
+	tmpPath := dbPath + ".encrypted"
+	os.Remove(tmpPath)
+	conn, err := open(tmpPath, password)
+	if err != nil {
+		return err
+	}
+	if err := initDatabaseTables(conn, ""); err != nil {
+		conn.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	conn.Close()
+
+	// copy over all the rows and swap the files
+	if err := plain.Copy(tmpPath, escapePassword(password)); err != nil {
+		log.Errorf("error copying into encrypted datastore: %s", err)
+		os.Remove(tmpPath)
+		return err
+	}
+	plain.Close()
+	return os.Rename(tmpPath, dbPath)
+}
+
+// IsEncrypted returns whether or not the datastore under repoPath requires a password
+func IsEncrypted(repoPath string) (bool, error) {
+	plain, err := Create(repoPath, "")
+	if err != nil {
+		return false, err
+	}
+	defer plain.Close()
+	return plain.Config().IsEncrypted(), nil
+}
+
+// open returns a connection to the database at dbPath, keyed with password if not empty
+func open(dbPath string, password string) (*sql.DB, error) {
+	conn, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	if password != "" {
+		// the key pragma is per connection, so only use one
+		conn.SetMaxOpenConns(1)
+		p := "pragma key='" + escapePassword(password) + "';"
+		conn.Exec(p)
+	}
+	return conn, nil
+}
+
+// datastorePath returns the location of the database under a repo
+func datastorePath(repoPath string) string {
+	return path.Join(repoPath, "datastore", "mainnet.db")
+}
+
+// escapePassword quotes a password for use in a pragma string literal
+func escapePassword(password string) string {
+	return strings.Replace(password, "'", "''", -1)
 }
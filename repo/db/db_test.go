@@ -0,0 +1,110 @@
+package db
+
+import (
+	"github.com/textileio/textile-go/repo"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func setupPlainRepo(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "textile_db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(path.Join(dir, "datastore"), os.ModePerm)
+	plain, err := Create(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	if err := plain.Config().Init(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Threads().Add(&repo.Thread{Id: "Qmabc", Name: "boom", PrivKey: make([]byte, 8)}); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestEncrypt(t *testing.T) {
+	dir := setupPlainRepo(t)
+	defer os.RemoveAll(dir)
+
+	encrypted, err := IsEncrypted(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encrypted {
+		t.Error("plaintext datastore reported encrypted")
+	}
+	if err := Encrypt(dir, "it's secret"); err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err = IsEncrypted(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !encrypted {
+		t.Error("encrypted datastore reported plaintext")
+	}
+	if err := Encrypt(dir, "again"); err != ErrAlreadyEncrypted {
+		t.Errorf("encrypt again returned wrong error: %v", err)
+	}
+
+	wrong, err := Create(dir, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !wrong.Config().IsEncrypted() {
+		t.Error("wrong password should not open datastore")
+	}
+	wrong.Close()
+
+	right, err := Create(dir, "it's secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer right.Close()
+	if right.Threads().Get("Qmabc") == nil {
+		t.Error("rows were not copied into encrypted datastore")
+	}
+}
+
+func TestSQLiteDatastore_Rekey(t *testing.T) {
+	dir := setupPlainRepo(t)
+	defer os.RemoveAll(dir)
+	if err := Encrypt(dir, "old"); err != nil {
+		t.Fatal(err)
+	}
+	old, err := Create(dir, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Rekey(""); err != ErrEmptyPassword {
+		t.Errorf("rekey with empty password returned wrong error: %v", err)
+	}
+	if err := old.Rekey("new"); err != nil {
+		t.Fatal(err)
+	}
+	old.Close()
+
+	stale, err := Create(dir, "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stale.Config().IsEncrypted() {
+		t.Error("old password should no longer open datastore")
+	}
+	stale.Close()
+
+	fresh, err := Create(dir, "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fresh.Close()
+	if fresh.Threads().Get("Qmabc") == nil {
+		t.Error("could not read rekeyed datastore")
+	}
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"strings"
+	"sync"
+	"time"
+)
+
+type PerceptualHashDB struct {
+	modelStore
+}
+
+func NewPerceptualHashStore(db *sql.DB, lock *sync.Mutex) repo.PerceptualHashStore {
+	return &PerceptualHashDB{modelStore{db, lock}}
+}
+
+func (c *PerceptualHashDB) Add(hash *repo.PerceptualHash) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	stm := `insert or replace into perceptual_hashes(target, hash, date) values(?,?,?)`
+	stmt, err := tx.Prepare(stm)
+	if err != nil {
+		log.Errorf("error in tx prepare: %s", err)
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		hash.Target,
+		int64(hash.Hash), // sqlite integers are signed
+		int(hash.Date.Unix()),
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Errorf("error in db exec: %s", err)
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+func (c *PerceptualHashDB) Get(target string) *repo.PerceptualHash {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ret := c.handleQuery("select * from perceptual_hashes where target=?;", target)
+	if len(ret) == 0 {
+		return nil
+	}
+	return &ret[0]
+}
+
+// List lists the hashes of some targets, or all hashes if none are given
+func (c *PerceptualHashDB) List(targets []string) []repo.PerceptualHash {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stm := "select * from perceptual_hashes"
+	var args []interface{}
+	if len(targets) > 0 {
+		stm += " where target in (?" + strings.Repeat(",?", len(targets)-1) + ")"
+		for _, target := range targets {
+			args = append(args, target)
+		}
+	}
+	return c.handleQuery(stm+" order by date desc;", args...)
+}
+
+func (c *PerceptualHashDB) Delete(target string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("delete from perceptual_hashes where target=?", target)
+	return err
+}
+
+func (c *PerceptualHashDB) handleQuery(stm string, args ...interface{}) []repo.PerceptualHash {
+	var ret []repo.PerceptualHash
+	rows, err := c.db.Query(stm, args...)
+	if err != nil {
+		log.Errorf("error in db query: %s", err)
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var target string
+		var hash, dateInt int64
+		if err := rows.Scan(&target, &hash, &dateInt); err != nil {
+			log.Errorf("error in db scan: %s", err)
+			continue
+		}
+		ret = append(ret, repo.PerceptualHash{
+			Target: target,
+			Hash:   uint64(hash),
+			Date:   time.Unix(dateInt, 0),
+		})
+	}
+	return ret
+}
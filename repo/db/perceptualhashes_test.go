@@ -0,0 +1,83 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"testing"
+	"time"
+)
+
+var pehdb repo.PerceptualHashStore
+
+func init() {
+	setupPerceptualHashDB()
+}
+
+func setupPerceptualHashDB() {
+	conn, _ := sql.Open("sqlite3", ":memory:")
+	initDatabaseTables(conn, "")
+	pehdb = NewPerceptualHashStore(conn, new(sync.Mutex))
+}
+
+func TestPerceptualHashDB_Add(t *testing.T) {
+	err := pehdb.Add(&repo.PerceptualHash{
+		Target: "Qmtarget1",
+		Hash:   0xf0f0f0f0f0f0f0f0,
+		Date:   time.Now(),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	stmt, err := pehdb.PrepareQuery("select target from perceptual_hashes where target=?")
+	defer stmt.Close()
+	var target string
+	err = stmt.QueryRow("Qmtarget1").Scan(&target)
+	if err != nil {
+		t.Error(err)
+	}
+	if target != "Qmtarget1" {
+		t.Errorf(`expected "Qmtarget1" got %s`, target)
+	}
+}
+
+func TestPerceptualHashDB_Get(t *testing.T) {
+	hash := pehdb.Get("Qmtarget1")
+	if hash == nil {
+		t.Error("could not get hash")
+		return
+	}
+	if hash.Hash != 0xf0f0f0f0f0f0f0f0 {
+		t.Errorf("hash high bits were not kept: %x", hash.Hash)
+	}
+	if pehdb.Get("Qmnope") != nil {
+		t.Error("missing target should not have a hash")
+	}
+}
+
+func TestPerceptualHashDB_List(t *testing.T) {
+	err := pehdb.Add(&repo.PerceptualHash{
+		Target: "Qmtarget2",
+		Hash:   0x0f0f,
+		Date:   time.Now(),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(pehdb.List(nil)) != 2 {
+		t.Error("list should return all hashes")
+	}
+	list := pehdb.List([]string{"Qmtarget2", "Qmnope"})
+	if len(list) != 1 || list[0].Hash != 0x0f0f {
+		t.Error("list should only return hashes of the given targets")
+	}
+}
+
+func TestPerceptualHashDB_Delete(t *testing.T) {
+	if err := pehdb.Delete("Qmtarget1"); err != nil {
+		t.Error(err)
+	}
+	if pehdb.Get("Qmtarget1") != nil {
+		t.Error("hash was not deleted")
+	}
+}
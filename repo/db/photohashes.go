@@ -0,0 +1,97 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"time"
+)
+
+type PhotoHashDB struct {
+	modelStore
+}
+
+func NewPhotoHashStore(db *sql.DB, lock *sync.Mutex) repo.PhotoHashStore {
+	return &PhotoHashDB{modelStore{db, lock}}
+}
+
+func (c *PhotoHashDB) Add(hash *repo.PhotoHash) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	stm := `insert or replace into photo_hashes(hash, target, key, date) values(?,?,?,?)`
+	stmt, err := tx.Prepare(stm)
+	if err != nil {
+		log.Errorf("error in tx prepare: %s", err)
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		hash.Hash,
+		hash.Target,
+		hash.Key,
+		int(hash.Date.Unix()),
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Errorf("error in db exec: %s", err)
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+func (c *PhotoHashDB) Get(hash string) *repo.PhotoHash {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ret := c.handleQuery("select * from photo_hashes where hash='" + hash + "';")
+	if len(ret) == 0 {
+		return nil
+	}
+	return &ret[0]
+}
+
+func (c *PhotoHashDB) GetByTarget(target string) *repo.PhotoHash {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ret := c.handleQuery("select * from photo_hashes where target='" + target + "';")
+	if len(ret) == 0 {
+		return nil
+	}
+	return &ret[0]
+}
+
+func (c *PhotoHashDB) DeleteByTarget(target string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("delete from photo_hashes where target=?", target)
+	return err
+}
+
+func (c *PhotoHashDB) handleQuery(stm string) []repo.PhotoHash {
+	var ret []repo.PhotoHash
+	rows, err := c.db.Query(stm)
+	if err != nil {
+		log.Errorf("error in db query: %s", err)
+		return nil
+	}
+	for rows.Next() {
+		var hash, target string
+		var key []byte
+		var dateInt int
+		if err := rows.Scan(&hash, &target, &key, &dateInt); err != nil {
+			log.Errorf("error in db scan: %s", err)
+			continue
+		}
+		ret = append(ret, repo.PhotoHash{
+			Hash:   hash,
+			Target: target,
+			Key:    key,
+			Date:   time.Unix(int64(dateInt), 0),
+		})
+	}
+	return ret
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"testing"
+	"time"
+)
+
+var phdb repo.PhotoHashStore
+
+func init() {
+	setupPhotoHashDB()
+}
+
+func setupPhotoHashDB() {
+	conn, _ := sql.Open("sqlite3", ":memory:")
+	initDatabaseTables(conn, "")
+	phdb = NewPhotoHashStore(conn, new(sync.Mutex))
+}
+
+func TestPhotoHashDB_Add(t *testing.T) {
+	err := phdb.Add(&repo.PhotoHash{
+		Hash:   "abc",
+		Target: "Qm123",
+		Key:    []byte("key"),
+		Date:   time.Now(),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	stmt, err := phdb.PrepareQuery("select target from photo_hashes where hash=?")
+	defer stmt.Close()
+	var target string
+	err = stmt.QueryRow("abc").Scan(&target)
+	if err != nil {
+		t.Error(err)
+	}
+	if target != "Qm123" {
+		t.Errorf(`expected "Qm123" got %s`, target)
+	}
+}
+
+func TestPhotoHashDB_Get(t *testing.T) {
+	hash := phdb.Get("abc")
+	if hash == nil {
+		t.Error("could not get photo hash")
+		return
+	}
+	if string(hash.Key) != "key" {
+		t.Errorf(`expected "key" got %s`, string(hash.Key))
+	}
+}
+
+func TestPhotoHashDB_GetByTarget(t *testing.T) {
+	hash := phdb.GetByTarget("Qm123")
+	if hash == nil || hash.Hash != "abc" {
+		t.Error("could not get photo hash by target")
+	}
+}
+
+func TestPhotoHashDB_DeleteByTarget(t *testing.T) {
+	if err := phdb.DeleteByTarget("Qm123"); err != nil {
+		t.Error(err)
+	}
+	if phdb.Get("abc") != nil {
+		t.Error("photo hash was not deleted")
+	}
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"strings"
+	"sync"
+	"time"
+)
+
+type PhotoLocationDB struct {
+	modelStore
+}
+
+func NewPhotoLocationStore(db *sql.DB, lock *sync.Mutex) repo.PhotoLocationStore {
+	return &PhotoLocationDB{modelStore{db, lock}}
+}
+
+func (c *PhotoLocationDB) Add(location *repo.PhotoLocation) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	stm := `insert or replace into photo_locations(id, thread, target, lat, lon, date) values(?,?,?,?,?,?)`
+	stmt, err := tx.Prepare(stm)
+	if err != nil {
+		log.Errorf("error in tx prepare: %s", err)
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		location.BlockId,
+		location.ThreadId,
+		location.Target,
+		location.Latitude,
+		location.Longitude,
+		int(location.Date.Unix()),
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Errorf("error in db exec: %s", err)
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+// InBounds lists locations inside a bounding box, newest first. A box whose min
+// longitude is greater than its max crosses the antimeridian.
+func (c *PhotoLocationDB) InBounds(minLat float64, minLon float64, maxLat float64, maxLon float64, threadIds []string) []repo.PhotoLocation {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stm := "select id, thread, target, lat, lon, date from photo_locations where lat>=? and lat<=?"
+	args := []interface{}{minLat, maxLat}
+	if minLon <= maxLon {
+		stm += " and lon>=? and lon<=?"
+	} else {
+		stm += " and (lon>=? or lon<=?)"
+	}
+	args = append(args, minLon, maxLon)
+	if len(threadIds) > 0 {
+		stm += " and thread in (?" + strings.Repeat(",?", len(threadIds)-1) + ")"
+		for _, id := range threadIds {
+			args = append(args, id)
+		}
+	}
+	return c.handleQuery(stm+" order by date desc;", args...)
+}
+
+func (c *PhotoLocationDB) Delete(blockId string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("delete from photo_locations where id=?", blockId)
+	return err
+}
+
+func (c *PhotoLocationDB) handleQuery(stm string, args ...interface{}) []repo.PhotoLocation {
+	var ret []repo.PhotoLocation
+	rows, err := c.db.Query(stm, args...)
+	if err != nil {
+		log.Errorf("error in db query: %s", err)
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, thread, target string
+		var lat, lon float64
+		var dateInt int64
+		if err := rows.Scan(&id, &thread, &target, &lat, &lon, &dateInt); err != nil {
+			log.Errorf("error in db scan: %s", err)
+			continue
+		}
+		ret = append(ret, repo.PhotoLocation{
+			BlockId:   id,
+			ThreadId:  thread,
+			Target:    target,
+			Latitude:  lat,
+			Longitude: lon,
+			Date:      time.Unix(dateInt, 0),
+		})
+	}
+	return ret
+}
@@ -0,0 +1,69 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"testing"
+	"time"
+)
+
+var pldb repo.PhotoLocationStore
+
+func init() {
+	setupPhotoLocationDB()
+}
+
+func setupPhotoLocationDB() {
+	conn, _ := sql.Open("sqlite3", ":memory:")
+	initDatabaseTables(conn, "")
+	pldb = NewPhotoLocationStore(conn, new(sync.Mutex))
+}
+
+func TestPhotoLocationDB_Add(t *testing.T) {
+	locations := []repo.PhotoLocation{
+		{BlockId: "Qmnyc", ThreadId: "Qmthread1", Target: "Qmt1", Latitude: 40.7, Longitude: -74, Date: time.Now()},
+		{BlockId: "Qmsf", ThreadId: "Qmthread2", Target: "Qmt2", Latitude: 37.8, Longitude: -122.4, Date: time.Now().Add(-time.Hour)},
+		{BlockId: "Qmfiji", ThreadId: "Qmthread1", Target: "Qmt3", Latitude: -17.7, Longitude: 178.1, Date: time.Now().Add(-time.Hour * 2)},
+	}
+	for _, loc := range locations {
+		l := loc
+		if err := pldb.Add(&l); err != nil {
+			t.Error(err)
+		}
+	}
+	stmt, err := pldb.PrepareQuery("select lat from photo_locations where id=?")
+	defer stmt.Close()
+	var lat float64
+	err = stmt.QueryRow("Qmnyc").Scan(&lat)
+	if err != nil {
+		t.Error(err)
+	}
+	if lat != 40.7 {
+		t.Errorf("expected 40.7 got %f", lat)
+	}
+}
+
+func TestPhotoLocationDB_InBounds(t *testing.T) {
+	if res := pldb.InBounds(-90, -180, 90, 180, nil); len(res) != 3 || res[0].BlockId != "Qmnyc" {
+		t.Error("world bounds should return all locations, newest first")
+	}
+	if res := pldb.InBounds(30, -130, 45, -70, nil); len(res) != 2 {
+		t.Errorf("usa bounds returned %d locations", len(res))
+	}
+	if res := pldb.InBounds(30, -130, 45, -70, []string{"Qmthread2"}); len(res) != 1 || res[0].BlockId != "Qmsf" {
+		t.Error("bounds should filter by thread")
+	}
+	if res := pldb.InBounds(-30, 170, 0, -170, nil); len(res) != 1 || res[0].BlockId != "Qmfiji" {
+		t.Error("bounds should wrap around the antimeridian")
+	}
+}
+
+func TestPhotoLocationDB_Delete(t *testing.T) {
+	if err := pldb.Delete("Qmnyc"); err != nil {
+		t.Error(err)
+	}
+	if len(pldb.InBounds(-90, -180, 90, 180, nil)) != 2 {
+		t.Error("location was not deleted")
+	}
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"time"
+)
+
+type PhotoVariantDB struct {
+	modelStore
+}
+
+func NewPhotoVariantStore(db *sql.DB, lock *sync.Mutex) repo.PhotoVariantStore {
+	return &PhotoVariantDB{modelStore{db, lock}}
+}
+
+func (c *PhotoVariantDB) Add(variant *repo.PhotoVariant) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	stm := `insert or replace into photo_variants(target, policy, grid, variant, date) values(?,?,?,?,?)`
+	stmt, err := tx.Prepare(stm)
+	if err != nil {
+		log.Errorf("error in tx prepare: %s", err)
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		variant.Target,
+		int(variant.Policy),
+		variant.Grid,
+		variant.Variant,
+		int(variant.Date.Unix()),
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Errorf("error in db exec: %s", err)
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+func (c *PhotoVariantDB) Get(target string, policy repo.LocationPolicy, grid float64) *repo.PhotoVariant {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ret := c.handleQuery("select * from photo_variants where target=? and policy=? and grid=?;", target, int(policy), grid)
+	if len(ret) == 0 {
+		return nil
+	}
+	return &ret[0]
+}
+
+// DeleteByTarget removes variants of a target, and any entry where it is the variant
+func (c *PhotoVariantDB) DeleteByTarget(target string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("delete from photo_variants where target=? or variant=?", target, target)
+	return err
+}
+
+func (c *PhotoVariantDB) handleQuery(stm string, args ...interface{}) []repo.PhotoVariant {
+	var ret []repo.PhotoVariant
+	rows, err := c.db.Query(stm, args...)
+	if err != nil {
+		log.Errorf("error in db query: %s", err)
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var target, variant string
+		var policyInt, dateInt int
+		var grid float64
+		if err := rows.Scan(&target, &policyInt, &grid, &variant, &dateInt); err != nil {
+			log.Errorf("error in db scan: %s", err)
+			continue
+		}
+		ret = append(ret, repo.PhotoVariant{
+			Target:  target,
+			Policy:  repo.LocationPolicy(policyInt),
+			Grid:    grid,
+			Variant: variant,
+			Date:    time.Unix(int64(dateInt), 0),
+		})
+	}
+	return ret
+}
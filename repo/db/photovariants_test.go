@@ -0,0 +1,66 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"testing"
+	"time"
+)
+
+var pvardb repo.PhotoVariantStore
+
+func init() {
+	setupPhotoVariantDB()
+}
+
+func setupPhotoVariantDB() {
+	conn, _ := sql.Open("sqlite3", ":memory:")
+	initDatabaseTables(conn, "")
+	pvardb = NewPhotoVariantStore(conn, new(sync.Mutex))
+}
+
+func TestPhotoVariantDB_Add(t *testing.T) {
+	err := pvardb.Add(&repo.PhotoVariant{
+		Target:  "Qm123",
+		Policy:  repo.LocationCoarsen,
+		Grid:    0.1,
+		Variant: "Qm456",
+		Date:    time.Now(),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	stmt, err := pvardb.PrepareQuery("select variant from photo_variants where target=?")
+	defer stmt.Close()
+	var variant string
+	err = stmt.QueryRow("Qm123").Scan(&variant)
+	if err != nil {
+		t.Error(err)
+	}
+	if variant != "Qm456" {
+		t.Errorf(`expected "Qm456" got %s`, variant)
+	}
+}
+
+func TestPhotoVariantDB_Get(t *testing.T) {
+	variant := pvardb.Get("Qm123", repo.LocationCoarsen, 0.1)
+	if variant == nil || variant.Variant != "Qm456" {
+		t.Error("could not get photo variant")
+	}
+	if pvardb.Get("Qm123", repo.LocationStrip, 0.1) != nil {
+		t.Error("got variant for wrong policy")
+	}
+	if pvardb.Get("Qm123", repo.LocationCoarsen, 1) != nil {
+		t.Error("got variant for wrong grid")
+	}
+}
+
+func TestPhotoVariantDB_DeleteByTarget(t *testing.T) {
+	if err := pvardb.DeleteByTarget("Qm456"); err != nil {
+		t.Error(err)
+	}
+	if pvardb.Get("Qm123", repo.LocationCoarsen, 0.1) != nil {
+		t.Error("delete by variant failed")
+	}
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"time"
+)
+
+type PhotoVersionDB struct {
+	modelStore
+}
+
+func NewPhotoVersionStore(db *sql.DB, lock *sync.Mutex) repo.PhotoVersionStore {
+	return &PhotoVersionDB{modelStore{db, lock}}
+}
+
+func (c *PhotoVersionDB) Add(version *repo.PhotoVersion) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	stm := `insert or replace into photo_versions(id, thread, original, target, date) values(?,?,?,?,?)`
+	stmt, err := tx.Prepare(stm)
+	if err != nil {
+		log.Errorf("error in tx prepare: %s", err)
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		version.BlockId,
+		version.ThreadId,
+		version.Original,
+		version.Target,
+		int(version.Date.Unix()),
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Errorf("error in db exec: %s", err)
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+func (c *PhotoVersionDB) GetByTarget(threadId string, target string) *repo.PhotoVersion {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ret := c.handleQuery("select * from photo_versions where thread=? and target=? limit 1;", threadId, target)
+	if len(ret) == 0 {
+		return nil
+	}
+	return &ret[0]
+}
+
+// Latest returns the newest version of an original photo in a thread
+func (c *PhotoVersionDB) Latest(threadId string, original string) *repo.PhotoVersion {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ret := c.handleQuery("select * from photo_versions where thread=? and original=? order by date desc, rowid desc limit 1;", threadId, original)
+	if len(ret) == 0 {
+		return nil
+	}
+	return &ret[0]
+}
+
+// List lists the versions of an original photo in a thread, newest first
+func (c *PhotoVersionDB) List(threadId string, original string) []repo.PhotoVersion {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.handleQuery("select * from photo_versions where thread=? and original=? order by date desc, rowid desc;", threadId, original)
+}
+
+func (c *PhotoVersionDB) Delete(blockId string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("delete from photo_versions where id=?", blockId)
+	return err
+}
+
+func (c *PhotoVersionDB) handleQuery(stm string, args ...interface{}) []repo.PhotoVersion {
+	var ret []repo.PhotoVersion
+	rows, err := c.db.Query(stm, args...)
+	if err != nil {
+		log.Errorf("error in db query: %s", err)
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, thread, original, target string
+		var dateInt int64
+		if err := rows.Scan(&id, &thread, &original, &target, &dateInt); err != nil {
+			log.Errorf("error in db scan: %s", err)
+			continue
+		}
+		ret = append(ret, repo.PhotoVersion{
+			BlockId:  id,
+			ThreadId: thread,
+			Original: original,
+			Target:   target,
+			Date:     time.Unix(dateInt, 0),
+		})
+	}
+	return ret
+}
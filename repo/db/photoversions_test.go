@@ -0,0 +1,81 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"testing"
+	"time"
+)
+
+var pvdb repo.PhotoVersionStore
+
+func init() {
+	setupPhotoVersionDB()
+}
+
+func setupPhotoVersionDB() {
+	conn, _ := sql.Open("sqlite3", ":memory:")
+	initDatabaseTables(conn, "")
+	pvdb = NewPhotoVersionStore(conn, new(sync.Mutex))
+}
+
+func TestPhotoVersionDB_Add(t *testing.T) {
+	versions := []repo.PhotoVersion{
+		{BlockId: "Qmblock1", ThreadId: "Qmthread", Original: "Qmoriginal", Target: "Qmv1", Date: time.Now().Add(-time.Hour)},
+		{BlockId: "Qmblock2", ThreadId: "Qmthread", Original: "Qmoriginal", Target: "Qmv2", Date: time.Now()},
+		{BlockId: "Qmblock3", ThreadId: "Qmother", Original: "Qmoriginal", Target: "Qmv3", Date: time.Now()},
+	}
+	for _, version := range versions {
+		v := version
+		if err := pvdb.Add(&v); err != nil {
+			t.Error(err)
+		}
+	}
+	stmt, err := pvdb.PrepareQuery("select original from photo_versions where id=?")
+	defer stmt.Close()
+	var original string
+	err = stmt.QueryRow("Qmblock1").Scan(&original)
+	if err != nil {
+		t.Error(err)
+	}
+	if original != "Qmoriginal" {
+		t.Errorf(`expected "Qmoriginal" got %s`, original)
+	}
+}
+
+func TestPhotoVersionDB_GetByTarget(t *testing.T) {
+	version := pvdb.GetByTarget("Qmthread", "Qmv1")
+	if version == nil || version.BlockId != "Qmblock1" {
+		t.Error("could not get version by target")
+	}
+	if pvdb.GetByTarget("Qmother", "Qmv1") != nil {
+		t.Error("version should not be found in another thread")
+	}
+}
+
+func TestPhotoVersionDB_Latest(t *testing.T) {
+	latest := pvdb.Latest("Qmthread", "Qmoriginal")
+	if latest == nil || latest.Target != "Qmv2" {
+		t.Error("latest should return the newest version")
+	}
+	if pvdb.Latest("Qmthread", "Qmnope") != nil {
+		t.Error("unedited photo should not have a latest version")
+	}
+}
+
+func TestPhotoVersionDB_List(t *testing.T) {
+	list := pvdb.List("Qmthread", "Qmoriginal")
+	if len(list) != 2 || list[0].Target != "Qmv2" || list[1].Target != "Qmv1" {
+		t.Error("list should return the thread's versions, newest first")
+	}
+}
+
+func TestPhotoVersionDB_Delete(t *testing.T) {
+	if err := pvdb.Delete("Qmblock2"); err != nil {
+		t.Error(err)
+	}
+	if latest := pvdb.Latest("Qmthread", "Qmoriginal"); latest == nil || latest.Target != "Qmv1" {
+		t.Error("version was not deleted")
+	}
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"github.com/textileio/textile-go/repo"
+	"strconv"
 	"sync"
 )
 
@@ -154,3 +155,72 @@ func (c *ProfileDB) GetTokens() (accessToken string, refreshToken string, err er
 	}
 	return accessToken, refreshToken, nil
 }
+
+func (c *ProfileDB) GetThreadIndex() (int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stmt, err := c.db.Prepare("select value from profile where key=?")
+	defer stmt.Close()
+	var index string
+	err = stmt.QueryRow("thread_index").Scan(&index)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(index)
+}
+
+func (c *ProfileDB) SetThreadIndex(index int) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("insert or replace into profile(key, value) values(?,?)", "thread_index", strconv.Itoa(index))
+	return err
+}
+
+func (c *ProfileDB) GetSeed() ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stmt, err := c.db.Prepare("select value from profile where key=?")
+	defer stmt.Close()
+	var seed []byte
+	err = stmt.QueryRow("seed").Scan(&seed)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return seed, nil
+}
+
+func (c *ProfileDB) SetSeed(seed []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("insert or replace into profile(key, value) values(?,?)", "seed", seed)
+	return err
+}
+
+func (c *ProfileDB) GetPublishedThreadIndex() (string, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stmt, err := c.db.Prepare("select value from profile where key=?")
+	defer stmt.Close()
+	var id string
+	err = stmt.QueryRow("thread_index_id").Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (c *ProfileDB) SetPublishedThreadIndex(id string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("insert or replace into profile(key, value) values(?,?)", "thread_index_id", id)
+	return err
+}
@@ -93,3 +93,75 @@ func TestProfileDB_SignOut(t *testing.T) {
 		t.Error("signed out but username still present")
 	}
 }
+
+func TestProfileDB_ThreadIndex(t *testing.T) {
+	index, err := pdb.GetThreadIndex()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if index != 0 {
+		t.Error("got bad initial thread index")
+		return
+	}
+	if err := pdb.SetThreadIndex(3); err != nil {
+		t.Error(err)
+		return
+	}
+	index, err = pdb.GetThreadIndex()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if index != 3 {
+		t.Error("got bad thread index")
+	}
+}
+
+func TestProfileDB_Seed(t *testing.T) {
+	seed, err := pdb.GetSeed()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if seed != nil {
+		t.Error("got seed before it was set")
+		return
+	}
+	if err := pdb.SetSeed([]byte("seed")); err != nil {
+		t.Error(err)
+		return
+	}
+	seed, err = pdb.GetSeed()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(seed) != "seed" {
+		t.Error("got bad seed")
+	}
+}
+
+func TestProfileDB_PublishedThreadIndex(t *testing.T) {
+	id, err := pdb.GetPublishedThreadIndex()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if id != "" {
+		t.Error("got published thread index before it was set")
+		return
+	}
+	if err := pdb.SetPublishedThreadIndex("Qm123"); err != nil {
+		t.Error(err)
+		return
+	}
+	id, err = pdb.GetPublishedThreadIndex()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if id != "Qm123" {
+		t.Error("got bad published thread index")
+	}
+}
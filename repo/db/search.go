@@ -0,0 +1,146 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SearchDB struct {
+	modelStore
+}
+
+func NewSearchStore(db *sql.DB, lock *sync.Mutex) repo.SearchStore {
+	return &SearchDB{modelStore{db, lock}}
+}
+
+func (c *SearchDB) Add(entry *repo.SearchEntry) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// fts tables have no primary key, replace by hand
+	if _, err := tx.Exec("delete from search where id=?", entry.BlockId); err != nil {
+		tx.Rollback()
+		log.Errorf("error in db exec: %s", err)
+		return err
+	}
+	stm := `insert into search(id, thread, target, caption, name, username, camera, date, created) values(?,?,?,?,?,?,?,?,?)`
+	stmt, err := tx.Prepare(stm)
+	if err != nil {
+		tx.Rollback()
+		log.Errorf("error in tx prepare: %s", err)
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		entry.BlockId,
+		entry.ThreadId,
+		entry.Target,
+		entry.Caption,
+		entry.Name,
+		entry.Username,
+		entry.Camera,
+		int(entry.Date.Unix()),
+		int(entry.Created.Unix()),
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Errorf("error in db exec: %s", err)
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+func (c *SearchDB) Search(query *repo.SearchQuery) []repo.SearchEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var conds []string
+	var args []interface{}
+	if match := matchQuery(query.Text); match != "" {
+		conds = append(conds, "search match ?")
+		args = append(args, match)
+	}
+	if query.ThreadId != "" {
+		conds = append(conds, "thread=?")
+		args = append(args, query.ThreadId)
+	}
+	if query.Username != "" {
+		conds = append(conds, "username=?")
+		args = append(args, query.Username)
+	}
+	if !query.From.IsZero() {
+		conds = append(conds, "date>=?")
+		args = append(args, int(query.From.Unix()))
+	}
+	if !query.To.IsZero() {
+		conds = append(conds, "date<?")
+		args = append(args, int(query.To.Unix()))
+	}
+	stm := "select id, thread, target, caption, name, username, camera, date, created from search"
+	if len(conds) > 0 {
+		stm += " where " + strings.Join(conds, " and ")
+	}
+	stm += " order by date desc"
+	if query.Limit > 0 {
+		stm += " limit " + strconv.Itoa(query.Limit)
+	}
+	return c.handleQuery(stm+";", args...)
+}
+
+func (c *SearchDB) Delete(blockId string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("delete from search where id=?", blockId)
+	return err
+}
+
+func (c *SearchDB) handleQuery(stm string, args ...interface{}) []repo.SearchEntry {
+	var ret []repo.SearchEntry
+	rows, err := c.db.Query(stm, args...)
+	if err != nil {
+		log.Errorf("error in db query: %s", err)
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, thread, target, caption, name, username, camera string
+		var dateInt, createdInt int64
+		if err := rows.Scan(&id, &thread, &target, &caption, &name, &username, &camera, &dateInt, &createdInt); err != nil {
+			log.Errorf("error in db scan: %s", err)
+			continue
+		}
+		ret = append(ret, repo.SearchEntry{
+			BlockId:  id,
+			ThreadId: thread,
+			Target:   target,
+			Caption:  caption,
+			Name:     name,
+			Username: username,
+			Camera:   camera,
+			Date:     time.Unix(dateInt, 0),
+			Created:  time.Unix(createdInt, 0),
+		})
+	}
+	return ret
+}
+
+// matchQuery turns free text into an fts query where every word must prefix match
+func matchQuery(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		word = strings.Replace(word, `"`, "", -1)
+		if word == "" {
+			continue
+		}
+		terms = append(terms, `"`+word+`*"`)
+	}
+	return strings.Join(terms, " ")
+}
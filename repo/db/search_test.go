@@ -0,0 +1,121 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"testing"
+	"time"
+)
+
+var sdb repo.SearchStore
+
+func init() {
+	setupSearchDB()
+}
+
+func setupSearchDB() {
+	conn, _ := sql.Open("sqlite3", ":memory:")
+	initDatabaseTables(conn, "")
+	sdb = NewSearchStore(conn, new(sync.Mutex))
+}
+
+func TestSearchDB_Add(t *testing.T) {
+	err := sdb.Add(&repo.SearchEntry{
+		BlockId:  "Qmblock1",
+		ThreadId: "Qmthread1",
+		Target:   "Qmtarget1",
+		Caption:  "sunset at the beach",
+		Name:     "IMG_0001",
+		Username: "alice",
+		Camera:   "Apple iPhone X",
+		Date:     time.Now().Add(-time.Hour * 48),
+		Created:  time.Now().Add(-time.Hour * 72),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	err = sdb.Add(&repo.SearchEntry{
+		BlockId:  "Qmblock2",
+		ThreadId: "Qmthread2",
+		Target:   "Qmtarget2",
+		Caption:  "birthday cake",
+		Name:     "DSC_0042",
+		Username: "bob",
+		Camera:   "NIKON D750",
+		Date:     time.Now(),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	stmt, err := sdb.PrepareQuery("select thread from search where id=?")
+	defer stmt.Close()
+	var thread string
+	err = stmt.QueryRow("Qmblock1").Scan(&thread)
+	if err != nil {
+		t.Error(err)
+	}
+	if thread != "Qmthread1" {
+		t.Errorf(`expected "Qmthread1" got %s`, thread)
+	}
+}
+
+func TestSearchDB_AddAgain(t *testing.T) {
+	err := sdb.Add(&repo.SearchEntry{
+		BlockId:  "Qmblock1",
+		ThreadId: "Qmthread1",
+		Target:   "Qmtarget1",
+		Caption:  "sunset at the beach, again",
+		Username: "alice",
+		Date:     time.Now().Add(-time.Hour * 48),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(sdb.Search(&repo.SearchQuery{})) != 2 {
+		t.Error("adding an entry again should replace it")
+	}
+}
+
+func TestSearchDB_Search(t *testing.T) {
+	all := sdb.Search(&repo.SearchQuery{})
+	if len(all) != 2 || all[0].BlockId != "Qmblock2" {
+		t.Error("search should return newest entries first")
+	}
+	if res := sdb.Search(&repo.SearchQuery{Text: "beac"}); len(res) != 1 || res[0].BlockId != "Qmblock1" {
+		t.Error("search should prefix match captions")
+	}
+	if res := sdb.Search(&repo.SearchQuery{Text: "nikon"}); len(res) != 1 || res[0].BlockId != "Qmblock2" {
+		t.Error("search should match cameras")
+	}
+	if res := sdb.Search(&repo.SearchQuery{Text: "sunset cake"}); len(res) != 0 {
+		t.Error("search should match all words")
+	}
+	if res := sdb.Search(&repo.SearchQuery{Text: `"unbalanced`}); len(res) != 0 {
+		t.Error("search should handle stray quotes")
+	}
+	if res := sdb.Search(&repo.SearchQuery{ThreadId: "Qmthread1"}); len(res) != 1 {
+		t.Error("search should filter by thread")
+	}
+	if res := sdb.Search(&repo.SearchQuery{Username: "bob"}); len(res) != 1 || res[0].BlockId != "Qmblock2" {
+		t.Error("search should filter by username")
+	}
+	if res := sdb.Search(&repo.SearchQuery{From: time.Now().Add(-time.Hour)}); len(res) != 1 || res[0].BlockId != "Qmblock2" {
+		t.Error("search should filter by from date")
+	}
+	if res := sdb.Search(&repo.SearchQuery{To: time.Now().Add(-time.Hour)}); len(res) != 1 || res[0].BlockId != "Qmblock1" {
+		t.Error("search should filter by to date")
+	}
+	if res := sdb.Search(&repo.SearchQuery{Limit: 1}); len(res) != 1 {
+		t.Error("search should limit results")
+	}
+}
+
+func TestSearchDB_Delete(t *testing.T) {
+	if err := sdb.Delete("Qmblock1"); err != nil {
+		t.Error(err)
+	}
+	if len(sdb.Search(&repo.SearchQuery{})) != 1 {
+		t.Error("entry was not deleted")
+	}
+}
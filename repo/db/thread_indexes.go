@@ -0,0 +1,93 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+)
+
+type ThreadIndexDB struct {
+	modelStore
+}
+
+func NewThreadIndexStore(db *sql.DB, lock *sync.Mutex) repo.ThreadIndexStore {
+	return &ThreadIndexDB{modelStore{db, lock}}
+}
+
+func (c *ThreadIndexDB) Add(index *repo.ThreadIndex) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	stm := `insert or replace into thread_indexes(thread, idx) values(?,?)`
+	stmt, err := tx.Prepare(stm)
+	if err != nil {
+		log.Errorf("error in tx prepare: %s", err)
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		index.ThreadId,
+		index.Index,
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Errorf("error in db exec: %s", err)
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+func (c *ThreadIndexDB) Get(threadId string) *repo.ThreadIndex {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stmt, err := c.db.Prepare("select idx from thread_indexes where thread=?")
+	if err != nil {
+		log.Errorf("error in db prepare: %s", err)
+		return nil
+	}
+	defer stmt.Close()
+	var index int
+	if err := stmt.QueryRow(threadId).Scan(&index); err != nil {
+		return nil
+	}
+	return &repo.ThreadIndex{
+		ThreadId: threadId,
+		Index:    index,
+	}
+}
+
+func (c *ThreadIndexDB) List() []repo.ThreadIndex {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	rows, err := c.db.Query("select thread, idx from thread_indexes order by idx asc;")
+	if err != nil {
+		log.Errorf("error in db query: %s", err)
+		return nil
+	}
+	defer rows.Close()
+	var ret []repo.ThreadIndex
+	for rows.Next() {
+		var threadId string
+		var index int
+		if err := rows.Scan(&threadId, &index); err != nil {
+			log.Errorf("error in db scan: %s", err)
+			continue
+		}
+		ret = append(ret, repo.ThreadIndex{
+			ThreadId: threadId,
+			Index:    index,
+		})
+	}
+	return ret
+}
+
+func (c *ThreadIndexDB) Delete(threadId string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("delete from thread_indexes where thread=?", threadId)
+	return err
+}
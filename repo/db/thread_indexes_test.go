@@ -0,0 +1,68 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"testing"
+)
+
+var tidb repo.ThreadIndexStore
+
+func init() {
+	setupThreadIndexDB()
+}
+
+func setupThreadIndexDB() {
+	conn, _ := sql.Open("sqlite3", ":memory:")
+	initDatabaseTables(conn, "")
+	tidb = NewThreadIndexStore(conn, new(sync.Mutex))
+}
+
+func TestThreadIndexDB_Add(t *testing.T) {
+	err := tidb.Add(&repo.ThreadIndex{
+		ThreadId: "Qm123",
+		Index:    2,
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	stmt, err := tidb.PrepareQuery("select idx from thread_indexes where thread=?")
+	defer stmt.Close()
+	var index int
+	err = stmt.QueryRow("Qm123").Scan(&index)
+	if err != nil {
+		t.Error(err)
+	}
+	if index != 2 {
+		t.Errorf("expected 2 got %d", index)
+	}
+}
+
+func TestThreadIndexDB_Get(t *testing.T) {
+	if idx := tidb.Get("Qm123"); idx == nil || idx.Index != 2 {
+		t.Error("could not get thread index")
+	}
+	if tidb.Get("Qm456") != nil {
+		t.Error("got index for unknown thread")
+	}
+}
+
+func TestThreadIndexDB_List(t *testing.T) {
+	if err := tidb.Add(&repo.ThreadIndex{ThreadId: "Qm456", Index: 0}); err != nil {
+		t.Error(err)
+	}
+	list := tidb.List()
+	if len(list) != 2 || list[0].ThreadId != "Qm456" || list[1].ThreadId != "Qm123" {
+		t.Error("thread indexes were not listed by index")
+	}
+}
+
+func TestThreadIndexDB_Delete(t *testing.T) {
+	if err := tidb.Delete("Qm123"); err != nil {
+		t.Error(err)
+	}
+	if tidb.Get("Qm123") != nil {
+		t.Error("thread index was not deleted")
+	}
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+)
+
+type ThreadLocationDB struct {
+	modelStore
+}
+
+func NewThreadLocationStore(db *sql.DB, lock *sync.Mutex) repo.ThreadLocationStore {
+	return &ThreadLocationDB{modelStore{db, lock}}
+}
+
+func (c *ThreadLocationDB) Set(location *repo.ThreadLocation) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	stm := `insert or replace into thread_locations(thread, policy, grid) values(?,?,?)`
+	stmt, err := tx.Prepare(stm)
+	if err != nil {
+		log.Errorf("error in tx prepare: %s", err)
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		location.ThreadId,
+		int(location.Policy),
+		location.Grid,
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Errorf("error in db exec: %s", err)
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+func (c *ThreadLocationDB) Get(threadId string) *repo.ThreadLocation {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stmt, err := c.db.Prepare("select policy, grid from thread_locations where thread=?")
+	if err != nil {
+		log.Errorf("error in db prepare: %s", err)
+		return nil
+	}
+	defer stmt.Close()
+	var policy int
+	var grid float64
+	if err := stmt.QueryRow(threadId).Scan(&policy, &grid); err != nil {
+		return nil
+	}
+	return &repo.ThreadLocation{
+		ThreadId: threadId,
+		Policy:   repo.LocationPolicy(policy),
+		Grid:     grid,
+	}
+}
+
+func (c *ThreadLocationDB) Delete(threadId string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("delete from thread_locations where thread=?", threadId)
+	return err
+}
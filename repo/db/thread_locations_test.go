@@ -0,0 +1,73 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"testing"
+)
+
+var tldb repo.ThreadLocationStore
+
+func init() {
+	setupThreadLocationDB()
+}
+
+func setupThreadLocationDB() {
+	conn, _ := sql.Open("sqlite3", ":memory:")
+	initDatabaseTables(conn, "")
+	tldb = NewThreadLocationStore(conn, new(sync.Mutex))
+}
+
+func TestThreadLocationDB_Set(t *testing.T) {
+	err := tldb.Set(&repo.ThreadLocation{
+		ThreadId: "Qm123",
+		Policy:   repo.LocationCoarsen,
+		Grid:     0.5,
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	stmt, err := tldb.PrepareQuery("select policy from thread_locations where thread=?")
+	defer stmt.Close()
+	var policy int
+	err = stmt.QueryRow("Qm123").Scan(&policy)
+	if err != nil {
+		t.Error(err)
+	}
+	if repo.LocationPolicy(policy) != repo.LocationCoarsen {
+		t.Errorf("expected coarsen got %d", policy)
+	}
+}
+
+func TestThreadLocationDB_SetAgain(t *testing.T) {
+	err := tldb.Set(&repo.ThreadLocation{
+		ThreadId: "Qm123",
+		Policy:   repo.LocationStrip,
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	loc := tldb.Get("Qm123")
+	if loc == nil || loc.Policy != repo.LocationStrip || loc.Grid != 0 {
+		t.Error("thread location was not replaced")
+	}
+}
+
+func TestThreadLocationDB_Get(t *testing.T) {
+	if tldb.Get("Qm123") == nil {
+		t.Error("could not get thread location")
+	}
+	if tldb.Get("Qm456") != nil {
+		t.Error("got location for unknown thread")
+	}
+}
+
+func TestThreadLocationDB_Delete(t *testing.T) {
+	if err := tldb.Delete("Qm123"); err != nil {
+		t.Error(err)
+	}
+	if tldb.Get("Qm123") != nil {
+		t.Error("thread location was not deleted")
+	}
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type TimelineDB struct {
+	modelStore
+}
+
+func NewTimelineStore(db *sql.DB, lock *sync.Mutex) repo.TimelineStore {
+	return &TimelineDB{modelStore{db, lock}}
+}
+
+func (c *TimelineDB) Add(entry *repo.TimelineEntry) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	stm := `insert or replace into timeline(id, thread, target, taken, added) values(?,?,?,?,?)`
+	stmt, err := tx.Prepare(stm)
+	if err != nil {
+		log.Errorf("error in tx prepare: %s", err)
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		entry.BlockId,
+		entry.ThreadId,
+		entry.Target,
+		int(entry.Taken.Unix()),
+		int(entry.Added.Unix()),
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Errorf("error in db exec: %s", err)
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+// Groups counts distinct targets per local day, month, or year they were taken, newest first,
+// so a photo shared to several threads is only counted once.
+// Only groups with keys before the given key are listed, unless it's empty.
+func (c *TimelineDB) Groups(period repo.TimelinePeriod, before string, limit int, threadIds []string) []repo.TimelineGroup {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stm := "select strftime(?, taken, 'unixepoch', 'localtime') as key, count(distinct target) from timeline"
+	args := []interface{}{timelineFormat(period)}
+	if len(threadIds) > 0 {
+		stm += " where thread in (?" + strings.Repeat(",?", len(threadIds)-1) + ")"
+		for _, id := range threadIds {
+			args = append(args, id)
+		}
+	}
+	stm += " group by key"
+	if before != "" {
+		stm += " having key<?"
+		args = append(args, before)
+	}
+	stm += " order by key desc"
+	if limit > 0 {
+		stm += " limit " + strconv.Itoa(limit)
+	}
+	var ret []repo.TimelineGroup
+	rows, err := c.db.Query(stm+";", args...)
+	if err != nil {
+		log.Errorf("error in db query: %s", err)
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var count int
+		if err := rows.Scan(&key, &count); err != nil {
+			log.Errorf("error in db scan: %s", err)
+			continue
+		}
+		ret = append(ret, repo.TimelineGroup{Key: key, Count: count})
+	}
+	return ret
+}
+
+// List lists entries taken from a time up to but not including another, newest first.
+// Each target is listed once, by the block that most recently added it.
+func (c *TimelineDB) List(from time.Time, to time.Time, limit int, threadIds []string) []repo.TimelineEntry {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stm := "select id, thread, target, taken, max(added) from timeline where taken>=? and taken<?"
+	args := []interface{}{int(from.Unix()), int(to.Unix())}
+	if len(threadIds) > 0 {
+		stm += " and thread in (?" + strings.Repeat(",?", len(threadIds)-1) + ")"
+		for _, id := range threadIds {
+			args = append(args, id)
+		}
+	}
+	stm += " group by target order by taken desc"
+	if limit > 0 {
+		stm += " limit " + strconv.Itoa(limit)
+	}
+	return c.handleQuery(stm+";", args...)
+}
+
+func (c *TimelineDB) Delete(blockId string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("delete from timeline where id=?", blockId)
+	return err
+}
+
+func (c *TimelineDB) handleQuery(stm string, args ...interface{}) []repo.TimelineEntry {
+	var ret []repo.TimelineEntry
+	rows, err := c.db.Query(stm, args...)
+	if err != nil {
+		log.Errorf("error in db query: %s", err)
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, thread, target string
+		var takenInt, addedInt int64
+		if err := rows.Scan(&id, &thread, &target, &takenInt, &addedInt); err != nil {
+			log.Errorf("error in db scan: %s", err)
+			continue
+		}
+		ret = append(ret, repo.TimelineEntry{
+			BlockId:  id,
+			ThreadId: thread,
+			Target:   target,
+			Taken:    time.Unix(takenInt, 0),
+			Added:    time.Unix(addedInt, 0),
+		})
+	}
+	return ret
+}
+
+// timelineFormat returns the sqlite strftime format matching a period's key layout
+func timelineFormat(period repo.TimelinePeriod) string {
+	switch period {
+	case repo.TimelineMonth:
+		return "%Y-%m"
+	case repo.TimelineYear:
+		return "%Y"
+	}
+	return "%Y-%m-%d"
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"testing"
+	"time"
+)
+
+var timdb repo.TimelineStore
+
+func init() {
+	setupTimelineDB()
+}
+
+func setupTimelineDB() {
+	conn, _ := sql.Open("sqlite3", ":memory:")
+	initDatabaseTables(conn, "")
+	timdb = NewTimelineStore(conn, new(sync.Mutex))
+}
+
+func TestTimelineDB_Add(t *testing.T) {
+	entries := []repo.TimelineEntry{
+		{BlockId: "Qm1", ThreadId: "Qmthread1", Target: "Qmt1", Taken: time.Date(2018, 5, 4, 10, 0, 0, 0, time.Local)},
+		{BlockId: "Qm2", ThreadId: "Qmthread2", Target: "Qmt2", Taken: time.Date(2018, 5, 4, 12, 0, 0, 0, time.Local)},
+		{BlockId: "Qm3", ThreadId: "Qmthread1", Target: "Qmt3", Taken: time.Date(2018, 5, 1, 9, 0, 0, 0, time.Local)},
+		{BlockId: "Qm4", ThreadId: "Qmthread1", Target: "Qmt4", Taken: time.Date(2017, 12, 31, 23, 0, 0, 0, time.Local)},
+		{BlockId: "Qm5", ThreadId: "Qmthread2", Target: "Qmt1", Taken: time.Date(2018, 5, 4, 10, 0, 0, 0, time.Local)},
+	}
+	for _, entry := range entries {
+		e := entry
+		e.Added = time.Now()
+		if err := timdb.Add(&e); err != nil {
+			t.Error(err)
+		}
+	}
+	stmt, err := timdb.PrepareQuery("select target from timeline where id=?")
+	defer stmt.Close()
+	var target string
+	err = stmt.QueryRow("Qm2").Scan(&target)
+	if err != nil {
+		t.Error(err)
+	}
+	if target != "Qmt2" {
+		t.Errorf("expected Qmt2 got %s", target)
+	}
+}
+
+func TestTimelineDB_Groups(t *testing.T) {
+	days := timdb.Groups(repo.TimelineDay, "", -1, nil)
+	if len(days) != 3 || days[0].Key != "2018-05-04" || days[0].Count != 2 {
+		t.Errorf("bad day groups: %+v", days)
+	}
+	days = timdb.Groups(repo.TimelineDay, "2018-05-04", 1, nil)
+	if len(days) != 1 || days[0].Key != "2018-05-01" {
+		t.Errorf("bad day groups before key: %+v", days)
+	}
+	months := timdb.Groups(repo.TimelineMonth, "", -1, []string{"Qmthread1"})
+	if len(months) != 2 || months[0].Key != "2018-05" || months[0].Count != 2 {
+		t.Errorf("bad month groups: %+v", months)
+	}
+	years := timdb.Groups(repo.TimelineYear, "", -1, nil)
+	if len(years) != 2 || years[1].Key != "2017" || years[1].Count != 1 {
+		t.Errorf("bad year groups: %+v", years)
+	}
+}
+
+func TestTimelineDB_List(t *testing.T) {
+	from := time.Date(2018, 5, 4, 0, 0, 0, 0, time.Local)
+	list := timdb.List(from, from.AddDate(0, 0, 1), -1, nil)
+	if len(list) != 2 || list[0].BlockId != "Qm2" {
+		t.Error("list should return the day's targets once each, newest first")
+	}
+	list = timdb.List(from, from.AddDate(0, 0, 1), 1, []string{"Qmthread1"})
+	if len(list) != 1 || list[0].BlockId != "Qm1" {
+		t.Error("list should filter by thread")
+	}
+}
+
+func TestTimelineDB_Delete(t *testing.T) {
+	if err := timdb.Delete("Qm4"); err != nil {
+		t.Error(err)
+	}
+	if len(timdb.Groups(repo.TimelineYear, "", -1, nil)) != 1 {
+		t.Error("entry was not deleted")
+	}
+}
@@ -0,0 +1,111 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"time"
+)
+
+type UploadDB struct {
+	modelStore
+}
+
+func NewUploadStore(db *sql.DB, lock *sync.Mutex) repo.UploadStore {
+	return &UploadDB{modelStore{db, lock}}
+}
+
+func (c *UploadDB) Add(upload *repo.Upload) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	stm := `insert into uploads(id, path, target, attempts, status, date, updated) values(?,?,?,?,?,?,?)`
+	stmt, err := tx.Prepare(stm)
+	if err != nil {
+		log.Errorf("error in tx prepare: %s", err)
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		upload.Id,
+		upload.PayloadPath,
+		upload.Target,
+		upload.Attempts,
+		int(upload.Status),
+		int(upload.Date.Unix()),
+		int(upload.Updated.Unix()),
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Errorf("error in db exec: %s", err)
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+func (c *UploadDB) Get(id string) *repo.Upload {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	ret := c.handleQuery("select * from uploads where id='" + id + "';")
+	if len(ret) == 0 {
+		return nil
+	}
+	return &ret[0]
+}
+
+func (c *UploadDB) List(query string) []repo.Upload {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	q := ""
+	if query != "" {
+		q = " where " + query
+	}
+	return c.handleQuery("select * from uploads" + q + " order by date asc;")
+}
+
+func (c *UploadDB) Update(upload *repo.Upload) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("update uploads set attempts=?, status=?, updated=? where id=?",
+		upload.Attempts, int(upload.Status), int(upload.Updated.Unix()), upload.Id)
+	return err
+}
+
+func (c *UploadDB) Delete(id string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("delete from uploads where id=?", id)
+	return err
+}
+
+func (c *UploadDB) handleQuery(stm string) []repo.Upload {
+	var ret []repo.Upload
+	rows, err := c.db.Query(stm)
+	if err != nil {
+		log.Errorf("error in db query: %s", err)
+		return nil
+	}
+	for rows.Next() {
+		var id, path, target string
+		var attempts, statusInt, dateInt, updatedInt int
+		if err := rows.Scan(&id, &path, &target, &attempts, &statusInt, &dateInt, &updatedInt); err != nil {
+			log.Errorf("error in db scan: %s", err)
+			continue
+		}
+		upload := repo.Upload{
+			Id:          id,
+			PayloadPath: path,
+			Target:      target,
+			Attempts:    attempts,
+			Status:      repo.UploadStatus(statusInt),
+			Date:        time.Unix(int64(dateInt), 0),
+			Updated:     time.Unix(int64(updatedInt), 0),
+		}
+		ret = append(ret, upload)
+	}
+	return ret
+}
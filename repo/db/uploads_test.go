@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"testing"
+	"time"
+)
+
+var udb repo.UploadStore
+
+func init() {
+	setupUploadDB()
+}
+
+func setupUploadDB() {
+	conn, _ := sql.Open("sqlite3", ":memory:")
+	initDatabaseTables(conn, "")
+	udb = NewUploadStore(conn, new(sync.Mutex))
+}
+
+func TestUploadDB_Add(t *testing.T) {
+	err := udb.Add(&repo.Upload{
+		Id:          "boundary",
+		PayloadPath: "/tmp/boundary",
+		Target:      "https://api.textile.io",
+		Status:      repo.UploadPending,
+		Date:        time.Now(),
+		Updated:     time.Now(),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	stmt, err := udb.PrepareQuery("select id from uploads where id=?")
+	defer stmt.Close()
+	var id string
+	err = stmt.QueryRow("boundary").Scan(&id)
+	if err != nil {
+		t.Error(err)
+	}
+	if id != "boundary" {
+		t.Errorf(`expected "boundary" got %s`, id)
+	}
+}
+
+func TestUploadDB_Get(t *testing.T) {
+	upload := udb.Get("boundary")
+	if upload == nil {
+		t.Error("could not get upload")
+		return
+	}
+	if upload.PayloadPath != "/tmp/boundary" {
+		t.Errorf(`expected "/tmp/boundary" got %s`, upload.PayloadPath)
+	}
+}
+
+func TestUploadDB_Update(t *testing.T) {
+	upload := udb.Get("boundary")
+	upload.Attempts = 3
+	upload.Status = repo.UploadFailed
+	if err := udb.Update(upload); err != nil {
+		t.Error(err)
+	}
+	upload = udb.Get("boundary")
+	if upload.Attempts != 3 || upload.Status != repo.UploadFailed {
+		t.Error("upload was not updated")
+	}
+}
+
+func TestUploadDB_List(t *testing.T) {
+	setupUploadDB()
+	for _, id := range []string{"a", "b"} {
+		err := udb.Add(&repo.Upload{
+			Id:          id,
+			PayloadPath: "/tmp/" + id,
+			Target:      "https://api.textile.io",
+			Status:      repo.UploadPending,
+			Date:        time.Now(),
+			Updated:     time.Now(),
+		})
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	b := udb.Get("b")
+	b.Status = repo.UploadFailed
+	udb.Update(b)
+	if len(udb.List("")) != 2 {
+		t.Error("returned incorrect number of uploads")
+	}
+	pending := udb.List("status=0")
+	if len(pending) != 1 || pending[0].Id != "a" {
+		t.Error("returned incorrect pending uploads")
+	}
+}
+
+func TestUploadDB_Delete(t *testing.T) {
+	if err := udb.Delete("a"); err != nil {
+		t.Error(err)
+	}
+	if udb.Get("a") != nil {
+		t.Error("upload was not deleted")
+	}
+}
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"time"
+)
+
+type WatchDB struct {
+	modelStore
+}
+
+func NewWatchStore(db *sql.DB, lock *sync.Mutex) repo.WatchStore {
+	return &WatchDB{modelStore{db, lock}}
+}
+
+func (c *WatchDB) Add(watch *repo.Watch) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	stm := `insert into watches(path, thread, date) values(?,?,?)`
+	stmt, err := tx.Prepare(stm)
+	if err != nil {
+		log.Errorf("error in tx prepare: %s", err)
+		return err
+	}
+	defer stmt.Close()
+	_, err = stmt.Exec(
+		watch.Path,
+		watch.ThreadId,
+		int(watch.Date.Unix()),
+	)
+	if err != nil {
+		tx.Rollback()
+		log.Errorf("error in db exec: %s", err)
+		return err
+	}
+	tx.Commit()
+	return nil
+}
+
+func (c *WatchDB) Get(path string) *repo.Watch {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	stmt, err := c.db.Prepare("select path, thread, date from watches where path=?")
+	if err != nil {
+		log.Errorf("error in db prepare: %s", err)
+		return nil
+	}
+	defer stmt.Close()
+	var thread string
+	var dateInt int
+	if err := stmt.QueryRow(path).Scan(&path, &thread, &dateInt); err != nil {
+		return nil
+	}
+	return &repo.Watch{
+		Path:     path,
+		ThreadId: thread,
+		Date:     time.Unix(int64(dateInt), 0),
+	}
+}
+
+func (c *WatchDB) List() []repo.Watch {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var ret []repo.Watch
+	rows, err := c.db.Query("select path, thread, date from watches order by date asc;")
+	if err != nil {
+		log.Errorf("error in db query: %s", err)
+		return nil
+	}
+	for rows.Next() {
+		var path, thread string
+		var dateInt int
+		if err := rows.Scan(&path, &thread, &dateInt); err != nil {
+			log.Errorf("error in db scan: %s", err)
+			continue
+		}
+		ret = append(ret, repo.Watch{
+			Path:     path,
+			ThreadId: thread,
+			Date:     time.Unix(int64(dateInt), 0),
+		})
+	}
+	return ret
+}
+
+func (c *WatchDB) Delete(path string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, err := c.db.Exec("delete from watches where path=?", path)
+	return err
+}
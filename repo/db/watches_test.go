@@ -0,0 +1,67 @@
+package db
+
+import (
+	"database/sql"
+	"github.com/textileio/textile-go/repo"
+	"sync"
+	"testing"
+	"time"
+)
+
+var wdb repo.WatchStore
+
+func init() {
+	setupWatchDB()
+}
+
+func setupWatchDB() {
+	conn, _ := sql.Open("sqlite3", ":memory:")
+	initDatabaseTables(conn, "")
+	wdb = NewWatchStore(conn, new(sync.Mutex))
+}
+
+func TestWatchDB_Add(t *testing.T) {
+	err := wdb.Add(&repo.Watch{
+		Path:     "/home/me/photos",
+		ThreadId: "Qm123",
+		Date:     time.Now(),
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	stmt, err := wdb.PrepareQuery("select thread from watches where path=?")
+	defer stmt.Close()
+	var thread string
+	err = stmt.QueryRow("/home/me/photos").Scan(&thread)
+	if err != nil {
+		t.Error(err)
+	}
+	if thread != "Qm123" {
+		t.Errorf(`expected "Qm123" got %s`, thread)
+	}
+}
+
+func TestWatchDB_Get(t *testing.T) {
+	watch := wdb.Get("/home/me/photos")
+	if watch == nil || watch.ThreadId != "Qm123" {
+		t.Error("could not get watch")
+	}
+	if wdb.Get("/nope") != nil {
+		t.Error("got watch for unknown path")
+	}
+}
+
+func TestWatchDB_List(t *testing.T) {
+	if len(wdb.List()) != 1 {
+		t.Error("returned incorrect number of watches")
+	}
+}
+
+func TestWatchDB_Delete(t *testing.T) {
+	if err := wdb.Delete("/home/me/photos"); err != nil {
+		t.Error(err)
+	}
+	if len(wdb.List()) != 0 {
+		t.Error("watch was not deleted")
+	}
+}
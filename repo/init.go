@@ -19,7 +19,7 @@ var log = logging.MustGetLogger("repo")
 
 var ErrRepoExists = errors.New("repo not empty, reinitializing would overwrite your keys")
 
-func DoInit(repoRoot string, isMobile bool, version string,
+func DoInit(repoRoot string, isMobile bool, version string, password string,
 	initDB func(string) error, initConfig func(time.Time, string) error, initProfile func() error) error {
 	if err := checkWriteable(repoRoot); err != nil {
 		return err
@@ -46,7 +46,7 @@ func DoInit(repoRoot string, isMobile bool, version string,
 		return err
 	}
 
-	if err := initDB(""); err != nil {
+	if err := initDB(password); err != nil {
 		return err
 	}
 
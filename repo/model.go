@@ -29,8 +29,179 @@ const (
 	PhotoBlock
 	CommentBlock
 	LikeBlock
+	VersionBlock
 )
 
 func (bt BlockType) Bytes() []byte {
 	return []byte(strconv.Itoa(int(bt)))
 }
+
+type PhotoVersion struct {
+	BlockId  string    `json:"block_id"`
+	ThreadId string    `json:"thread_id"`
+	Original string    `json:"original"`
+	Target   string    `json:"target"`
+	Date     time.Time `json:"date"`
+}
+
+type Upload struct {
+	Id          string       `json:"id"`
+	PayloadPath string       `json:"payload_path"`
+	Target      string       `json:"target"`
+	Attempts    int          `json:"attempts"`
+	Status      UploadStatus `json:"status"`
+	Date        time.Time    `json:"date"`
+	Updated     time.Time    `json:"updated"`
+}
+
+type UploadStatus int
+
+const (
+	UploadPending UploadStatus = iota
+	UploadDone
+	UploadFailed
+)
+
+func (us UploadStatus) String() string {
+	switch us {
+	case UploadPending:
+		return "pending"
+	case UploadDone:
+		return "done"
+	case UploadFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+type PhotoHash struct {
+	Hash   string    `json:"hash"`
+	Target string    `json:"target"`
+	Key    []byte    `json:"key"`
+	Date   time.Time `json:"date"`
+}
+
+type PhotoVariant struct {
+	Target  string         `json:"target"`
+	Policy  LocationPolicy `json:"policy"`
+	Grid    float64        `json:"grid"`
+	Variant string         `json:"variant"`
+	Date    time.Time      `json:"date"`
+}
+
+type PerceptualHash struct {
+	Target string    `json:"target"`
+	Hash   uint64    `json:"hash"`
+	Date   time.Time `json:"date"`
+}
+
+type Watch struct {
+	Path     string    `json:"path"`
+	ThreadId string    `json:"thread_id"`
+	Date     time.Time `json:"date"`
+}
+
+type ThreadLocation struct {
+	ThreadId string         `json:"thread_id"`
+	Policy   LocationPolicy `json:"policy"`
+	Grid     float64        `json:"grid"`
+}
+
+type ThreadIndex struct {
+	ThreadId string `json:"thread_id"`
+	Index    int    `json:"index"`
+}
+
+type LocationPolicy int
+
+const (
+	LocationKeep LocationPolicy = iota
+	LocationCoarsen
+	LocationStrip
+)
+
+func (lp LocationPolicy) String() string {
+	switch lp {
+	case LocationKeep:
+		return "keep"
+	case LocationCoarsen:
+		return "coarsen"
+	case LocationStrip:
+		return "strip"
+	}
+	return "unknown"
+}
+
+type SearchEntry struct {
+	BlockId  string    `json:"block_id"`
+	ThreadId string    `json:"thread_id"`
+	Target   string    `json:"target"`
+	Caption  string    `json:"caption"`
+	Name     string    `json:"name"`
+	Username string    `json:"username"`
+	Camera   string    `json:"camera"`
+	Date     time.Time `json:"date"`
+	Created  time.Time `json:"created"`
+}
+
+type SearchQuery struct {
+	Text     string    `json:"text"`
+	ThreadId string    `json:"thread_id"`
+	Username string    `json:"username"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Limit    int       `json:"limit"`
+}
+
+type PhotoLocation struct {
+	BlockId   string    `json:"block_id"`
+	ThreadId  string    `json:"thread_id"`
+	Target    string    `json:"target"`
+	Latitude  float64   `json:"lat"`
+	Longitude float64   `json:"lon"`
+	Date      time.Time `json:"date"`
+}
+
+type TimelineEntry struct {
+	BlockId  string    `json:"block_id"`
+	ThreadId string    `json:"thread_id"`
+	Target   string    `json:"target"`
+	Taken    time.Time `json:"taken"`
+	Added    time.Time `json:"added"`
+}
+
+type TimelineGroup struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+type TimelinePeriod int
+
+const (
+	TimelineDay TimelinePeriod = iota
+	TimelineMonth
+	TimelineYear
+)
+
+func (tp TimelinePeriod) String() string {
+	switch tp {
+	case TimelineDay:
+		return "day"
+	case TimelineMonth:
+		return "month"
+	case TimelineYear:
+		return "year"
+	}
+	return "unknown"
+}
+
+// Layout returns the time layout of the period's group keys
+func (tp TimelinePeriod) Layout() string {
+	switch tp {
+	case TimelineMonth:
+		return "2006-01"
+	case TimelineYear:
+		return "2006"
+	}
+	return "2006-01-02"
+}
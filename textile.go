@@ -13,11 +13,16 @@ import (
 	"gopkg.in/abiosoft/ishell.v2"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type Opts struct {
-	Version bool   `short:"v" long:"version" description:"print the version number and exit"`
-	DataDir string `short:"d" long:"datadir" description:"specify the data directory to be used"`
+	Version    bool   `short:"v" long:"version" description:"print the version number and exit"`
+	DataDir    string `short:"d" long:"datadir" description:"specify the data directory to be used"`
+	RawKeys    bool   `long:"gateway-raw-keys" description:"allow the gateway to decrypt with raw file keys in urls"`
+	MaxUpload  int64  `long:"api-max-upload" description:"largest photo upload the rest api accepts, in bytes"`
+	Restore    bool   `long:"restore" description:"create the repo from an existing mnemonic phrase, prompting for it and its passphrase"`
+	Passphrase bool   `long:"passphrase" description:"prompt for a passphrase protecting the mnemonic phrase of a new repo"`
 }
 
 var Options Opts
@@ -129,13 +134,18 @@ func main() {
 		photoCmd := &ishell.Cmd{
 			Name:     "photo",
 			Help:     "manage photos",
-			LongHelp: "Add, list, and get info about photos.",
+			LongHelp: "Add, import, list, edit, find near duplicates of, and get info about photos.",
 		}
 		photoCmd.AddCmd(&ishell.Cmd{
 			Name: "add",
 			Help: "add a new photo (default thread is \"#default\")",
 			Func: cmd.AddPhoto,
 		})
+		photoCmd.AddCmd(&ishell.Cmd{
+			Name: "import",
+			Help: "add all photos in a directory (default thread is \"#default\")",
+			Func: cmd.ImportPhotos,
+		})
 		photoCmd.AddCmd(&ishell.Cmd{
 			Name: "share",
 			Help: "share a photo to a different thread",
@@ -161,19 +171,44 @@ func main() {
 			Help: "list photos from a thread (defaults to \"#default\")",
 			Func: cmd.ListPhotos,
 		})
+		photoCmd.AddCmd(&ishell.Cmd{
+			Name: "edit",
+			Help: "add an edited version of a photo in a thread (rotate:<90|180|270>, crop:<x>,<y>,<w>,<h>, flip:<h|v>)",
+			Func: cmd.EditPhoto,
+		})
+		photoCmd.AddCmd(&ishell.Cmd{
+			Name: "versions",
+			Help: "list the edited versions of a photo in a thread (defaults to \"#default\")",
+			Func: cmd.ListPhotoVersions,
+		})
+		photoCmd.AddCmd(&ishell.Cmd{
+			Name: "similar",
+			Help: "list photos that look like a photo, within an optional hash distance (default 10)",
+			Func: cmd.ListSimilarPhotos,
+		})
+		photoCmd.AddCmd(&ishell.Cmd{
+			Name: "dupes",
+			Help: "list groups of near-duplicate photos in a thread (defaults to \"#default\"), within an optional hash distance",
+			Func: cmd.ListSimilarClusters,
+		})
 		shell.AddCmd(photoCmd)
 	}
 	{
 		threadCmd := &ishell.Cmd{
 			Name:     "thread",
 			Help:     "manage photo threads",
-			LongHelp: "Add, list, enable, disable, and get info about photo threads.",
+			LongHelp: "Add, list, enable, disable, set location policies for, and get info about photo threads.",
 		}
 		threadCmd.AddCmd(&ishell.Cmd{
 			Name: "add",
 			Help: "add a new thread",
 			Func: cmd.CreateThread,
 		})
+		threadCmd.AddCmd(&ishell.Cmd{
+			Name: "restore",
+			Help: "restore a thread derived from the master seed at an index, or all published threads",
+			Func: cmd.RestoreThread,
+		})
 		threadCmd.AddCmd(&ishell.Cmd{
 			Name: "ls",
 			Help: "list threads",
@@ -199,19 +234,145 @@ func main() {
 			Help: "list peers",
 			Func: cmd.ListThreadPeers,
 		})
+		threadCmd.AddCmd(&ishell.Cmd{
+			Name: "location",
+			Help: "show or set how photo locations are shared (keep, coarsen [degrees], strip, or default)",
+			Func: cmd.ThreadLocationPolicy,
+		})
 		shell.AddCmd(threadCmd)
 	}
+	{
+		repoCmd := &ishell.Cmd{
+			Name:     "repo",
+			Help:     "manage the local repo",
+			LongHelp: "Encrypt, unlock, lock, change the password of, and garbage collect the local repo.",
+		}
+		repoCmd.AddCmd(&ishell.Cmd{
+			Name: "encrypt",
+			Help: "encrypt the datastore with a password (node must be stopped)",
+			Func: cmd.EncryptRepo,
+		})
+		repoCmd.AddCmd(&ishell.Cmd{
+			Name: "unlock",
+			Help: "unlock an encrypted datastore",
+			Func: cmd.UnlockRepo,
+		})
+		repoCmd.AddCmd(&ishell.Cmd{
+			Name: "lock",
+			Help: "lock an encrypted datastore (node must be stopped)",
+			Func: cmd.LockRepo,
+		})
+		repoCmd.AddCmd(&ishell.Cmd{
+			Name: "passwd",
+			Help: "change the datastore password",
+			Func: cmd.ChangeRepoPassword,
+		})
+		repoCmd.AddCmd(&ishell.Cmd{
+			Name: "gc",
+			Help: "remove unreferenced content, or schedule with an interval (e.g. 24h, off)",
+			Func: cmd.CollectGarbage,
+		})
+		repoCmd.AddCmd(&ishell.Cmd{
+			Name: "location",
+			Help: "show or set the default photo location policy for threads (keep, coarsen [degrees], strip)",
+			Func: cmd.DefaultLocationPolicy,
+		})
+		shell.AddCmd(repoCmd)
+	}
+	{
+		searchCmd := &ishell.Cmd{
+			Name:     "search",
+			Help:     "search photos by caption, name, username, and camera",
+			LongHelp: "Search photos in all threads. Filter with thread:<name>, by:<username>, from:<yyyy-mm-dd>, to:<yyyy-mm-dd>, and limit:<n>.",
+			Func:     cmd.Search,
+		}
+		searchCmd.AddCmd(&ishell.Cmd{
+			Name: "reindex",
+			Help: "rebuild the search, timeline, and location indexes from all thread photos",
+			Func: cmd.RebuildSearchIndex,
+		})
+		shell.AddCmd(searchCmd)
+	}
+	shell.AddCmd(&ishell.Cmd{
+		Name:     "timeline",
+		Help:     "list photo counts by the day, month, or year they were taken",
+		LongHelp: "List photos in all threads grouped by day, month (default), or year taken, newest first. Filter with thread:<name>, and pass the printed cursor to see older groups.",
+		Func:     cmd.Timeline,
+	})
+	{
+		watchCmd := &ishell.Cmd{
+			Name:     "watch",
+			Help:     "manage watched folders",
+			LongHelp: "Add, list, and remove folders whose new photos are added to a thread.",
+		}
+		watchCmd.AddCmd(&ishell.Cmd{
+			Name: "add",
+			Help: "add new photos in a folder to a thread (default thread is \"#default\")",
+			Func: cmd.AddWatch,
+		})
+		watchCmd.AddCmd(&ishell.Cmd{
+			Name: "ls",
+			Help: "list watched folders",
+			Func: cmd.ListWatches,
+		})
+		watchCmd.AddCmd(&ishell.Cmd{
+			Name: "rm",
+			Help: "stop watching a folder",
+			Func: cmd.RemoveWatch,
+		})
+		shell.AddCmd(watchCmd)
+	}
+	{
+		uploadCmd := &ishell.Cmd{
+			Name:     "upload",
+			Help:     "manage queued uploads",
+			LongHelp: "List, flush, and retry uploads queued for the remote pinning service.",
+		}
+		uploadCmd.AddCmd(&ishell.Cmd{
+			Name: "ls",
+			Help: "list queued and failed uploads",
+			Func: cmd.ListUploads,
+		})
+		uploadCmd.AddCmd(&ishell.Cmd{
+			Name: "flush",
+			Help: "send due uploads now",
+			Func: cmd.FlushUploads,
+		})
+		uploadCmd.AddCmd(&ishell.Cmd{
+			Name: "retry",
+			Help: "requeue a failed upload",
+			Func: cmd.RetryUpload,
+		})
+		shell.AddCmd(uploadCmd)
+	}
+
+	// the master mnemonic and passphrase are only used when creating the repo
+	var mnemonic *string
+	var passphrase string
+	if Options.Restore {
+		shell.Print("mnemonic phrase: ")
+		mnem := strings.TrimSpace(shell.ReadLine())
+		mnemonic = &mnem
+	}
+	if Options.Restore || Options.Passphrase {
+		shell.Print("mnemonic passphrase (optional): ")
+		passphrase = shell.ReadPassword()
+	}
 
 	// create and start a desktop textile node
 	// TODO: darwin should use App. Support dir, not home dir
 	// TODO: make api url configuratable via an option flag
 	config := core.NodeConfig{
-		LogLevel: logging.DEBUG,
-		LogFiles: true,
+		LogLevel:       logging.DEBUG,
+		LogFiles:       true,
+		GatewayRawKeys: Options.RawKeys,
+		ApiMaxUpload:   Options.MaxUpload,
 		WalletConfig: wallet.Config{
-			RepoPath:   dataDir,
-			CentralAPI: "https://api.textile.io",
-			IsMobile:   false,
+			RepoPath:         dataDir,
+			CentralAPI:       "https://api.textile.io",
+			IsMobile:         false,
+			MasterMnemonic:   mnemonic,
+			MasterPassphrase: passphrase,
 		},
 	}
 	node, err := core.NewNode(config)
@@ -221,6 +382,28 @@ func main() {
 	}
 	core.Node = node
 
+	// a new repo's mnemonic is only shown once
+	if mnem := core.Node.Wallet.Mnemonic(); mnem != "" {
+		red := color.New(color.FgRed).SprintFunc()
+		shell.Println(red("write down your mnemonic phrase, it's needed to restore this repo:"))
+		shell.Println(red(mnem))
+	}
+
+	// report upload progress
+	cmd.SubscribeUploads(shell)
+
+	// unlock an encrypted datastore
+	for i := 0; core.Node.Wallet.Locked(); i++ {
+		if i == 3 {
+			shell.Println(errors.New("too many failed unlock attempts"))
+			return
+		}
+		shell.Print("datastore password: ")
+		if err := core.Node.Wallet.Unlock(shell.ReadPassword()); err != nil {
+			shell.Println(err)
+		}
+	}
+
 	// auto start it
 	if err := start(shell); err != nil {
 		shell.Println(fmt.Errorf("start desktop node failed: %s", err))
@@ -271,5 +454,6 @@ _/  |_  ____ ___  ____/  |_|__|  |   ____
 	shell.Println("")
 	shell.Println("textile node v" + core.Version)
 	shell.Printf("using repo at %s\n", dataDir)
+	shell.Printf("rest api at %s, token in %s\n", core.Node.GetApiAddress(), filepath.Join(dataDir, core.ApiTokenFile))
 	shell.Println("type `help` for available commands")
 }
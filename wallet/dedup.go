@@ -0,0 +1,59 @@
+package wallet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	trepo "github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet/model"
+	"gx/ipfs/QmcZfnkapfECQGcLZaf9B79NRg7cRa9EnZh4LSbkCzwNvY/go-cid"
+	"io"
+	"time"
+)
+
+// hashPhoto returns the hex encoded sha256 of the plaintext photo
+func hashPhoto(reader io.ReadSeeker) (string, error) {
+	if _, err := reader.Seek(0, 0); err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	if _, err := reader.Seek(0, 0); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// existingPhoto returns the target already added for a photo hash, if it's still pinned
+func (w *Wallet) existingPhoto(hash string) *model.AddResult {
+	existing := w.datastore.PhotoHashes().Get(hash)
+	if existing == nil {
+		return nil
+	}
+	c, err := cid.Decode(existing.Target)
+	if err != nil {
+		log.Warningf("bad photo hash target %s: %s", existing.Target, err)
+		return nil
+	}
+	if _, pinned, err := w.ipfs.Pinning.IsPinned(c); err != nil || !pinned {
+		// the target was collected, forget it
+		if err := w.datastore.PhotoHashes().DeleteByTarget(existing.Target); err != nil {
+			log.Errorf("error removing stale photo hash: %s", err)
+		}
+		return nil
+	}
+	w.markRecent(existing.Target)
+	log.Debugf("photo already added as %s", existing.Target)
+	return &model.AddResult{Id: existing.Target, Key: existing.Key, Duplicate: true}
+}
+
+// indexPhotoHash records a newly added target so repeat adds can find it
+func (w *Wallet) indexPhotoHash(hash string, id string, key []byte) error {
+	return w.datastore.PhotoHashes().Add(&trepo.PhotoHash{
+		Hash:   hash,
+		Target: id,
+		Key:    key,
+		Date:   time.Now(),
+	})
+}
@@ -0,0 +1,154 @@
+package wallet
+
+import (
+	"context"
+	"github.com/textileio/textile-go/wallet/model"
+	"gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/core/corerepo"
+	"gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/pin/gc"
+	ft "gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/unixfs"
+	"gx/ipfs/QmcZfnkapfECQGcLZaf9B79NRg7cRa9EnZh4LSbkCzwNvY/go-cid"
+	"time"
+)
+
+// gcLinkTimeout bounds how long we look for the links of a live node
+const gcLinkTimeout = time.Second * 5
+
+// gcGracePeriod protects freshly added photos that are not yet in a thread
+const gcGracePeriod = time.Hour
+
+// GC unpins all content not referenced by an indexed block and runs the ipfs repo gc
+func (w *Wallet) GC() (*model.GCResult, error) {
+	if !w.started {
+		return nil, ErrStopped
+	}
+	if err := w.touchDatastore(); err != nil {
+		return nil, err
+	}
+	w.gcMux.Lock()
+	defer w.gcMux.Unlock()
+	log.Info("collecting garbage...")
+	ctx := w.ipfs.Context()
+
+	// compute the live set
+	live, roots := w.liveSet(ctx)
+
+	// unpin everything else
+	result := &model.GCResult{}
+	for _, c := range w.ipfs.Pinning.RecursiveKeys() {
+		if live[c.KeyString()] {
+			continue
+		}
+		if err := w.ipfs.Pinning.Unpin(ctx, c, true); err != nil {
+			log.Warningf("error unpinning %s: %s", c.String(), err)
+			continue
+		}
+		result.Unpinned++
+	}
+	for _, c := range w.ipfs.Pinning.DirectKeys() {
+		if live[c.KeyString()] {
+			continue
+		}
+		if err := w.ipfs.Pinning.Unpin(ctx, c, false); err != nil {
+			log.Warningf("error unpinning %s: %s", c.String(), err)
+			continue
+		}
+
+		// targets are direct-pinned, so a dropped one can no longer dedupe adds
+		if err := w.datastore.PhotoHashes().DeleteByTarget(c.Hash().B58String()); err != nil {
+			log.Warningf("error removing photo hash for %s: %s", c.String(), err)
+		}
+		if err := w.datastore.PhotoVariants().DeleteByTarget(c.Hash().B58String()); err != nil {
+			log.Warningf("error removing photo variants for %s: %s", c.String(), err)
+		}
+		result.Unpinned++
+	}
+	if err := w.ipfs.Pinning.Flush(); err != nil {
+		return nil, err
+	}
+
+	// run the repo gc
+	before, err := w.ipfs.Repo.GetStorageUsage()
+	if err != nil {
+		return nil, err
+	}
+	// targets are only direct-pinned, so their files and chunks are kept as best effort roots
+	mfsRoots, err := corerepo.BestEffortRoots(w.ipfs.FilesRoot)
+	if err != nil {
+		return nil, err
+	}
+	gcOut := gc.GC(ctx, w.ipfs.Blockstore, w.ipfs.Repo.Datastore(), w.ipfs.Pinning, append(mfsRoots, roots...))
+	if err := corerepo.CollectResult(ctx, gcOut, func(*cid.Cid) {
+		result.Removed++
+	}); err != nil {
+		return nil, err
+	}
+	after, err := w.ipfs.Repo.GetStorageUsage()
+	if err != nil {
+		return nil, err
+	}
+	if before > after {
+		result.Freed = before - after
+	}
+
+	log.Infof("unpinned %d, removed %d blocks, freed %d bytes", result.Unpinned, result.Removed, result.Freed)
+	return result, nil
+}
+
+// liveSet returns the keys of all blocks, targets, and their files,
+// along with the roots whose full dags must survive the repo gc
+func (w *Wallet) liveSet(ctx context.Context) (map[string]bool, []*cid.Cid) {
+	live := make(map[string]bool)
+	var cids []*cid.Cid
+
+	// keep the empty dir pinned when initializing the ipns keyspace
+	live[ft.EmptyDirNode().Cid().KeyString()] = true
+
+	var roots []string
+	for _, block := range w.datastore.Blocks().List("", -1, "") {
+		roots = append(roots, block.Id, block.Target)
+	}
+	if index, err := w.datastore.Profile().GetPublishedThreadIndex(); err == nil && index != "" {
+		roots = append(roots, index)
+	}
+	w.recentMux.Lock()
+	for id, added := range w.recent {
+		if time.Since(added) > gcGracePeriod {
+			delete(w.recent, id)
+			continue
+		}
+		roots = append(roots, id)
+	}
+	w.recentMux.Unlock()
+
+	for _, root := range roots {
+		c, err := cid.Decode(root)
+		if err != nil {
+			log.Warningf("bad live root %s: %s", root, err)
+			continue
+		}
+		live[c.KeyString()] = true
+		cids = append(cids, c)
+
+		// block and target files are pinned individually
+		lctx, cancel := context.WithTimeout(ctx, gcLinkTimeout)
+		node, err := w.ipfs.DAG.Get(lctx, c)
+		cancel()
+		if err != nil {
+			continue
+		}
+		for _, link := range node.Links() {
+			live[link.Cid.KeyString()] = true
+		}
+	}
+	return live, cids
+}
+
+// markRecent protects a newly added target from gc until it's added to a thread
+func (w *Wallet) markRecent(id string) {
+	w.recentMux.Lock()
+	defer w.recentMux.Unlock()
+	if w.recent == nil {
+		w.recent = make(map[string]time.Time)
+	}
+	w.recent[id] = time.Now()
+}
@@ -0,0 +1,81 @@
+package wallet
+
+import (
+	"errors"
+	trepo "github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet/model"
+	"github.com/textileio/textile-go/wallet/util"
+	"sort"
+)
+
+var ErrBadBounds = errors.New("bounds must be within -90, -180 and 90, 180")
+var ErrBadZoom = errors.New("zoom must be between 0 and 22")
+
+// PhotosInBounds returns indexed photo locations inside a bounding box, newest first,
+// optionally limited to some thread ids. A min longitude greater than the max crosses the antimeridian.
+func (w *Wallet) PhotosInBounds(minLat float64, minLon float64, maxLat float64, maxLon float64, threads []string) ([]trepo.PhotoLocation, error) {
+	if err := checkBounds(minLat, minLon, maxLat, maxLon); err != nil {
+		return nil, err
+	}
+	if err := w.touchDatastore(); err != nil {
+		return nil, err
+	}
+	return w.datastore.PhotoLocations().InBounds(minLat, minLon, maxLat, maxLon, threads), nil
+}
+
+// PhotoClusters counts photos inside a bounding box per map tile at a zoom level.
+// Each cluster is located at the average of its photos and represented by the newest one.
+func (w *Wallet) PhotoClusters(zoom int, minLat float64, minLon float64, maxLat float64, maxLon float64, threads []string) ([]model.PhotoCluster, error) {
+	if zoom < 0 || zoom > util.MaxTileZoom {
+		return nil, ErrBadZoom
+	}
+	locations, err := w.PhotosInBounds(minLat, minLon, maxLat, maxLon, threads)
+	if err != nil {
+		return nil, err
+	}
+
+	// locations are newest first, so the first in a tile represents it
+	tiles := make(map[[2]int]*model.PhotoCluster)
+	lons := make(map[*model.PhotoCluster][]float64)
+	var clusters []*model.PhotoCluster
+	for _, loc := range locations {
+		x, y := util.TileForLocation(loc.Latitude, loc.Longitude, zoom)
+		cluster, ok := tiles[[2]int{x, y}]
+		if !ok {
+			cluster = &model.PhotoCluster{
+				Zoom:    zoom,
+				X:       x,
+				Y:       y,
+				BlockId: loc.BlockId,
+				Target:  loc.Target,
+			}
+			tiles[[2]int{x, y}] = cluster
+			clusters = append(clusters, cluster)
+		}
+		cluster.Count++
+		cluster.Latitude += loc.Latitude
+		lons[cluster] = append(lons[cluster], loc.Longitude)
+	}
+
+	result := make([]model.PhotoCluster, len(clusters))
+	for i, cluster := range clusters {
+		cluster.Latitude /= float64(cluster.Count)
+		cluster.Longitude = util.MeanLongitude(lons[cluster])
+		result[i] = *cluster
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Count > result[j].Count
+	})
+	return result, nil
+}
+
+// checkBounds validates a bounding box
+func checkBounds(minLat float64, minLon float64, maxLat float64, maxLon float64) error {
+	if minLat < -90 || maxLat > 90 || minLat > maxLat {
+		return ErrBadBounds
+	}
+	if minLon < -180 || minLon > 180 || maxLon < -180 || maxLon > 180 {
+		return ErrBadBounds
+	}
+	return nil
+}
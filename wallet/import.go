@@ -0,0 +1,211 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"github.com/textileio/textile-go/wallet/model"
+	"github.com/textileio/textile-go/wallet/thread"
+	"github.com/textileio/textile-go/wallet/util"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultImportParallelism is the number of photos encrypted and added at once
+const DefaultImportParallelism = 4
+
+var ErrImportCanceled = errors.New("import canceled")
+
+// ImportOptions configures a directory import
+type ImportOptions struct {
+	// Caption is added to every block
+	Caption string
+	// Parallelism bounds concurrent adds, defaults to DefaultImportParallelism
+	Parallelism int
+	// Progress receives an update per photo and phase, it must be drained
+	Progress chan<- model.ImportUpdate
+	// Cancel stops the import when closed, it can be resumed by importing again
+	Cancel <-chan struct{}
+}
+
+// importItem is a photo found during an import
+type importItem struct {
+	path  string
+	date  time.Time
+	added *model.AddResult
+}
+
+// ImportDirectory adds all supported photos under path, then adds them to a thread in the order
+// they were taken. Photos and blocks are deduplicated, so an interrupted import resumes when re-run.
+func (w *Wallet) ImportDirectory(path string, thrd *thread.Thread, opts ImportOptions) (*model.ImportResult, error) {
+	if !w.started {
+		return nil, ErrStopped
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New(fmt.Sprintf("not a directory: %s", path))
+	}
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = DefaultImportParallelism
+	}
+	cancel := opts.Cancel
+	done := w.done
+
+	// find photos
+	paths, err := findPhotos(path)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.ImportResult{Total: len(paths)}
+	log.Infof("importing %d photos from %s to thread %s", len(paths), path, thrd.Name)
+	notify := func(update model.ImportUpdate) bool {
+		if opts.Progress == nil {
+			return true
+		}
+		select {
+		case opts.Progress <- update:
+			return true
+		case <-cancel:
+			return false
+		case <-done:
+			return false
+		}
+	}
+
+	// add photos with bounded parallelism
+	items := make([]*importItem, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+	count := 0
+	for i := 0; i < opts.Parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				item, err := w.importPhoto(paths[index])
+				update := model.ImportUpdate{Phase: "add", Path: paths[index], Total: len(paths)}
+				mux.Lock()
+				count++
+				update.Done = count
+				if err != nil {
+					log.Warningf("error importing %s: %s", paths[index], err)
+					result.Failed = append(result.Failed, paths[index])
+					update.Status, update.Error = "failed", err.Error()
+				} else {
+					items[index] = item
+					update.Id, update.Status = item.added.Id, "added"
+					if item.added.Duplicate {
+						update.Status = "existing"
+					}
+				}
+				mux.Unlock()
+				notify(update)
+			}
+		}()
+	}
+	canceled := false
+feed:
+	for i := range paths {
+		select {
+		case jobs <- i:
+		case <-cancel:
+			canceled = true
+			break feed
+		case <-done:
+			canceled = true
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if canceled {
+		return result, ErrImportCanceled
+	}
+
+	// add blocks in the order photos were taken
+	var ordered []*importItem
+	for _, item := range items {
+		if item != nil {
+			ordered = append(ordered, item)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].date.Before(ordered[j].date)
+	})
+	for i, item := range ordered {
+		select {
+		case <-cancel:
+			return result, ErrImportCanceled
+		case <-done:
+			return result, ErrImportCanceled
+		default:
+		}
+		update := model.ImportUpdate{Phase: "thread", Path: item.path, Id: item.added.Id, Done: i + 1, Total: len(ordered)}
+		shared, err := w.AddPhotoToThread(item.added.Id, item.added.Key, thrd, opts.Caption)
+		if err != nil {
+			log.Warningf("error adding %s to thread %s: %s", item.path, thrd.Name, err)
+			result.Failed = append(result.Failed, item.path)
+			update.Status, update.Error = "failed", err.Error()
+		} else if shared.Duplicate {
+			result.Duplicates++
+			update.BlockId, update.Status = shared.Id, "duplicate"
+		} else {
+			w.QueueUploadOrDiscard(shared.RemoteRequest)
+			result.Added++
+			update.BlockId, update.Status = shared.Id, "shared"
+		}
+		if !notify(update) {
+			return result, ErrImportCanceled
+		}
+	}
+
+	log.Infof("imported %d photos, skipped %d duplicates, %d failed", result.Added, result.Duplicates, len(result.Failed))
+	return result, nil
+}
+
+// importPhoto adds a single photo, noting when it was taken
+func (w *Wallet) importPhoto(path string) (*importItem, error) {
+	date, err := util.GetPhotoDate(path)
+	if err != nil {
+		return nil, err
+	}
+	added, err := w.AddPhoto(path)
+	if err != nil {
+		return nil, err
+	}
+	if !added.Duplicate {
+		w.QueueUploadOrDiscard(added.RemoteRequest)
+	}
+	return &importItem{path: path, date: date, added: added}, nil
+}
+
+// findPhotos recursively lists supported photos under root, skipping hidden files
+func findPhotos(root string) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			log.Warningf("error walking %s: %s", path, err)
+			return nil
+		}
+		hidden := path != root && strings.HasPrefix(info.Name(), ".")
+		if info.IsDir() {
+			if hidden {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if hidden || !util.PhotoExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	return paths, err
+}
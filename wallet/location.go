@@ -0,0 +1,317 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/textileio/textile-go/crypto"
+	"github.com/textileio/textile-go/net"
+	trepo "github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet/model"
+	"github.com/textileio/textile-go/wallet/thread"
+	"github.com/textileio/textile-go/wallet/util"
+	uio "gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/unixfs/io"
+	"gx/ipfs/QmcZfnkapfECQGcLZaf9B79NRg7cRa9EnZh4LSbkCzwNvY/go-cid"
+	"math"
+	"path/filepath"
+	"time"
+)
+
+// DefaultLocationGrid is the coarsening grid size in degrees (roughly 11km at the equator)
+const DefaultLocationGrid = 0.1
+
+var ErrBadLocationPolicy = errors.New("location policy must be one of keep, coarsen, or strip")
+var ErrBadLocationGrid = errors.New("location grid must be between 0 and 180 degrees")
+
+// ParseLocationPolicy returns the policy with the given name
+func ParseLocationPolicy(name string) (trepo.LocationPolicy, error) {
+	for _, policy := range []trepo.LocationPolicy{trepo.LocationKeep, trepo.LocationCoarsen, trepo.LocationStrip} {
+		if policy.String() == name {
+			return policy, nil
+		}
+	}
+	return trepo.LocationKeep, ErrBadLocationPolicy
+}
+
+// LocationPolicy returns the default location policy for threads
+func (w *Wallet) LocationPolicy() (trepo.LocationPolicy, float64, error) {
+	if err := w.touchDatastore(); err != nil {
+		return trepo.LocationKeep, 0, err
+	}
+	policy, grid, err := w.datastore.Config().GetLocationPolicy()
+	if err != nil {
+		return trepo.LocationKeep, 0, err
+	}
+	if grid == 0 {
+		grid = DefaultLocationGrid
+	}
+	return policy, grid, nil
+}
+
+// SetLocationPolicy sets the default location policy for threads, a zero grid uses the default
+func (w *Wallet) SetLocationPolicy(policy trepo.LocationPolicy, grid float64) error {
+	if err := checkLocationPolicy(policy, grid); err != nil {
+		return err
+	}
+	if err := w.touchDatastore(); err != nil {
+		return err
+	}
+	return w.datastore.Config().SetLocationPolicy(policy, grid)
+}
+
+// ThreadLocationPolicy returns the location policy for a thread, falling back to the default
+func (w *Wallet) ThreadLocationPolicy(threadId string) (trepo.LocationPolicy, float64, error) {
+	if err := w.touchDatastore(); err != nil {
+		return trepo.LocationKeep, 0, err
+	}
+	loc := w.datastore.ThreadLocations().Get(threadId)
+	if loc == nil {
+		return w.LocationPolicy()
+	}
+	if loc.Grid == 0 {
+		loc.Grid = DefaultLocationGrid
+	}
+	return loc.Policy, loc.Grid, nil
+}
+
+// SetThreadLocationPolicy sets the location policy for a thread, a zero grid uses the default
+func (w *Wallet) SetThreadLocationPolicy(threadId string, policy trepo.LocationPolicy, grid float64) error {
+	if err := checkLocationPolicy(policy, grid); err != nil {
+		return err
+	}
+	if err := w.touchDatastore(); err != nil {
+		return err
+	}
+	return w.datastore.ThreadLocations().Set(&trepo.ThreadLocation{
+		ThreadId: threadId,
+		Policy:   policy,
+		Grid:     grid,
+	})
+}
+
+// ResetThreadLocationPolicy makes a thread use the default location policy
+func (w *Wallet) ResetThreadLocationPolicy(threadId string) error {
+	if err := w.touchDatastore(); err != nil {
+		return err
+	}
+	return w.datastore.ThreadLocations().Delete(threadId)
+}
+
+// SharePhoto adds an existing photo to another thread, applying the thread's location policy
+func (w *Wallet) SharePhoto(id string, thrd *thread.Thread, caption string) (*model.AddResult, error) {
+	if !w.started {
+		return nil, ErrStopped
+	}
+
+	// get the file key from the original block
+	block, err := w.GetBlockByTarget(id)
+	if err != nil {
+		return nil, err
+	}
+	fromThread := w.GetThread(block.ThreadPubKey)
+	if fromThread == nil {
+		return nil, errors.New(fmt.Sprintf("could not find thread %s", block.ThreadPubKey))
+	}
+	key, err := fromThread.Decrypt(block.TargetKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// TODO: owner challenge
+	return w.AddPhotoToThread(id, key, thrd, caption)
+}
+
+// AddPhotoToThread adds a photo target to a thread, swapping in a target with cleaned
+// meta data if the thread's location policy requires it. All thread adds go through here.
+func (w *Wallet) AddPhotoToThread(id string, key []byte, thrd *thread.Thread, caption string) (*model.AddResult, error) {
+	if !w.started {
+		return nil, ErrStopped
+	}
+	policy, grid, err := w.ThreadLocationPolicy(thrd.Id)
+	if err != nil {
+		return nil, err
+	}
+	target := id
+	if policy != trepo.LocationKeep {
+		target, err = w.locationVariant(id, key, policy, grid)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return thrd.AddPhoto(target, caption, key)
+}
+
+// locationVariant returns a target sharing the photo's files, but with its meta data
+// location coarsened or stripped. The original target is returned if it has no location.
+func (w *Wallet) locationVariant(id string, key []byte, policy trepo.LocationPolicy, grid float64) (string, error) {
+	// variants are indexed so they're only made once
+	if existing := w.existingVariant(id, policy, grid); existing != "" {
+		return existing, nil
+	}
+
+	// decrypt and clean the original meta data
+	metacypher, err := util.GetDataAtPath(w.ipfs, fmt.Sprintf("%s/meta", id))
+	if err != nil {
+		return "", err
+	}
+	metab, err := crypto.DecryptAES(metacypher, key)
+	if err != nil {
+		return "", err
+	}
+	var meta model.PhotoMetadata
+	if err := json.Unmarshal(metab, &meta); err != nil {
+		return "", err
+	}
+	if !applyLocationPolicy(&meta, policy, grid) {
+		return id, nil
+	}
+	metab, err = json.Marshal(meta)
+	if err != nil {
+		return "", err
+	}
+	metacypher, err = crypto.EncryptAES(metab, key)
+	if err != nil {
+		return "", err
+	}
+
+	// create a virtual directory linking to the original files
+	ctx := w.ipfs.Context()
+	oid, err := cid.Decode(id)
+	if err != nil {
+		return "", err
+	}
+	orig, err := w.ipfs.DAG.Get(ctx, oid)
+	if err != nil {
+		return "", err
+	}
+	dirb := uio.NewDirectory(w.ipfs.DAG)
+	var names []string
+	for _, link := range orig.Links() {
+		names = append(names, link.Name)
+		if link.Name == "meta" {
+			continue
+		}
+		node, err := link.GetNode(ctx, w.ipfs.DAG)
+		if err != nil {
+			return "", err
+		}
+		if err := dirb.AddChild(ctx, link.Name, node); err != nil {
+			return "", err
+		}
+	}
+	if err := util.AddFileToDirectory(w.ipfs, dirb, metacypher, "meta"); err != nil {
+		return "", err
+	}
+
+	// pin the directory
+	dir, err := dirb.GetNode()
+	if err != nil {
+		return "", err
+	}
+	vid := dir.Cid().Hash().B58String()
+	w.markRecent(vid)
+	if err := util.PinDirectory(w.ipfs, dir, []string{"photo"}); err != nil {
+		return "", err
+	}
+	err = w.datastore.PhotoVariants().Add(&trepo.PhotoVariant{
+		Target:  id,
+		Policy:  policy,
+		Grid:    grid,
+		Variant: vid,
+		Date:    time.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+	if phash := w.datastore.PerceptualHashes().Get(id); phash != nil {
+		if err := w.indexPerceptualHash(vid, phash.Hash); err != nil {
+			return "", err
+		}
+	}
+	log.Debugf("%s location of %s as %s", policy.String(), id, vid)
+
+	// pin to remote when online
+	request, err := w.targetRequest(vid, names)
+	if err != nil {
+		return "", err
+	}
+	w.QueueUploadOrDiscard(request)
+	return vid, nil
+}
+
+// existingVariant returns the location variant already made for a target, if it's still pinned
+func (w *Wallet) existingVariant(id string, policy trepo.LocationPolicy, grid float64) string {
+	existing := w.datastore.PhotoVariants().Get(id, policy, grid)
+	if existing == nil {
+		return ""
+	}
+	c, err := cid.Decode(existing.Variant)
+	if err != nil {
+		log.Warningf("bad photo variant %s: %s", existing.Variant, err)
+		return ""
+	}
+	if _, pinned, err := w.ipfs.Pinning.IsPinned(c); err != nil || !pinned {
+		// the variant was collected, forget it
+		if err := w.datastore.PhotoVariants().DeleteByTarget(existing.Variant); err != nil {
+			log.Errorf("error removing stale photo variant: %s", err)
+		}
+		return ""
+	}
+	w.markRecent(existing.Variant)
+	return existing.Variant
+}
+
+// targetRequest creates a remote request for the named files of a target
+func (w *Wallet) targetRequest(id string, names []string) (*net.MultipartRequest, error) {
+	request := &net.MultipartRequest{}
+	request.Init(filepath.Join(w.repoPath, "tmp"), id)
+	for _, name := range names {
+		reader, err := util.GetReaderAtPath(w.ipfs, fmt.Sprintf("%s/%s", id, name))
+		if err != nil {
+			return nil, err
+		}
+		err = request.AddFileReader(reader, name)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := request.Finish(); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+// applyLocationPolicy coarsens or strips meta data location, returning whether it changed
+func applyLocationPolicy(meta *model.PhotoMetadata, policy trepo.LocationPolicy, grid float64) bool {
+	if meta.Latitude == 0 && meta.Longitude == 0 {
+		return false
+	}
+	switch policy {
+	case trepo.LocationCoarsen:
+		meta.Latitude = coarsen(meta.Latitude, grid, 90)
+		meta.Longitude = coarsen(meta.Longitude, grid, 180)
+	case trepo.LocationStrip:
+		meta.Latitude, meta.Longitude = 0, 0
+	default:
+		return false
+	}
+	return true
+}
+
+// coarsen snaps a coordinate to the center of its grid cell
+func coarsen(coord float64, grid float64, limit float64) float64 {
+	snapped := math.Floor(coord/grid)*grid + grid/2
+	return math.Max(-limit, math.Min(limit, snapped))
+}
+
+// checkLocationPolicy validates a policy and grid size
+func checkLocationPolicy(policy trepo.LocationPolicy, grid float64) error {
+	if policy < trepo.LocationKeep || policy > trepo.LocationStrip {
+		return ErrBadLocationPolicy
+	}
+	if grid < 0 || grid > 180 {
+		return ErrBadLocationGrid
+	}
+	return nil
+}
@@ -1,12 +1,30 @@
 package model
 
 import (
+	"fmt"
 	"github.com/textileio/textile-go/net"
+	"github.com/textileio/textile-go/repo"
 	"time"
 )
 
 const ThumbnailWidth = 300
 
+// Rendition is a named thumbnail size generated for each photo
+type Rendition struct {
+	Name  string `json:"name"`
+	Width int    `json:"width"`
+}
+
+// DefaultRenditions are generated in addition to the standard thumbnail
+var DefaultRenditions = []Rendition{
+	{Name: "small", Width: 100},
+	{Name: "medium", Width: 800},
+	{Name: "large", Width: 1600},
+}
+
+// PhotoFiles are the standard files in a photo directory, which renditions can't be named
+var PhotoFiles = []string{"photo", "thumb", "meta", "pk"}
+
 type Metadata struct {
 	Username string    `json:"un,omitempty"`
 	Created  time.Time `json:"cts,omitempty"`
@@ -23,10 +41,115 @@ type AddResult struct {
 	Id            string
 	Key           []byte
 	RemoteRequest *net.MultipartRequest
+	Duplicate     bool
+}
+
+type UploadUpdate struct {
+	Id       string `json:"id"`
+	Status   string `json:"status"`
+	Attempts int    `json:"attempts"`
+	Sent     int64  `json:"sent,omitempty"`
+	Total    int64  `json:"total,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type ImportUpdate struct {
+	Phase   string `json:"phase"`
+	Path    string `json:"path"`
+	Id      string `json:"id,omitempty"`
+	BlockId string `json:"block_id,omitempty"`
+	Status  string `json:"status"`
+	Done    int    `json:"done"`
+	Total   int    `json:"total"`
+	Error   string `json:"error,omitempty"`
+}
+
+type ImportResult struct {
+	Total      int      `json:"total"`
+	Added      int      `json:"added"`
+	Duplicates int      `json:"duplicates"`
+	Failed     []string `json:"failed"`
+}
+
+type GCResult struct {
+	Unpinned int    `json:"unpinned"`
+	Removed  int    `json:"removed"`
+	Freed    uint64 `json:"freed"`
 }
 
 type PhotoMetadata struct {
 	FileMetadata
-	Latitude  float64 `json:"lat,omitempty"`
-	Longitude float64 `json:"lon,omitempty"`
+	Latitude     float64        `json:"lat,omitempty"`
+	Longitude    float64        `json:"lon,omitempty"`
+	Width        int            `json:"width,omitempty"`
+	Height       int            `json:"height,omitempty"`
+	Format       string         `json:"format,omitempty"`
+	Size         int64          `json:"size,omitempty"`
+	Make         string         `json:"make,omitempty"`
+	Model        string         `json:"model,omitempty"`
+	Lens         string         `json:"lens,omitempty"`
+	FocalLength  float64        `json:"focal,omitempty"`
+	ISO          int            `json:"iso,omitempty"`
+	ExposureTime string         `json:"exposure,omitempty"`
+	FNumber      float64        `json:"fnum,omitempty"`
+	Orientation  int            `json:"orient,omitempty"`
+	Renditions   map[string]int `json:"rends,omitempty"`
+	ThumbFormat  string         `json:"tfmt,omitempty"`
+}
+
+type PhotoCluster struct {
+	Zoom      int     `json:"zoom"`
+	X         int     `json:"x"`
+	Y         int     `json:"y"`
+	Count     int     `json:"count"`
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+	BlockId   string  `json:"block_id"`
+	Target    string  `json:"target"`
+}
+
+type TimelineGroup struct {
+	Key    string               `json:"key"`
+	Start  time.Time            `json:"start"`
+	End    time.Time            `json:"end"`
+	Count  int                  `json:"count"`
+	Photos []repo.TimelineEntry `json:"photos"`
+}
+
+type TimelinePage struct {
+	Groups []TimelineGroup `json:"groups"`
+	Next   string          `json:"next,omitempty"`
+}
+
+type SimilarPhoto struct {
+	Id       string `json:"id"`
+	Distance int    `json:"distance"`
+}
+
+type SimilarCluster struct {
+	Photos []string `json:"photos"`
+}
+
+// Edit is an image operation used to make a photo version. Rotation angles are clockwise,
+// crops are in pixels of the original, and flips are horizontal (h) or vertical (v).
+type Edit struct {
+	Op     string `json:"op"`
+	Angle  int    `json:"angle,omitempty"`
+	X      int    `json:"x,omitempty"`
+	Y      int    `json:"y,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Axis   string `json:"axis,omitempty"`
+}
+
+func (e Edit) String() string {
+	switch e.Op {
+	case "rotate":
+		return fmt.Sprintf("rotate:%d", e.Angle)
+	case "crop":
+		return fmt.Sprintf("crop:%d,%d,%d,%d", e.X, e.Y, e.Width, e.Height)
+	case "flip":
+		return fmt.Sprintf("flip:%s", e.Axis)
+	}
+	return e.Op
 }
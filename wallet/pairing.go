@@ -0,0 +1,186 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"gx/ipfs/QmZoWKhxUmZ2seW4BzX6fJkNR8hh9PsGModr7q171yq2SS/go-libp2p-peer"
+	libp2pc "gx/ipfs/QmaPbCnUMBohSGo3KnxEa2bHqyJVVeEEcwtqJAYxerieBo/go-libp2p-crypto"
+	"math/big"
+	"time"
+)
+
+// DefaultPairingTTL is how long a pairing request stays valid
+const DefaultPairingTTL = time.Minute * 5
+
+// maxPairingAttempts is the number of invalid invites tolerated before a request is burned
+const maxPairingAttempts = 3
+
+// pairingAckTimeout is how long the inviting device waits for a confirmation
+const pairingAckTimeout = time.Second * 30
+
+var ErrPairingExpired = errors.New("pairing request expired")
+var ErrPairingRejected = errors.New("pairing invite rejected")
+var ErrPairingMismatch = errors.New("pairing confirmation does not match")
+
+// PairingRequest is held by a device waiting to be paired, its code is shown to the user
+type PairingRequest struct {
+	Code     string
+	PubKey   libp2pc.PubKey
+	Created  time.Time
+	Expires  time.Time
+	attempts int
+}
+
+// PairingInvite is sent by the inviting device, encrypted with the waiting device's peer key
+type PairingInvite struct {
+	Secret []byte `json:"secret"`
+	PubKey []byte `json:"pk"`
+	Date   int64  `json:"date"`
+	Mac    []byte `json:"mac"`
+}
+
+// PairingAck is sent back to the inviting device, encrypted with its peer key
+type PairingAck struct {
+	SAS string `json:"sas"`
+}
+
+// Pairing is a verified invite waiting for the user to confirm its short authentication string
+type Pairing struct {
+	SAS      string
+	Inviter  libp2pc.PubKey
+	secret   libp2pc.PrivKey
+	accepted bool
+}
+
+// NewPairingRequest creates a new request with a random confirmation code
+func NewPairingRequest(pk libp2pc.PubKey, ttl time.Duration) (*PairingRequest, error) {
+	code, err := randomDigits(4)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &PairingRequest{
+		Code:    code,
+		PubKey:  pk,
+		Created: now,
+		Expires: now.Add(ttl),
+	}, nil
+}
+
+// NewPairingInvite creates an invite carrying a thread secret, authenticated with the request code
+func NewPairingInvite(code string, to libp2pc.PubKey, from libp2pc.PubKey, secret libp2pc.PrivKey) (*PairingInvite, error) {
+	skb, err := secret.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	fromb, err := from.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	invite := &PairingInvite{
+		Secret: skb,
+		PubKey: fromb,
+		Date:   time.Now().Unix(),
+	}
+	mac, err := invite.mac(code, to)
+	if err != nil {
+		return nil, err
+	}
+	invite.Mac = mac
+	return invite, nil
+}
+
+// Expired returns whether or not the request can still be used
+func (r *PairingRequest) Expired() bool {
+	return time.Now().After(r.Expires) || r.attempts >= maxPairingAttempts
+}
+
+// Verify checks an invite received from a peer against the request,
+// returning the invited thread secret and the inviter's public key
+func (r *PairingRequest) Verify(invite *PairingInvite, from peer.ID) (libp2pc.PrivKey, libp2pc.PubKey, error) {
+	if r.Expired() {
+		return nil, nil, ErrPairingExpired
+	}
+	r.attempts++
+
+	// the invite must come from the key it claims
+	pk, err := libp2pc.UnmarshalPublicKey(invite.PubKey)
+	if err != nil {
+		return nil, nil, ErrPairingRejected
+	}
+	pid, err := peer.IDFromPublicKey(pk)
+	if err != nil || pid != from {
+		return nil, nil, ErrPairingRejected
+	}
+
+	// and must have been created during the request's lifetime
+	date := time.Unix(invite.Date, 0)
+	if date.Before(r.Created.Truncate(time.Second)) || date.After(r.Expires) {
+		return nil, nil, ErrPairingRejected
+	}
+
+	// and must prove knowledge of the code
+	mac, err := invite.mac(r.Code, r.PubKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !hmac.Equal(mac, invite.Mac) {
+		return nil, nil, ErrPairingRejected
+	}
+
+	secret, err := libp2pc.UnmarshalPrivateKey(invite.Secret)
+	if err != nil {
+		return nil, nil, ErrPairingRejected
+	}
+	r.attempts = maxPairingAttempts // single use
+	return secret, pk, nil
+}
+
+// PairingSAS derives a short authentication string for the two devices in a pairing,
+// which each device displays so the user can compare them
+func PairingSAS(code string, waiting libp2pc.PubKey, inviting libp2pc.PubKey) (string, error) {
+	wb, err := waiting.Bytes()
+	if err != nil {
+		return "", err
+	}
+	ib, err := inviting.Bytes()
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hash.Write([]byte(code))
+	hash.Write(wb)
+	hash.Write(ib)
+	sum := hash.Sum(nil)
+	return fmt.Sprintf("%06d", binary.BigEndian.Uint32(sum[:4])%1e6), nil
+}
+
+// mac returns the invite's authentication code under the pairing code
+func (i *PairingInvite) mac(code string, to libp2pc.PubKey) ([]byte, error) {
+	tob, err := to.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	date := make([]byte, 8)
+	binary.BigEndian.PutUint64(date, uint64(i.Date))
+	hm := hmac.New(sha256.New, []byte(code))
+	hm.Write(tob)
+	hm.Write(i.PubKey)
+	hm.Write(i.Secret)
+	hm.Write(date)
+	return hm.Sum(nil), nil
+}
+
+// randomDigits returns a random numeric string of length n
+func randomDigits(n int) (string, error) {
+	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+	num, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", n, num), nil
+}
@@ -0,0 +1,162 @@
+package wallet_test
+
+import (
+	. "github.com/textileio/textile-go/wallet"
+	"gx/ipfs/QmZoWKhxUmZ2seW4BzX6fJkNR8hh9PsGModr7q171yq2SS/go-libp2p-peer"
+	libp2pc "gx/ipfs/QmaPbCnUMBohSGo3KnxEa2bHqyJVVeEEcwtqJAYxerieBo/go-libp2p-crypto"
+	"testing"
+	"time"
+)
+
+type pairingPeer struct {
+	sk libp2pc.PrivKey
+	pk libp2pc.PubKey
+	id peer.ID
+}
+
+func newPairingPeer(t *testing.T) *pairingPeer {
+	sk, pk, err := libp2pc.GenerateKeyPair(libp2pc.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pairingPeer{sk: sk, pk: pk, id: id}
+}
+
+func TestPairingRequest_Verify(t *testing.T) {
+	desktop := newPairingPeer(t)
+	mobile := newPairingPeer(t)
+	req, err := NewPairingRequest(desktop.pk, DefaultPairingTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Code) != 4 {
+		t.Errorf("bad pairing code: %s", req.Code)
+	}
+	secret, _, err := libp2pc.GenerateKeyPair(libp2pc.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invite, err := NewPairingInvite(req.Code, desktop.pk, mobile.pk, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, inviter, err := req.Verify(invite, mobile.id)
+	if err != nil {
+		t.Fatalf("verify valid invite failed: %s", err)
+	}
+	if !got.Equals(secret) {
+		t.Error("verify returned wrong secret")
+	}
+	if !inviter.Equals(mobile.pk) {
+		t.Error("verify returned wrong inviter")
+	}
+
+	// requests are single use
+	if _, _, err := req.Verify(invite, mobile.id); err != ErrPairingExpired {
+		t.Errorf("verify reused request returned wrong error: %v", err)
+	}
+}
+
+func TestPairingRequest_VerifyMaliciousPeer(t *testing.T) {
+	desktop := newPairingPeer(t)
+	mobile := newPairingPeer(t)
+	mallory := newPairingPeer(t)
+	req, err := NewPairingRequest(desktop.pk, DefaultPairingTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evil, _, err := libp2pc.GenerateKeyPair(libp2pc.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// mallory can see the desktop's key, but not the code
+	guess := "0000"
+	if req.Code == guess {
+		guess = "0001"
+	}
+	unsolicited, err := NewPairingInvite(guess, desktop.pk, mallory.pk, evil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := req.Verify(unsolicited, mallory.id); err != ErrPairingRejected {
+		t.Errorf("verify unsolicited invite returned wrong error: %v", err)
+	}
+
+	// mallory replays the legit invite from her own peer
+	secret, _, err := libp2pc.GenerateKeyPair(libp2pc.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legit, err := NewPairingInvite(req.Code, desktop.pk, mobile.pk, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := req.Verify(legit, mallory.id); err != ErrPairingRejected {
+		t.Errorf("verify replayed invite returned wrong error: %v", err)
+	}
+
+	// mallory swaps in her own secret on the legit invite
+	tampered := *legit
+	tampered.Secret, err = evil.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := req.Verify(&tampered, mobile.id); err != ErrPairingRejected {
+		t.Errorf("verify tampered invite returned wrong error: %v", err)
+	}
+
+	// too many bad attempts burns the request
+	if _, _, err := req.Verify(legit, mobile.id); err != ErrPairingExpired {
+		t.Errorf("verify burned request returned wrong error: %v", err)
+	}
+}
+
+func TestPairingRequest_VerifyExpired(t *testing.T) {
+	desktop := newPairingPeer(t)
+	mobile := newPairingPeer(t)
+	req, err := NewPairingRequest(desktop.pk, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, _, err := libp2pc.GenerateKeyPair(libp2pc.Ed25519, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	invite, err := NewPairingInvite(req.Code, desktop.pk, mobile.pk, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 10)
+	if _, _, err := req.Verify(invite, mobile.id); err != ErrPairingExpired {
+		t.Errorf("verify expired request returned wrong error: %v", err)
+	}
+}
+
+func TestPairingSAS(t *testing.T) {
+	desktop := newPairingPeer(t)
+	mobile := newPairingPeer(t)
+	mallory := newPairingPeer(t)
+	a, err := PairingSAS("1234", desktop.pk, mobile.pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := PairingSAS("1234", desktop.pk, mobile.pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != b || len(a) != 6 {
+		t.Errorf("bad sas: %s, %s", a, b)
+	}
+	c, err := PairingSAS("1234", desktop.pk, mallory.pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == c {
+		t.Error("sas should differ for a different peer")
+	}
+}
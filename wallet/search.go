@@ -0,0 +1,38 @@
+package wallet
+
+import (
+	"fmt"
+	trepo "github.com/textileio/textile-go/repo"
+)
+
+// Search returns photo blocks whose caption or meta data match the query, newest first
+func (w *Wallet) Search(query *trepo.SearchQuery) ([]trepo.SearchEntry, error) {
+	if err := w.touchDatastore(); err != nil {
+		return nil, err
+	}
+	return w.datastore.Search().Search(query), nil
+}
+
+// RebuildSearchIndex indexes the caption and meta data of every photo block already in a thread
+// for search, timeline, and location queries, returning the number indexed
+func (w *Wallet) RebuildSearchIndex() (int, error) {
+	if !w.started {
+		return 0, ErrStopped
+	}
+	if err := w.touchDatastore(); err != nil {
+		return 0, err
+	}
+	var count int
+	for _, thrd := range w.Threads() {
+		query := fmt.Sprintf("pk='%s' and type=%d", thrd.Id, trepo.PhotoBlock)
+		for _, block := range w.datastore.Blocks().List("", -1, query) {
+			if err := thrd.IndexMetadata(&block); err != nil {
+				log.Warningf("error indexing block %s metadata: %s", block.Id, err)
+				continue
+			}
+			count++
+		}
+	}
+	log.Infof("indexed %d blocks for search", count)
+	return count, nil
+}
@@ -0,0 +1,175 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	trepo "github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet/model"
+	"github.com/textileio/textile-go/wallet/util"
+	"gx/ipfs/QmcZfnkapfECQGcLZaf9B79NRg7cRa9EnZh4LSbkCzwNvY/go-cid"
+	"sort"
+	"time"
+)
+
+// DefaultSimilarThreshold is the largest perceptual hash distance between near duplicates
+const DefaultSimilarThreshold = 10
+
+var ErrBadSimilarThreshold = errors.New("similar threshold must be between 0 and 64")
+
+// SimilarPhotos returns other photos that look like a photo, closest first
+func (w *Wallet) SimilarPhotos(id string, threshold int) ([]model.SimilarPhoto, error) {
+	if !w.started {
+		return nil, ErrStopped
+	}
+	if threshold < 0 || threshold > 64 {
+		return nil, ErrBadSimilarThreshold
+	}
+	hash, err := w.perceptualHash(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var similar []model.SimilarPhoto
+	for _, other := range w.datastore.PerceptualHashes().List(nil) {
+		if other.Target == id {
+			continue
+		}
+		dist := util.HashDistance(hash, other.Hash)
+		if dist > threshold || !w.pinnedPerceptualHash(other.Target) {
+			continue
+		}
+		similar = append(similar, model.SimilarPhoto{Id: other.Target, Distance: dist})
+	}
+	sort.SliceStable(similar, func(i, j int) bool {
+		return similar[i].Distance < similar[j].Distance
+	})
+	return similar, nil
+}
+
+// SimilarPhotoClusters groups the photos in a thread that look alike, largest group first.
+// Photos are grouped when they're within the threshold of any other photo in the group.
+func (w *Wallet) SimilarPhotoClusters(threadId string, threshold int) ([]model.SimilarCluster, error) {
+	if !w.started {
+		return nil, ErrStopped
+	}
+	if threshold < 0 || threshold > 64 {
+		return nil, ErrBadSimilarThreshold
+	}
+
+	// hash each photo once, newest first
+	query := fmt.Sprintf("pk='%s' and type=%d", threadId, trepo.PhotoBlock)
+	var ids []string
+	var hashes []uint64
+	seen := make(map[string]bool)
+	for _, block := range w.datastore.Blocks().List("", -1, query) {
+		if seen[block.Target] {
+			continue
+		}
+		seen[block.Target] = true
+		hash, err := w.perceptualHash(block.Target)
+		if err != nil {
+			log.Warningf("error getting perceptual hash of %s: %s", block.Target, err)
+			continue
+		}
+		ids = append(ids, block.Target)
+		hashes = append(hashes, hash)
+	}
+
+	// link every close pair
+	parents := make([]int, len(ids))
+	for i := range parents {
+		parents[i] = i
+	}
+	var root func(i int) int
+	root = func(i int) int {
+		if parents[i] != i {
+			parents[i] = root(parents[i])
+		}
+		return parents[i]
+	}
+	for i := range hashes {
+		for j := i + 1; j < len(hashes); j++ {
+			if util.HashDistance(hashes[i], hashes[j]) <= threshold {
+				parents[root(j)] = root(i)
+			}
+		}
+	}
+
+	// collect groups in order of their newest photo
+	groups := make(map[int]*model.SimilarCluster)
+	var clusters []*model.SimilarCluster
+	for i, id := range ids {
+		r := root(i)
+		cluster, ok := groups[r]
+		if !ok {
+			cluster = &model.SimilarCluster{}
+			groups[r] = cluster
+			clusters = append(clusters, cluster)
+		}
+		cluster.Photos = append(cluster.Photos, id)
+	}
+	var result []model.SimilarCluster
+	for _, cluster := range clusters {
+		if len(cluster.Photos) > 1 {
+			result = append(result, *cluster)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return len(result[i].Photos) > len(result[j].Photos)
+	})
+	return result, nil
+}
+
+// perceptualHash returns the indexed perceptual hash of a photo. Photos added before
+// hashes were indexed are hashed from their thumbnail.
+func (w *Wallet) perceptualHash(id string) (uint64, error) {
+	if existing := w.datastore.PerceptualHashes().Get(id); existing != nil {
+		return existing.Hash, nil
+	}
+	block, err := w.GetBlockByTarget(id)
+	if err != nil {
+		return 0, err
+	}
+	thrd := w.GetThread(block.ThreadPubKey)
+	if thrd == nil {
+		return 0, errors.New(fmt.Sprintf("could not find thread %s", block.ThreadPubKey))
+	}
+	reader, err := thrd.GetFileReader(fmt.Sprintf("%s/thumb", id), block)
+	if err != nil {
+		return 0, err
+	}
+	defer reader.Close()
+	hash, err := util.DifferenceHash(reader)
+	if err != nil {
+		return 0, err
+	}
+	if err := w.indexPerceptualHash(id, hash); err != nil {
+		return 0, err
+	}
+	return hash, nil
+}
+
+// indexPerceptualHash records the perceptual hash of a newly added target
+func (w *Wallet) indexPerceptualHash(id string, hash uint64) error {
+	return w.datastore.PerceptualHashes().Add(&trepo.PerceptualHash{
+		Target: id,
+		Hash:   hash,
+		Date:   time.Now(),
+	})
+}
+
+// pinnedPerceptualHash returns whether a hashed target is still pinned, forgetting it if not
+func (w *Wallet) pinnedPerceptualHash(id string) bool {
+	c, err := cid.Decode(id)
+	if err != nil {
+		log.Warningf("bad perceptual hash target %s: %s", id, err)
+		return false
+	}
+	if _, pinned, err := w.ipfs.Pinning.IsPinned(c); err != nil || !pinned {
+		if err := w.datastore.PerceptualHashes().Delete(id); err != nil {
+			log.Errorf("error removing stale perceptual hash: %s", err)
+		}
+		return false
+	}
+	return true
+}
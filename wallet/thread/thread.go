@@ -18,6 +18,7 @@ import (
 	"gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/core"
 	uio "gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/unixfs/io"
 	"io"
+	"io/ioutil"
 	"path/filepath"
 	"sort"
 	"strconv"
@@ -37,6 +38,10 @@ type Config struct {
 	RepoPath   string
 	Ipfs       func() *core.IpfsNode
 	Blocks     func() repo.BlockStore
+	Search     func() repo.SearchStore
+	Locations  func() repo.PhotoLocationStore
+	Timeline   func() repo.TimelineStore
+	Versions   func() repo.PhotoVersionStore
 	GetHead    func() (string, error)
 	UpdateHead func(head string) error
 	Publish    func(payload []byte) error
@@ -60,6 +65,10 @@ type Thread struct {
 	walletId   func() (string, error)
 	ipfs       func() *core.IpfsNode
 	blocks     func() repo.BlockStore
+	search     func() repo.SearchStore
+	locations  func() repo.PhotoLocationStore
+	timeline   func() repo.TimelineStore
+	versions   func() repo.PhotoVersionStore
 	GetHead    func() (string, error)
 	updateHead func(head string) error
 	publish    func(payload []byte) error
@@ -81,6 +90,10 @@ func NewThread(model *repo.Thread, config *Config) (*Thread, error) {
 		repoPath:   config.RepoPath,
 		ipfs:       config.Ipfs,
 		blocks:     config.Blocks,
+		search:     config.Search,
+		locations:  config.Locations,
+		timeline:   config.Timeline,
+		versions:   config.Versions,
 		GetHead:    config.GetHead,
 		updateHead: config.UpdateHead,
 		publish:    config.Publish,
@@ -92,60 +105,94 @@ func (t *Thread) AddPhoto(id string, caption string, key []byte) (*model.AddResu
 	t.mux.Lock()
 	defer t.mux.Unlock()
 
-	// get current HEAD
-	head, err := t.GetHead()
-	if err != nil {
-		return nil, err
+	// a photo is only added to a thread once
+	query := fmt.Sprintf("pk='%s' and type=%d and target='%s'", t.Id, repo.PhotoBlock, id)
+	if existing := t.blocks().List("", 1, query); len(existing) > 0 {
+		return &model.AddResult{Id: existing[0].Id, Duplicate: true}, nil
 	}
 
-	// encrypt AES key with thread pk
-	keycypher, err := t.Encrypt(key)
+	// encrypt caption with thread pk
+	captioncypher, err := t.Encrypt([]byte(caption))
 	if err != nil {
 		return nil, err
 	}
-	threadkeyb, err := t.PrivKey.GetPublic().Bytes()
-	if err != nil {
-		return nil, err
+	return t.addBlock(repo.PhotoBlock, id, key, map[string][]byte{"caption": captioncypher})
+}
+
+// AddVersion adds a block for an edited version of a photo in this thread,
+// where edits describes how the version was made from the original
+func (t *Thread) AddVersion(original string, id string, key []byte, edits []byte) (*model.AddResult, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	// versions are of photos in this thread
+	query := fmt.Sprintf("pk='%s' and type=%d and target='%s'", t.Id, repo.PhotoBlock, original)
+	if existing := t.blocks().List("", 1, query); len(existing) == 0 {
+		return nil, errors.New(fmt.Sprintf("could not find photo %s in thread %s", original, t.Name))
+	}
+	if existing := t.versions().GetByTarget(t.Id, id); existing != nil {
+		return &model.AddResult{Id: existing.BlockId, Duplicate: true}, nil
 	}
-	threadkey := libp2pc.ConfigEncodeKey(threadkeyb)
-	typeb := repo.PhotoBlock.Bytes() // silly?
-	dateb := util.GetNowBytes()
 
-	// encrypt caption with thread pk
-	captioncypher, err := t.Encrypt([]byte(caption))
+	// encrypt edits with thread pk
+	editscypher, err := t.Encrypt(edits)
 	if err != nil {
 		return nil, err
 	}
+	return t.addBlock(repo.VersionBlock, id, key, map[string][]byte{
+		"original": []byte(original),
+		"edits":    editscypher,
+	})
+}
 
-	// create a virtual directory for the new block
-	dirb := uio.NewDirectory(t.ipfs().DAG)
-	err = util.AddFileToDirectory(t.ipfs(), dirb, []byte(id), "target")
+// addBlock creates, pins, and indexes a new block at the head of this thread
+// with some extra files, returning a request to pin it remotely
+func (t *Thread) addBlock(blockType repo.BlockType, id string, key []byte, extra map[string][]byte) (*model.AddResult, error) {
+	// get current HEAD
+	head, err := t.GetHead()
 	if err != nil {
 		return nil, err
 	}
-	err = util.AddFileToDirectory(t.ipfs(), dirb, []byte(head), "parents")
+
+	// encrypt AES key with thread pk
+	keycypher, err := t.Encrypt(key)
 	if err != nil {
 		return nil, err
 	}
-	err = util.AddFileToDirectory(t.ipfs(), dirb, keycypher, "key")
+	threadkeyb, err := t.PrivKey.GetPublic().Bytes()
 	if err != nil {
 		return nil, err
 	}
-	err = util.AddFileToDirectory(t.ipfs(), dirb, []byte(threadkey), "pk")
-	if err != nil {
-		return nil, err
+	threadkey := libp2pc.ConfigEncodeKey(threadkeyb)
+	typeb := blockType.Bytes() // silly?
+	dateb := util.GetNowBytes()
+
+	// collect the block's files in a stable order
+	names := []string{"target", "parents", "key", "pk", "type", "date"}
+	files := map[string][]byte{
+		"target":  []byte(id),
+		"parents": []byte(head),
+		"key":     keycypher,
+		"pk":      []byte(threadkey),
+		"type":    typeb,
+		"date":    dateb,
 	}
-	err = util.AddFileToDirectory(t.ipfs(), dirb, typeb, "type")
-	if err != nil {
-		return nil, err
+	var extraNames []string
+	for name := range extra {
+		extraNames = append(extraNames, name)
 	}
-	err = util.AddFileToDirectory(t.ipfs(), dirb, dateb, "date")
-	if err != nil {
-		return nil, err
+	sort.Strings(extraNames)
+	for _, name := range extraNames {
+		names = append(names, name)
+		files[name] = extra[name]
 	}
-	err = util.AddFileToDirectory(t.ipfs(), dirb, captioncypher, "caption")
-	if err != nil {
-		return nil, err
+
+	// create a virtual directory for the new block
+	dirb := uio.NewDirectory(t.ipfs().DAG)
+	for _, name := range names {
+		if err := util.AddFileToDirectory(t.ipfs(), dirb, files[name], name); err != nil {
+			return nil, err
+		}
 	}
 
 	// pin it
@@ -163,6 +210,9 @@ func (t *Thread) AddPhoto(id string, caption string, key []byte) (*model.AddResu
 	if err != nil {
 		return nil, err
 	}
+	if err := t.IndexMetadata(block); err != nil {
+		log.Warningf("error indexing block %s metadata: %s", bid, err)
+	}
 
 	// update head
 	if err := t.updateHead(bid); err != nil {
@@ -177,26 +227,10 @@ func (t *Thread) AddPhoto(id string, caption string, key []byte) (*model.AddResu
 	request.Init(filepath.Join(t.repoPath, "tmp"), bid)
 
 	// add files to request
-	if err := request.AddFile([]byte(id), "target"); err != nil {
-		return nil, err
-	}
-	if err := request.AddFile([]byte(head), "parents"); err != nil {
-		return nil, err
-	}
-	if err := request.AddFile(keycypher, "key"); err != nil {
-		return nil, err
-	}
-	if err := request.AddFile([]byte(threadkey), "pk"); err != nil {
-		return nil, err
-	}
-	if err := request.AddFile(typeb, "type"); err != nil {
-		return nil, err
-	}
-	if err := request.AddFile(dateb, "date"); err != nil {
-		return nil, err
-	}
-	if err := request.AddFile(captioncypher, "caption"); err != nil {
-		return nil, err
+	for _, name := range names {
+		if err := request.AddFile(files[name], name); err != nil {
+			return nil, err
+		}
 	}
 
 	// finish request
@@ -240,15 +274,20 @@ func (t *Thread) GetFileKey(block *repo.Block) (string, error) {
 	return string(key), nil
 }
 
-// GetFileData cats file data from ipfs and tries to decrypt it with the provided block
+// GetFileData cats file data from ipfs and tries to decrypt it with the provided block,
+// it's meant for small files like meta data, see GetFileReader for photos
 func (t *Thread) GetFileData(path string, block *repo.Block) ([]byte, error) {
-	// get bytes
-	cypher, err := util.GetDataAtPath(t.ipfs(), path)
+	reader, err := t.GetFileReader(path, block)
 	if err != nil {
-		log.Errorf("error getting file data: %s", err)
 		return nil, err
 	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
 
+// GetFileReader returns a decrypting reader for file data from ipfs, which must be closed,
+// seeking only decrypts the chunks that are read
+func (t *Thread) GetFileReader(path string, block *repo.Block) (util.ReadSeekCloser, error) {
 	// decrypt the file key
 	key, err := t.Decrypt(block.TargetKey)
 	if err != nil {
@@ -256,8 +295,36 @@ func (t *Thread) GetFileData(path string, block *repo.Block) ([]byte, error) {
 		return nil, err
 	}
 
-	// finally, decrypt the file
-	return crypto.DecryptAES(cypher, key)
+	// get a reader
+	cypher, err := util.GetReaderAtPath(t.ipfs(), path)
+	if err != nil {
+		log.Errorf("error getting file data: %s", err)
+		return nil, err
+	}
+
+	// finally, decrypt the file as it's read
+	plain, err := crypto.NewAESDecryptReadSeeker(cypher, key)
+	if err != nil {
+		cypher.Close()
+		return nil, err
+	}
+	return &decryptReader{ReadSeeker: plain, Closer: cypher}, nil
+}
+
+// GetRendition returns a decrypting reader for a named thumbnail rendition of a photo, which must be closed,
+// falling back to the original when the rendition doesn't exist, i.e., the original is smaller or predates renditions
+func (t *Thread) GetRendition(id string, name string, block *repo.Block) (util.ReadSeekCloser, error) {
+	for _, file := range model.PhotoFiles {
+		if name == file {
+			return t.GetFileReader(fmt.Sprintf("%s/%s", id, name), block)
+		}
+	}
+	reader, err := t.GetFileReader(fmt.Sprintf("%s/%s", id, name), block)
+	if !util.IsLinkNotFound(err) {
+		return reader, err
+	}
+	log.Debugf("rendition %s not found for %s, using original", name, id)
+	return t.GetFileReader(fmt.Sprintf("%s/photo", id), block)
 }
 
 // GetFileDataBase64 returns file data encoded as base64 under an ipfs path
@@ -364,16 +431,46 @@ func (t *Thread) Listening() bool {
 	return t.listening
 }
 
-// Blocks paginates photos from the datastore
+// Blocks paginates photos from the datastore. Edited photos point at the target
+// and key of their latest version, see Versions for the rest.
 // TODO: add filter on type
 func (t *Thread) Blocks(offsetId string, limit int) []repo.Block {
 	log.Debugf("listing blocks: offsetId: %s, limit: %d, thread: %s", offsetId, limit, t.Name)
 	query := fmt.Sprintf("pk='%s' and type=%d", t.Id, repo.PhotoBlock)
 	list := t.blocks().List(offsetId, limit, query)
+	for i, block := range list {
+		latest := t.versions().Latest(t.Id, block.Target)
+		if latest == nil {
+			continue
+		}
+		if vblock := t.blocks().Get(latest.BlockId); vblock != nil {
+			list[i].Target = vblock.Target
+			list[i].TargetKey = vblock.TargetKey
+		}
+	}
 	log.Debugf("found %d photos in thread %s", len(list), t.Name)
 	return list
 }
 
+// Versions lists the version blocks of a photo in this thread, newest first
+func (t *Thread) Versions(original string) []repo.Block {
+	var list []repo.Block
+	for _, version := range t.versions().List(t.Id, original) {
+		if block := t.blocks().Get(version.BlockId); block != nil {
+			list = append(list, *block)
+		}
+	}
+	return list
+}
+
+// GetVersionEdits returns the json encoded edits of a version block
+func (t *Thread) GetVersionEdits(block *repo.Block) ([]byte, error) {
+	if block.Type != repo.VersionBlock {
+		return nil, errors.New(fmt.Sprintf("block %s is not a version", block.Id))
+	}
+	return t.GetBlockData(fmt.Sprintf("%s/edits", block.Id), block)
+}
+
 // Encrypt data with thread public key
 func (t *Thread) Encrypt(data []byte) ([]byte, error) {
 	return crypto.Encrypt(t.PrivKey.GetPublic(), data)
@@ -499,6 +596,12 @@ func (t *Thread) handleBlock(id string, datac chan Update) error {
 	if err != nil {
 		return err
 	}
+	go func() {
+		// the target may need to be fetched from the network
+		if err := t.IndexMetadata(block); err != nil {
+			log.Warningf("error indexing block %s metadata: %s", id, err)
+		}
+	}()
 
 	// update current head
 	if err := t.updateHead(id); err != nil {
@@ -569,9 +672,95 @@ func (t *Thread) indexBlock(id string) (*repo.Block, error) {
 	if err := t.blocks().Add(block); err != nil {
 		return nil, err
 	}
+
+	// versions point back at their original photo
+	if block.Type == repo.VersionBlock {
+		original, err := util.GetDataAtPath(t.ipfs(), fmt.Sprintf("%s/original", id))
+		if err != nil {
+			return nil, err
+		}
+		err = t.versions().Add(&repo.PhotoVersion{
+			BlockId:  id,
+			ThreadId: t.Id,
+			Original: string(original),
+			Target:   block.Target,
+			Date:     block.Date,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
 	return block, nil
 }
 
+// IndexMetadata adds a photo block's caption and meta data to the local search, timeline, and location indexes
+func (t *Thread) IndexMetadata(block *repo.Block) error {
+	if block.Type != repo.PhotoBlock {
+		return nil
+	}
+	caption, err := t.GetBlockData(fmt.Sprintf("%s/caption", block.Id), block)
+	if err != nil {
+		return err
+	}
+	metab, err := t.GetFileData(fmt.Sprintf("%s/meta", block.Target), block)
+	if err != nil {
+		return err
+	}
+	var meta model.PhotoMetadata
+	if err := json.Unmarshal(metab, &meta); err != nil {
+		return err
+	}
+	var camera []string
+	for _, part := range []string{meta.Make, meta.Model, meta.Lens} {
+		if part != "" {
+			camera = append(camera, part)
+		}
+	}
+	err = t.search().Add(&repo.SearchEntry{
+		BlockId:  block.Id,
+		ThreadId: t.Id,
+		Target:   block.Target,
+		Caption:  string(caption),
+		Name:     meta.Name,
+		Username: meta.Username,
+		Camera:   strings.Join(camera, " "),
+		Date:     block.Date,
+		Created:  meta.Created,
+	})
+	if err != nil {
+		return err
+	}
+
+	// photos without a capture date fall back to when they were added
+	taken := meta.Created
+	if taken.IsZero() {
+		taken = block.Date
+	}
+	err = t.timeline().Add(&repo.TimelineEntry{
+		BlockId:  block.Id,
+		ThreadId: t.Id,
+		Target:   block.Target,
+		Taken:    taken,
+		Added:    block.Date,
+	})
+	if err != nil {
+		return err
+	}
+
+	// zero is the absence of a location, not null island
+	if meta.Latitude == 0 && meta.Longitude == 0 {
+		return nil
+	}
+	return t.locations().Add(&repo.PhotoLocation{
+		BlockId:   block.Id,
+		ThreadId:  t.Id,
+		Target:    block.Target,
+		Latitude:  meta.Latitude,
+		Longitude: meta.Longitude,
+		Date:      block.Date,
+	})
+}
+
 // signBlock generated a valid JWT based on a thread block
 func (t *Thread) signBlock(block *repo.Block) (string, error) {
 	var blockId string
@@ -599,3 +788,9 @@ func (t *Thread) signBlock(block *repo.Block) (string, error) {
 	}
 	return token, nil
 }
+
+// decryptReader closes the underlying ipfs reader
+type decryptReader struct {
+	io.ReadSeeker
+	io.Closer
+}
@@ -35,7 +35,7 @@ func Test_SetupThread(t *testing.T) {
 
 func TestNewThread_WalletOffline(t *testing.T) {
 	var err error
-	thrd, _, err = twallet.AddThreadWithMnemonic("thread1", nil)
+	thrd, _, err = twallet.AddThreadWithMnemonic("thread1", nil, "")
 	if err != nil {
 		t.Errorf("create thread while offline failed: %s", err)
 	}
@@ -44,7 +44,7 @@ func TestNewThread_WalletOffline(t *testing.T) {
 func TestNewThread_WalletOnline(t *testing.T) {
 	<-wonline
 	var err error
-	_, _, err = twallet.AddThreadWithMnemonic("thread2", nil)
+	_, _, err = twallet.AddThreadWithMnemonic("thread2", nil, "")
 	if err != nil {
 		t.Errorf("create thread while online failed: %s", err)
 	}
@@ -80,6 +80,17 @@ func TestThread_AddPhoto(t *testing.T) {
 	}
 }
 
+func TestThread_AddPhotoAgain(t *testing.T) {
+	again, err := thrd.AddPhoto(wadded.Id, "howdy again", wadded.Key)
+	if err != nil {
+		t.Errorf("add photo to thread again failed: %s", err)
+		return
+	}
+	if !again.Duplicate || again.Id != tadded.Id {
+		t.Errorf("add photo to thread again got new block: %s", again.Id)
+	}
+}
+
 func TestThread_GetBlockData(t *testing.T) {
 	// TODO
 }
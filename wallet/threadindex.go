@@ -0,0 +1,168 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/textileio/textile-go/crypto"
+	trepo "github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet/thread"
+	"github.com/textileio/textile-go/wallet/util"
+	"gx/ipfs/QmZoWKhxUmZ2seW4BzX6fJkNR8hh9PsGModr7q171yq2SS/go-libp2p-peer"
+	libp2pc "gx/ipfs/QmaPbCnUMBohSGo3KnxEa2bHqyJVVeEEcwtqJAYxerieBo/go-libp2p-crypto"
+	"gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/core/coreunix"
+	"gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/path"
+	"time"
+)
+
+// threadIndexTimeout bounds publishing and resolving the thread index
+const threadIndexTimeout = time.Minute
+
+var ErrNoSeed = errors.New("master seed not found, re-init the repo from the mnemonic")
+
+// threadIndex is the encrypted list of derived threads published under an ipns name
+// which only the master seed can produce, so a restore can find every derivation index
+type threadIndex struct {
+	Threads []derivedThread `json:"threads"`
+}
+
+type derivedThread struct {
+	Name  string `json:"name"`
+	Index int    `json:"index"`
+}
+
+// PublishThreadIndex publishes the names and derivation indexes of all derived threads
+func (w *Wallet) PublishThreadIndex() error {
+	if !w.Online() {
+		return ErrOffline
+	}
+	seed, err := w.masterSeed()
+	if err != nil {
+		return err
+	}
+	sk, key, err := util.ThreadIndexKeysFromSeed(seed)
+	if err != nil {
+		return err
+	}
+
+	index := threadIndex{}
+	for _, ti := range w.datastore.ThreadIndexes().List() {
+		mod := w.datastore.Threads().Get(ti.ThreadId)
+		if mod == nil {
+			continue
+		}
+		index.Threads = append(index.Threads, derivedThread{Name: mod.Name, Index: ti.Index})
+	}
+	plain, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	cypher, err := crypto.EncryptAES(plain, key)
+	if err != nil {
+		return err
+	}
+	id, err := coreunix.Add(w.ipfs, bytes.NewReader(cypher))
+	if err != nil {
+		return err
+	}
+	if err := util.PinPath(w.ipfs, id, false); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(w.ipfs.Context(), threadIndexTimeout)
+	defer cancel()
+	if err := w.ipfs.Namesys.Publish(ctx, sk, path.FromString("/ipfs/"+id)); err != nil {
+		return err
+	}
+	log.Infof("published index of %d derived threads: %s", len(index.Threads), id)
+	return w.datastore.Profile().SetPublishedThreadIndex(id)
+}
+
+// RestoreThreads resolves the published thread index and re-derives every listed thread
+func (w *Wallet) RestoreThreads() ([]*thread.Thread, error) {
+	if !w.Online() {
+		return nil, ErrOffline
+	}
+	seed, err := w.masterSeed()
+	if err != nil {
+		return nil, err
+	}
+	sk, key, err := util.ThreadIndexKeysFromSeed(seed)
+	if err != nil {
+		return nil, err
+	}
+	pid, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(w.ipfs.Context(), threadIndexTimeout)
+	defer cancel()
+	pth, err := w.ipfs.Namesys.Resolve(ctx, "/ipns/"+pid.Pretty())
+	if err != nil {
+		return nil, err
+	}
+	cypher, err := util.GetDataAtPath(w.ipfs, pth.String())
+	if err != nil {
+		return nil, err
+	}
+	plain, err := crypto.DecryptAES(cypher, key)
+	if err != nil {
+		return nil, err
+	}
+	index := threadIndex{}
+	if err := json.Unmarshal(plain, &index); err != nil {
+		return nil, err
+	}
+
+	var restored []*thread.Thread
+	for _, dt := range index.Threads {
+		secret, err := util.ThreadKeyFromSeed(seed, dt.Index)
+		if err != nil {
+			return nil, err
+		}
+		pkb, err := secret.GetPublic().Bytes()
+		if err != nil {
+			return nil, err
+		}
+		id := libp2pc.ConfigEncodeKey(pkb)
+		if mod := w.datastore.Threads().Get(id); mod != nil {
+			if err := w.datastore.ThreadIndexes().Add(&trepo.ThreadIndex{ThreadId: id, Index: dt.Index}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// a local thread may have taken the name since
+		name := dt.Name
+		if existing, err := w.getThreadModelByName(name); err != nil {
+			return nil, err
+		} else if existing != nil {
+			name = fmt.Sprintf("%s (%d)", dt.Name, dt.Index)
+		}
+		thrd, err := w.restoreThread(name, dt.Index)
+		if err != nil {
+			return nil, err
+		}
+		restored = append(restored, thrd)
+	}
+	log.Infof("restored %d derived threads", len(restored))
+	return restored, w.datastore.Profile().SetPublishedThreadIndex(pth.Segments()[1])
+}
+
+// masterSeed returns the bip39 seed the master key and derived thread keys come from
+func (w *Wallet) masterSeed() ([]byte, error) {
+	if err := w.touchDatastore(); err != nil {
+		return nil, err
+	}
+	seed, err := w.datastore.Profile().GetSeed()
+	if err != nil {
+		return nil, err
+	}
+	if seed == nil {
+		return nil, ErrNoSeed
+	}
+	return seed, nil
+}
@@ -0,0 +1,83 @@
+package wallet
+
+import (
+	"errors"
+	trepo "github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet/model"
+	"time"
+)
+
+// DefaultTimelineLimit is the number of groups in a timeline page
+const DefaultTimelineLimit = 20
+
+// DefaultTimelineThumbs is the number of representative photos in a timeline group
+const DefaultTimelineThumbs = 4
+
+var ErrBadTimelinePeriod = errors.New("timeline period must be one of day, month, or year")
+var ErrBadTimelineCursor = errors.New("timeline cursor does not match the period")
+
+// ParseTimelinePeriod returns the period with the given name
+func ParseTimelinePeriod(name string) (trepo.TimelinePeriod, error) {
+	for _, period := range []trepo.TimelinePeriod{trepo.TimelineDay, trepo.TimelineMonth, trepo.TimelineYear} {
+		if period.String() == name {
+			return period, nil
+		}
+	}
+	return trepo.TimelineDay, ErrBadTimelinePeriod
+}
+
+// Timeline groups photos in all threads, or only some thread ids, by the local day, month, or year
+// they were taken, newest first. Photos without a capture date are grouped by when they were added.
+// Each group has its count and up to thumbs of its newest photos. Pass a page's next cursor
+// to get the groups that follow it.
+func (w *Wallet) Timeline(period trepo.TimelinePeriod, cursor string, limit int, thumbs int, threads []string) (*model.TimelinePage, error) {
+	if period < trepo.TimelineDay || period > trepo.TimelineYear {
+		return nil, ErrBadTimelinePeriod
+	}
+	if cursor != "" {
+		if _, err := time.ParseInLocation(period.Layout(), cursor, time.Local); err != nil {
+			return nil, ErrBadTimelineCursor
+		}
+	}
+	if limit <= 0 {
+		limit = DefaultTimelineLimit
+	}
+	if thumbs <= 0 {
+		thumbs = DefaultTimelineThumbs
+	}
+	if err := w.touchDatastore(); err != nil {
+		return nil, err
+	}
+
+	groups := w.datastore.Timeline().Groups(period, cursor, limit, threads)
+	page := &model.TimelinePage{Groups: make([]model.TimelineGroup, 0, len(groups))}
+	for _, group := range groups {
+		start, err := time.ParseInLocation(period.Layout(), group.Key, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		end := periodEnd(start, period)
+		page.Groups = append(page.Groups, model.TimelineGroup{
+			Key:    group.Key,
+			Start:  start,
+			End:    end,
+			Count:  group.Count,
+			Photos: w.datastore.Timeline().List(start, end, thumbs, threads),
+		})
+	}
+	if len(groups) == limit {
+		page.Next = groups[len(groups)-1].Key
+	}
+	return page, nil
+}
+
+// periodEnd returns the start of the period following the one starting at start
+func periodEnd(start time.Time, period trepo.TimelinePeriod) time.Time {
+	switch period {
+	case trepo.TimelineMonth:
+		return start.AddDate(0, 1, 0)
+	case trepo.TimelineYear:
+		return start.AddDate(1, 0, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
@@ -0,0 +1,270 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"github.com/textileio/textile-go/net"
+	trepo "github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet/model"
+	"os"
+	"time"
+)
+
+// uploadFlushInterval is how often the queue looks for due uploads while online
+const uploadFlushInterval = time.Second * 30
+
+// uploadBackoffBase is the wait after the first failed attempt, doubling with each failure
+const uploadBackoffBase = time.Second * 10
+
+// uploadBackoffMax caps the wait between attempts
+const uploadBackoffMax = time.Hour
+
+// maxUploadAttempts is the number of failed attempts before an upload is given up on
+const maxUploadAttempts = 20
+
+var ErrNoCentralAPI = errors.New("central api is not configured")
+
+// QueueUpload persists a remote request so it's sent as soon as the node is online
+func (w *Wallet) QueueUpload(request *net.MultipartRequest) error {
+	if err := w.touchDatastore(); err != nil {
+		return err
+	}
+	if w.centralAPI == "" {
+		return ErrNoCentralAPI
+	}
+	now := time.Now()
+	upload := &trepo.Upload{
+		Id:          request.Boundary,
+		PayloadPath: request.PayloadPath,
+		Target:      w.centralAPI,
+		Status:      trepo.UploadPending,
+		Date:        now,
+		Updated:     now,
+	}
+	if err := w.datastore.Uploads().Add(upload); err != nil {
+		return err
+	}
+	w.notifyUpload(upload, nil)
+
+	// try right away if we can
+	if w.Online() {
+		go w.FlushUploads()
+	}
+	return nil
+}
+
+// QueueUploadOrDiscard queues a remote request, discarding it if there's no remote to pin to
+func (w *Wallet) QueueUploadOrDiscard(request *net.MultipartRequest) {
+	err := w.QueueUpload(request)
+	if err == nil {
+		return
+	}
+	if err != ErrNoCentralAPI {
+		log.Errorf("error queueing upload %s: %s", request.Boundary, err)
+	}
+	if err := os.Remove(request.PayloadPath); err != nil {
+		log.Warningf("error removing payload %s: %s", request.PayloadPath, err)
+	}
+}
+
+// Uploads lists queued and failed uploads
+func (w *Wallet) Uploads() ([]trepo.Upload, error) {
+	if err := w.touchDatastore(); err != nil {
+		return nil, err
+	}
+	return w.datastore.Uploads().List(""), nil
+}
+
+// FlushUploads sends all pending uploads whose backoff has elapsed
+func (w *Wallet) FlushUploads() error {
+	if !w.Online() {
+		return ErrOffline
+	}
+	if err := w.touchDatastore(); err != nil {
+		return err
+	}
+	w.uploadMux.Lock()
+	defer w.uploadMux.Unlock()
+
+	query := fmt.Sprintf("status=%d", trepo.UploadPending)
+	for _, upload := range w.datastore.Uploads().List(query) {
+		if time.Since(upload.Updated) < uploadBackoff(upload.Attempts) {
+			continue
+		}
+		if !w.Online() {
+			return ErrOffline
+		}
+		w.sendUpload(&upload)
+	}
+	return nil
+}
+
+// RetryUpload puts a failed upload back in the queue
+func (w *Wallet) RetryUpload(id string) error {
+	if err := w.touchDatastore(); err != nil {
+		return err
+	}
+	upload := w.datastore.Uploads().Get(id)
+	if upload == nil {
+		return errors.New(fmt.Sprintf("upload not found: %s", id))
+	}
+	if _, err := os.Stat(upload.PayloadPath); err != nil {
+		return err
+	}
+	upload.Attempts = 0
+	upload.Status = trepo.UploadPending
+	upload.Updated = time.Now()
+	if err := w.datastore.Uploads().Update(upload); err != nil {
+		return err
+	}
+	w.notifyUpload(upload, nil)
+	if w.Online() {
+		go w.FlushUploads()
+	}
+	return nil
+}
+
+// SubscribeUploads registers a channel for upload status updates
+func (w *Wallet) SubscribeUploads(datac chan model.UploadUpdate) {
+	w.uploadSubsMux.Lock()
+	defer w.uploadSubsMux.Unlock()
+	w.uploadSubs = append(w.uploadSubs, datac)
+}
+
+// UnsubscribeUploads removes and closes a channel registered with SubscribeUploads
+func (w *Wallet) UnsubscribeUploads(datac chan model.UploadUpdate) {
+	w.uploadSubsMux.Lock()
+	defer w.uploadSubsMux.Unlock()
+	for i, sub := range w.uploadSubs {
+		if sub == datac {
+			w.uploadSubs = append(w.uploadSubs[:i], w.uploadSubs[i+1:]...)
+			close(datac)
+			return
+		}
+	}
+}
+
+// sendUpload makes one attempt at an upload, updating its state
+func (w *Wallet) sendUpload(upload *trepo.Upload) {
+	upload.Attempts++
+	upload.Updated = time.Now()
+
+	// send as the signed in user, if any
+	token, err := w.GetAccessToken()
+	if err != nil {
+		log.Debugf("sending upload %s without access token: %s", upload.Id, err)
+	}
+	var percent int64 = -1
+	request := &net.MultipartRequest{Boundary: upload.Id, PayloadPath: upload.PayloadPath}
+	err = request.Send(upload.Target, &net.SendOptions{
+		Token:     token,
+		ChunkSize: w.uploadChunk,
+		Progress: func(sent int64, total int64) {
+			if total == 0 || sent*100/total == percent {
+				return
+			}
+			percent = sent * 100 / total
+			w.notifyUploadProgress(upload, sent, total)
+		},
+	})
+	if err == nil {
+		log.Debugf("upload %s complete", upload.Id)
+		if err := os.Remove(upload.PayloadPath); err != nil && !os.IsNotExist(err) {
+			log.Warningf("error removing payload %s: %s", upload.PayloadPath, err)
+		}
+		if err := w.datastore.Uploads().Delete(upload.Id); err != nil {
+			log.Errorf("error removing upload %s: %s", upload.Id, err)
+		}
+		upload.Status = trepo.UploadDone
+		w.notifyUpload(upload, nil)
+		return
+	}
+	log.Warningf("upload %s attempt %d failed: %s", upload.Id, upload.Attempts, err)
+
+	// a missing payload or a rejected request will never succeed
+	_, serr := os.Stat(upload.PayloadPath)
+	herr, isHTTP := err.(*net.HTTPError)
+	if os.IsNotExist(serr) || (isHTTP && !herr.Temporary()) || upload.Attempts >= maxUploadAttempts {
+		upload.Status = trepo.UploadFailed
+	}
+	if err := w.datastore.Uploads().Update(upload); err != nil {
+		log.Errorf("error updating upload %s: %s", upload.Id, err)
+	}
+	w.notifyUpload(upload, err)
+}
+
+// notifyUpload sends a status update to all upload subscribers
+func (w *Wallet) notifyUpload(upload *trepo.Upload, err error) {
+	update := model.UploadUpdate{
+		Id:       upload.Id,
+		Status:   upload.Status.String(),
+		Attempts: upload.Attempts,
+	}
+	if err != nil {
+		update.Error = err.Error()
+	}
+	w.sendUploadUpdate(update)
+}
+
+// notifyUploadProgress sends a progress update to all upload subscribers
+func (w *Wallet) notifyUploadProgress(upload *trepo.Upload, sent int64, total int64) {
+	w.sendUploadUpdate(model.UploadUpdate{
+		Id:       upload.Id,
+		Status:   "uploading",
+		Attempts: upload.Attempts,
+		Sent:     sent,
+		Total:    total,
+	})
+}
+
+// sendUploadUpdate delivers an update without blocking the queue
+func (w *Wallet) sendUploadUpdate(update model.UploadUpdate) {
+	w.uploadSubsMux.Lock()
+	defer w.uploadSubsMux.Unlock()
+	for _, sub := range w.uploadSubs {
+		select {
+		case sub <- update:
+		default:
+			log.Debugf("dropped upload update for %s", update.Id)
+		}
+	}
+}
+
+// runUploadQueue periodically flushes uploads once online, until the wallet is stopped
+func (w *Wallet) runUploadQueue(online <-chan struct{}, done <-chan struct{}) {
+	select {
+	case <-online:
+	case <-done:
+		return
+	}
+	if err := w.FlushUploads(); err != nil && err != ErrOffline {
+		log.Errorf("error flushing uploads: %s", err)
+	}
+	ticker := time.NewTicker(uploadFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.FlushUploads(); err != nil && err != ErrOffline {
+				log.Errorf("error flushing uploads: %s", err)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// uploadBackoff returns how long to wait after a number of failed attempts
+func uploadBackoff(attempts int) time.Duration {
+	if attempts <= 0 {
+		return 0
+	}
+	backoff := uploadBackoffBase
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= uploadBackoffMax {
+			return uploadBackoffMax
+		}
+	}
+	return backoff
+}
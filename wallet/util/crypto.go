@@ -4,28 +4,25 @@ import (
 	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
 	"github.com/textileio/textile-go/crypto"
 	"github.com/tyler-smith/go-bip39"
 	libp2pc "gx/ipfs/QmaPbCnUMBohSGo3KnxEa2bHqyJVVeEEcwtqJAYxerieBo/go-libp2p-crypto"
 	"io"
-	"io/ioutil"
 	"strconv"
 	"time"
 )
 
-// PrivKeyFromMnemonic creates a private key form a mnemonic phrase
-func PrivKeyFromMnemonic(mnemonic *string) (libp2pc.PrivKey, string, error) {
-	if mnemonic == nil {
-		mnemonics, err := createMnemonic(bip39.NewEntropy, bip39.NewMnemonic)
-		if err != nil {
-			return nil, "", err
-		}
-		mnemonic = &mnemonics
-	}
+var ErrBadMnemonic = errors.New("invalid mnemonic phrase")
 
-	// create the bip39 seed from the phrase
-	// TODO: allow password?
-	seed := bip39.NewSeed(*mnemonic, "")
+// PrivKeyFromMnemonic creates a private key form a mnemonic phrase and an optional passphrase
+func PrivKeyFromMnemonic(mnemonic *string, passphrase string) (libp2pc.PrivKey, string, error) {
+	seed, mnem, err := SeedFromMnemonic(mnemonic, passphrase)
+	if err != nil {
+		return nil, "", err
+	}
 	key, err := identityKeyFromSeed(seed)
 	if err != nil {
 		return nil, "", err
@@ -34,16 +31,34 @@ func PrivKeyFromMnemonic(mnemonic *string) (libp2pc.PrivKey, string, error) {
 	if err != nil {
 		return nil, "", err
 	}
-	return sk, *mnemonic, nil
+	return sk, mnem, nil
 }
 
-// IDAndSecretFromMnemonic create a secret key from a mnemonic,
+// SeedFromMnemonic returns the bip39 seed of a mnemonic phrase and an optional passphrase,
+// a new phrase is created if mnemonic is nil
+func SeedFromMnemonic(mnemonic *string, passphrase string) ([]byte, string, error) {
+	if mnemonic != nil && !bip39.IsMnemonicValid(*mnemonic) {
+		return nil, "", ErrBadMnemonic
+	}
+	if mnemonic == nil {
+		mnemonics, err := createMnemonic(bip39.NewEntropy, bip39.NewMnemonic)
+		if err != nil {
+			return nil, "", err
+		}
+		mnemonic = &mnemonics
+	}
+
+	// the passphrase acts as a 25th word
+	return bip39.NewSeed(*mnemonic, passphrase), *mnemonic, nil
+}
+
+// IDAndSecretFromMnemonic create a secret key from a mnemonic and an optional passphrase,
 // returns the mnemonic (it may have been generated),
 // the secret's public key (base64 string) as an id,
 // and the raw secret bytes.
 // Used for generating a new master identity.
-func IDAndSecretFromMnemonic(mnemonic *string) (mnem string, id string, secret []byte, err error) {
-	sk, mnem, err := PrivKeyFromMnemonic(mnemonic)
+func IDAndSecretFromMnemonic(mnemonic *string, passphrase string) (mnem string, id string, secret []byte, err error) {
+	sk, mnem, err := PrivKeyFromMnemonic(mnemonic, passphrase)
 	if err != nil {
 		return "", "", nil, err
 	}
@@ -59,13 +74,77 @@ func IDAndSecretFromMnemonic(mnemonic *string) (mnem string, id string, secret [
 	return mnem, id, skb, nil
 }
 
-// GetEncryptedReaderBytes reads reader bytes and returns the encrypted result
-func GetEncryptedReaderBytes(reader io.Reader, key []byte) ([]byte, error) {
-	bts, err := ioutil.ReadAll(reader)
+// HardenedOffset is added to an index to form a hardened SLIP-10 path element
+const HardenedOffset uint32 = 0x80000000
+
+// ThreadKeyPath is the SLIP-10 derivation path prefix for thread keys, the thread index is appended
+var ThreadKeyPath = []uint32{44 + HardenedOffset, 406 + HardenedOffset, 0 + HardenedOffset}
+
+// ThreadIndexPath is the SLIP-10 derivation path prefix for the keys of the published thread index
+var ThreadIndexPath = []uint32{44 + HardenedOffset, 406 + HardenedOffset, 1 + HardenedOffset}
+
+// ThreadKeyFromSeed deterministically derives the ed25519 key for a thread index from the master bip39 seed
+func ThreadKeyFromSeed(seed []byte, index int) (libp2pc.PrivKey, error) {
+	if index < 0 || int64(index) >= int64(HardenedOffset) {
+		return nil, errors.New("thread index must be positive and less than 2^31")
+	}
+	path := append(append([]uint32{}, ThreadKeyPath...), uint32(index)+HardenedOffset)
+	key, _, err := DeriveKeyFromSeed(seed, path)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519FromKey(key)
+}
+
+// ThreadIndexKeysFromSeed derives the keys used to publish the index of derived threads
+// from the master bip39 seed, an ed25519 key naming the index and an aes key encrypting it
+func ThreadIndexKeysFromSeed(seed []byte) (libp2pc.PrivKey, []byte, error) {
+	path := append(append([]uint32{}, ThreadIndexPath...), 0+HardenedOffset)
+	key, _, err := DeriveKeyFromSeed(seed, path)
 	if err != nil {
+		return nil, nil, err
+	}
+	sk, err := ed25519FromKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	path[len(path)-1] = 1 + HardenedOffset
+	aes, _, err := DeriveKeyFromSeed(seed, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sk, aes, nil
+}
+
+// DeriveKeyFromSeed returns the SLIP-10 ed25519 private key and chain code at path,
+// only hardened path elements are allowed for ed25519
+func DeriveKeyFromSeed(seed []byte, path []uint32) (key []byte, chain []byte, err error) {
+	hm := hmac.New(sha512.New, []byte("ed25519 seed"))
+	hm.Write(seed)
+	sum := hm.Sum(nil)
+	key, chain = sum[:32], sum[32:]
+	for _, index := range path {
+		if index < HardenedOffset {
+			return nil, nil, errors.New("ed25519 only supports hardened derivation")
+		}
+		data := make([]byte, 37)
+		copy(data[1:33], key)
+		binary.BigEndian.PutUint32(data[33:], index)
+		hm = hmac.New(sha512.New, chain)
+		hm.Write(data)
+		sum = hm.Sum(nil)
+		key, chain = sum[:32], sum[32:]
+	}
+	return key, chain, nil
+}
+
+// GetEncryptedReaderBytes encrypts reader bytes as a chunked stream and returns the result
+func GetEncryptedReaderBytes(reader io.Reader, key []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := crypto.EncryptAESStream(reader, &buf, key); err != nil {
 		return nil, err
 	}
-	return crypto.EncryptAES(bts, key)
+	return buf.Bytes(), nil
 }
 
 // GetNowBytes returns the current unix time as a byte string
@@ -104,6 +183,16 @@ func createMnemonic(newEntropy func(int) ([]byte, error), newMnemonic func([]byt
 	return mnemonic, nil
 }
 
+// ed25519FromKey returns the ed25519 key for a derived 32 byte key,
+// which fully determines an ed25519 key
+func ed25519FromKey(key []byte) (libp2pc.PrivKey, error) {
+	sk, _, err := libp2pc.GenerateKeyPairWithReader(libp2pc.Ed25519, 0, bytes.NewReader(key))
+	if err != nil {
+		return nil, err
+	}
+	return sk, nil
+}
+
 // identityKeyFromSeed returns a new key identity from a seed
 func identityKeyFromSeed(seed []byte) ([]byte, error) {
 	hm := hmac.New(sha256.New, []byte("scythian horde"))
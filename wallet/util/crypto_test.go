@@ -1,20 +1,182 @@
 package util_test
 
 import (
-	//. "github.com/textileio/textile-go/wallet/util"
+	"bytes"
+	"encoding/hex"
+	"github.com/textileio/textile-go/crypto"
+	. "github.com/textileio/textile-go/wallet/util"
+	libp2pc "gx/ipfs/QmaPbCnUMBohSGo3KnxEa2bHqyJVVeEEcwtqJAYxerieBo/go-libp2p-crypto"
 	"testing"
 )
 
+// slip10Vectors are the ed25519 test vector 1 from the SLIP-10 spec
+var slip10Vectors = []struct {
+	path  []uint32
+	key   string
+	chain string
+}{
+	{
+		path:  []uint32{},
+		key:   "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7",
+		chain: "90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb",
+	},
+	{
+		path:  []uint32{0 + HardenedOffset},
+		key:   "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3",
+		chain: "8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69",
+	},
+	{
+		path: []uint32{
+			0 + HardenedOffset, 1 + HardenedOffset, 2 + HardenedOffset,
+			2 + HardenedOffset, 1000000000 + HardenedOffset,
+		},
+		key:   "8f94d394a8e8fd6b1bc2f3f49f5c47e385281d5c17e65324b0f62483e37e8793",
+		chain: "68789923a0cac2cd5a29172a475fe9e0fb14cd6adb5ad98a3fa70333e7afa230",
+	},
+}
+
+// passphraseVectors holds a mnemonic with several passphrases, each of which must yield a distinct key
+var passphraseVectors = struct {
+	mnemonic    string
+	passphrases []string
+}{
+	mnemonic:    "legal winner thank year wave sausage worth useful legal winner thank yellow",
+	passphrases: []string{"", "TREZOR", "trezor", "TREZOR "},
+}
+
+// bip39Vectors are from the BIP39 reference vectors, which all use the passphrase "TREZOR"
+var bip39Vectors = []struct {
+	mnemonic   string
+	passphrase string
+	seed       string
+}{
+	{
+		mnemonic:   "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		passphrase: "TREZOR",
+		seed:       "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+	},
+	{
+		mnemonic:   "legal winner thank year wave sausage worth useful legal winner thank yellow",
+		passphrase: "TREZOR",
+		seed:       "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+	},
+	{
+		mnemonic:   "letter advice cage absurd amount doctor acoustic avoid letter advice cage above",
+		passphrase: "TREZOR",
+		seed:       "d71de856f81a8acc65e6fc851a38d4d7ec216fd0796d0a6827a3ad6ed5511a30fa280f12eb2e47ed2ac03b5c462a0358d18d69fe4f985ec81778c1b370b652a8",
+	},
+	{
+		mnemonic:   "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+		passphrase: "TREZOR",
+		seed:       "ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+	},
+	{
+		mnemonic:   "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+		passphrase: "",
+		seed:       "5eb00bbddcf069084889a8ab9155568165f5c453ccb85e70811aaed6f6da5fc19a5ac40b389cd370d086206dec8aa6c43daea6690f20ad3d8d48b2d2ce9e38e4",
+	},
+}
+
+func Test_SeedFromMnemonic(t *testing.T) {
+	for _, v := range bip39Vectors {
+		mnemonic := v.mnemonic
+		seed, mnem, err := SeedFromMnemonic(&mnemonic, v.passphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mnem != v.mnemonic {
+			t.Error("returned mnemonic does not match input")
+		}
+		if hex.EncodeToString(seed) != v.seed {
+			t.Errorf("seed for %q with passphrase %q: expected %s got %x", v.mnemonic, v.passphrase, v.seed, seed)
+		}
+	}
+}
+
 func Test_PrivKeyFromMnemonic(t *testing.T) {
-	// TODO
+	mnemonic := passphraseVectors.mnemonic
+	seen := make(map[string]string)
+	for _, pass := range passphraseVectors.passphrases {
+		sk, mnem, err := PrivKeyFromMnemonic(&mnemonic, pass)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mnem != mnemonic {
+			t.Error("returned mnemonic does not match input")
+		}
+		again, _, err := PrivKeyFromMnemonic(&mnemonic, pass)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !sk.Equals(again) {
+			t.Errorf("passphrase %q did not yield a stable key", pass)
+		}
+		skb, err := sk.Bytes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other, ok := seen[string(skb)]; ok {
+			t.Errorf("passphrases %q and %q yielded the same key", other, pass)
+		}
+		seen[string(skb)] = pass
+	}
+}
+
+func Test_PrivKeyFromMnemonicNew(t *testing.T) {
+	sk, mnem, err := PrivKeyFromMnemonic(nil, "TREZOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, _, err := PrivKeyFromMnemonic(&mnem, "TREZOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sk.Equals(restored) {
+		t.Error("generated mnemonic and passphrase did not restore the same key")
+	}
+	wrong, _, err := PrivKeyFromMnemonic(&mnem, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sk.Equals(wrong) {
+		t.Error("restore without passphrase should yield a different key")
+	}
 }
 
 func Test_IDAndSecretFromMnemonic(t *testing.T) {
-	// TODO
+	mnemonic := passphraseVectors.mnemonic
+	_, id1, secret1, err := IDAndSecretFromMnemonic(&mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, id2, secret2, err := IDAndSecretFromMnemonic(&mnemonic, "TREZOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id1 == id2 || string(secret1) == string(secret2) {
+		t.Error("different passphrases should yield different identities")
+	}
 }
 
 func Test_GetEncryptedReaderBytes(t *testing.T) {
-	// TODO
+	key, err := crypto.GenerateAESKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := bytes.Repeat([]byte("photo"), crypto.StreamChunkSize)
+	cypher, err := GetEncryptedReaderBytes(bytes.NewReader(plain), key)
+	if err != nil {
+		t.Errorf("get encrypted reader bytes failed: %s", err)
+		return
+	}
+	decrypted, err := crypto.DecryptAES(cypher, key)
+	if err != nil {
+		t.Errorf("decrypt encrypted reader bytes failed: %s", err)
+		return
+	}
+	if !bytes.Equal(decrypted, plain) {
+		t.Error("encrypted reader bytes decrypted badly")
+	}
 }
 
 func Test_UnmarshalPrivateKeyFromString(t *testing.T) {
@@ -36,3 +198,121 @@ func Test_createMnemonic(t *testing.T) {
 func Test_identityKeyFromSeed(t *testing.T) {
 	// TODO
 }
+
+func Test_DeriveKeyFromSeed(t *testing.T) {
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range slip10Vectors {
+		key, chain, err := DeriveKeyFromSeed(seed, v.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(key) != v.key {
+			t.Errorf("bad key for path %v", v.path)
+		}
+		if hex.EncodeToString(chain) != v.chain {
+			t.Errorf("bad chain code for path %v", v.path)
+		}
+	}
+	if _, _, err := DeriveKeyFromSeed(seed, []uint32{1}); err == nil {
+		t.Error("non-hardened derivation should fail")
+	}
+}
+
+func Test_ThreadKeyFromSeed(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	seed, _, err := SeedFromMnemonic(&mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, _, err := SeedFromMnemonic(&mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk0, err := ThreadKeyFromSeed(seed, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk1, err := ThreadKeyFromSeed(seed, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sk0.Equals(sk1) {
+		t.Error("thread keys at different indexes should differ")
+	}
+	again, err := ThreadKeyFromSeed(restored, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.Equals(sk1) {
+		t.Error("restored seed should derive the same thread key")
+	}
+
+	// the passphrase is part of the seed
+	other, _, err := SeedFromMnemonic(&mnemonic, "TREZOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong, err := ThreadKeyFromSeed(other, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wrong.Equals(sk1) {
+		t.Error("a different passphrase should derive a different thread key")
+	}
+
+	// derived keys are plain ed25519 keys usable as thread ids
+	pkb, err := again.GetPublic().Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, err := UnmarshalPublicKeyFromString(libp2pc.ConfigEncodeKey(pkb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pk.Equals(sk1.GetPublic()) {
+		t.Error("thread id did not round trip")
+	}
+
+	// indexes past the hardened range would wrap into non-hardened keys
+	var hardened int64 = int64(HardenedOffset)
+	if int64(int(hardened)) == hardened {
+		if _, err := ThreadKeyFromSeed(seed, int(hardened)); err == nil {
+			t.Error("thread index at the hardened offset should fail")
+		}
+	}
+	if _, err := ThreadKeyFromSeed(seed, -1); err == nil {
+		t.Error("negative thread index should fail")
+	}
+}
+
+func Test_ThreadIndexKeysFromSeed(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	seed, _, err := SeedFromMnemonic(&mnemonic, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk, aes, err := ThreadIndexKeysFromSeed(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk2, aes2, err := ThreadIndexKeysFromSeed(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sk.Equals(sk2) || !bytes.Equal(aes, aes2) {
+		t.Error("thread index keys should be reproducible from the seed")
+	}
+	if len(aes) != 32 {
+		t.Errorf("bad thread index aes key length: %d", len(aes))
+	}
+	thrd, err := ThreadKeyFromSeed(seed, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sk.Equals(thrd) {
+		t.Error("thread index key should differ from thread keys")
+	}
+}
@@ -0,0 +1,129 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/disintegration/imaging"
+	"github.com/textileio/textile-go/wallet/model"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrBadEdit = errors.New("edits must be rotate:<90|180|270>, crop:<x>,<y>,<width>,<height>, or flip:<h|v>")
+
+// ParseEdit reads an edit written as op:args, e.g. rotate:90, crop:0,0,640,480, or flip:h
+func ParseEdit(str string) (model.Edit, error) {
+	parts := strings.SplitN(str, ":", 2)
+	if len(parts) != 2 {
+		return model.Edit{}, ErrBadEdit
+	}
+	edit := model.Edit{Op: parts[0]}
+	switch edit.Op {
+	case "rotate":
+		angle, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return model.Edit{}, ErrBadEdit
+		}
+		edit.Angle = angle
+	case "crop":
+		var dims []int
+		for _, arg := range strings.Split(parts[1], ",") {
+			dim, err := strconv.Atoi(arg)
+			if err != nil {
+				return model.Edit{}, ErrBadEdit
+			}
+			dims = append(dims, dim)
+		}
+		if len(dims) != 4 {
+			return model.Edit{}, ErrBadEdit
+		}
+		edit.X, edit.Y, edit.Width, edit.Height = dims[0], dims[1], dims[2], dims[3]
+	case "flip":
+		edit.Axis = parts[1]
+	default:
+		return model.Edit{}, ErrBadEdit
+	}
+	if err := checkEdit(edit); err != nil {
+		return model.Edit{}, err
+	}
+	return edit, nil
+}
+
+// ApplyEdits applies edits to an image in order, crops are relative to the image at that point
+func ApplyEdits(img image.Image, edits []model.Edit) (image.Image, error) {
+	for _, edit := range edits {
+		if err := checkEdit(edit); err != nil {
+			return nil, err
+		}
+		switch edit.Op {
+		case "rotate":
+			switch edit.Angle {
+			case 90:
+				img = imaging.Rotate270(img) // imaging rotates counter-clockwise
+			case 180:
+				img = imaging.Rotate180(img)
+			case 270:
+				img = imaging.Rotate90(img)
+			}
+		case "crop":
+			bounds := img.Bounds()
+			rect := image.Rect(edit.X, edit.Y, edit.X+edit.Width, edit.Y+edit.Height).Add(bounds.Min)
+			if !rect.In(bounds) {
+				return nil, errors.New(fmt.Sprintf("crop %s is outside the %dx%d image", edit.String(), bounds.Dx(), bounds.Dy()))
+			}
+			img = imaging.Crop(img, rect)
+		case "flip":
+			if edit.Axis == "h" {
+				img = imaging.FlipH(img)
+			} else {
+				img = imaging.FlipV(img)
+			}
+		}
+	}
+	return img, nil
+}
+
+// EditImage decodes an image, applies edits, and re-encodes it, returning the new format.
+// Animated images are flattened to their first frame.
+func EditImage(reader io.Reader, edits []model.Edit) (*bytes.Reader, string, error) {
+	img, format, err := image.Decode(reader)
+	if err != nil {
+		return nil, "", err
+	}
+	if format != "jpeg" {
+		format = "png"
+	}
+	img, err = ApplyEdits(img, edits)
+	if err != nil {
+		return nil, "", err
+	}
+	edited, err := encodeSingleImage(img, format)
+	if err != nil {
+		return nil, "", err
+	}
+	return edited, format, nil
+}
+
+// checkEdit validates an edit's op and arguments
+func checkEdit(edit model.Edit) error {
+	switch edit.Op {
+	case "rotate":
+		if edit.Angle != 90 && edit.Angle != 180 && edit.Angle != 270 {
+			return ErrBadEdit
+		}
+	case "crop":
+		if edit.X < 0 || edit.Y < 0 || edit.Width <= 0 || edit.Height <= 0 {
+			return ErrBadEdit
+		}
+	case "flip":
+		if edit.Axis != "h" && edit.Axis != "v" {
+			return ErrBadEdit
+		}
+	default:
+		return ErrBadEdit
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package util_test
+
+import (
+	"github.com/textileio/textile-go/wallet/model"
+	. "github.com/textileio/textile-go/wallet/util"
+	"image"
+	"image/color"
+	"os"
+	"testing"
+)
+
+func Test_ParseEdit(t *testing.T) {
+	edit, err := ParseEdit("crop:10,20,30,40")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edit.X != 10 || edit.Y != 20 || edit.Width != 30 || edit.Height != 40 {
+		t.Errorf("bad crop edit: %+v", edit)
+	}
+	if edit.String() != "crop:10,20,30,40" {
+		t.Errorf("bad edit string: %s", edit.String())
+	}
+	for _, bad := range []string{"rotate:45", "crop:1,2,3", "flip:x", "blur:2", "rotate"} {
+		if _, err := ParseEdit(bad); err != ErrBadEdit {
+			t.Errorf("parsing %s returned wrong error: %v", bad, err)
+		}
+	}
+}
+
+func Test_ApplyEdits(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	img.Set(0, 0, color.White) // top left
+
+	// a clockwise quarter turn moves the top left to the top right
+	rotated, err := ApplyEdits(img, []model.Edit{{Op: "rotate", Angle: 90}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := rotated.Bounds(); b.Dx() != 2 || b.Dy() != 4 {
+		t.Errorf("rotated image is %dx%d", b.Dx(), b.Dy())
+	}
+	if r, _, _, _ := rotated.At(1, 0).RGBA(); r == 0 {
+		t.Error("rotate should be clockwise")
+	}
+
+	// crops apply to the edited image
+	edited, err := ApplyEdits(img, []model.Edit{
+		{Op: "flip", Axis: "h"},
+		{Op: "crop", X: 2, Y: 0, Width: 2, Height: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := edited.Bounds(); b.Dx() != 2 || b.Dy() != 1 {
+		t.Errorf("cropped image is %dx%d", b.Dx(), b.Dy())
+	}
+	if r, _, _, _ := edited.At(1, 0).RGBA(); r == 0 {
+		t.Error("crop should keep the flipped corner")
+	}
+
+	if _, err := ApplyEdits(img, []model.Edit{{Op: "crop", Width: 5, Height: 1}}); err == nil {
+		t.Error("crop outside the image should fail")
+	}
+}
+
+func Test_EditImage(t *testing.T) {
+	file, err := os.Open("../testdata/image.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	conf, _, err := image.DecodeConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Seek(0, 0)
+	reader, format, err := EditImage(file, []model.Edit{{Op: "rotate", Angle: 270}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" {
+		t.Errorf("edited jpeg became %s", format)
+	}
+	edited, _, err := image.DecodeConfig(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Width != conf.Height || edited.Height != conf.Width {
+		t.Error("edited image was not rotated")
+	}
+}
@@ -0,0 +1,46 @@
+package util
+
+import "math"
+
+// MaxTileLatitude is the furthest latitude from the equator covered by map tiles
+const MaxTileLatitude = 85.0511287798
+
+// MaxTileZoom is the deepest supported tile zoom level
+const MaxTileZoom = 22
+
+// TileForLocation returns the x, y coordinates of the web mercator map tile
+// containing a location at a zoom level
+func TileForLocation(lat float64, lon float64, zoom int) (int, int) {
+	n := math.Exp2(float64(zoom))
+	lat = math.Max(-MaxTileLatitude, math.Min(MaxTileLatitude, lat))
+	rad := lat * math.Pi / 180
+	x := int(math.Floor((lon + 180) / 360 * n))
+	y := int(math.Floor((1 - math.Log(math.Tan(rad)+1/math.Cos(rad))/math.Pi) / 2 * n))
+	return clampTile(x, int(n)), clampTile(y, int(n))
+}
+
+// MeanLongitude averages longitudes as unit vectors, so points either side of the
+// antimeridian average near it rather than near the prime meridian
+func MeanLongitude(lons []float64) float64 {
+	var x, y float64
+	for _, lon := range lons {
+		rad := lon * math.Pi / 180
+		x += math.Cos(rad)
+		y += math.Sin(rad)
+	}
+	if x == 0 && y == 0 {
+		return 0
+	}
+	return math.Atan2(y, x) * 180 / math.Pi
+}
+
+// clampTile keeps a tile coordinate on the map, since the east and south edges round up
+func clampTile(c int, n int) int {
+	if c < 0 {
+		return 0
+	}
+	if c >= n {
+		return n - 1
+	}
+	return c
+}
@@ -0,0 +1,45 @@
+package util_test
+
+import (
+	. "github.com/textileio/textile-go/wallet/util"
+	"math"
+	"testing"
+)
+
+func Test_TileForLocation(t *testing.T) {
+	vectors := []struct {
+		lat, lon float64
+		zoom     int
+		x, y     int
+	}{
+		{lat: 0, lon: 0, zoom: 0, x: 0, y: 0},
+		{lat: 40.7128, lon: -74.006, zoom: 10, x: 301, y: 385},
+		{lat: -33.8688, lon: 151.2093, zoom: 12, x: 3768, y: 2457},
+		{lat: 90, lon: 180, zoom: 2, x: 3, y: 0},
+		{lat: -90, lon: -180, zoom: 2, x: 0, y: 3},
+	}
+	for _, v := range vectors {
+		x, y := TileForLocation(v.lat, v.lon, v.zoom)
+		if x != v.x || y != v.y {
+			t.Errorf("tile for %f, %f at zoom %d: expected %d/%d got %d/%d", v.lat, v.lon, v.zoom, v.x, v.y, x, y)
+		}
+	}
+}
+
+func Test_MeanLongitude(t *testing.T) {
+	vectors := []struct {
+		lons []float64
+		mean float64
+	}{
+		{lons: []float64{10, 20}, mean: 15},
+		{lons: []float64{179, -179}, mean: 180},
+		{lons: []float64{170, -160}, mean: -175},
+		{lons: []float64{-74.006}, mean: -74.006},
+	}
+	for _, v := range vectors {
+		mean := MeanLongitude(v.lons)
+		if math.Abs(mean-v.mean) > 1e-9 && math.Abs(math.Abs(mean-v.mean)-360) > 1e-9 {
+			t.Errorf("mean longitude of %v: expected %f got %f", v.lons, v.mean, mean)
+		}
+	}
+}
@@ -13,9 +13,11 @@ import (
 	"gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/core/coreapi"
 	"gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/core/coreapi/interface/options"
 	"gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/core/coreunix"
+	dag "gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/merkledag"
 	uio "gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/unixfs/io"
 	"gx/ipfs/QmcZfnkapfECQGcLZaf9B79NRg7cRa9EnZh4LSbkCzwNvY/go-cid"
 	ipld "gx/ipfs/Qme5bWv7wtjUNGsK2BNGVUFPKiuxWrsqrtvYwCLRw8YFES/go-ipld-format"
+	"io"
 	"io/ioutil"
 	"sort"
 	"strings"
@@ -52,6 +54,67 @@ func GetDataAtPath(ipfs *core.IpfsNode, path string) ([]byte, error) {
 	return ioutil.ReadAll(r)
 }
 
+// IsLinkNotFound returns whether err means a named link does not exist under a node,
+// as opposed to the node itself being unreachable
+func IsLinkNotFound(err error) bool {
+	if err == nil {
+		return false
+	}
+	return err == dag.ErrLinkNotFound || strings.HasPrefix(err.Error(), "no link named")
+}
+
+// ReadSeekCloser is a reader that can seek and must be closed
+type ReadSeekCloser interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// GetReaderAtPath returns a seekable reader for any data under an ipfs path, which must be closed
+func GetReaderAtPath(ipfs *core.IpfsNode, path string) (ReadSeekCloser, error) {
+	// convert string to an ipfs path
+	ip, err := coreapi.ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	api := coreapi.NewCoreAPI(ipfs)
+	ctx, cancel := context.WithTimeout(ipfs.Context(), catTimeout)
+	r, err := api.Unixfs().Cat(ctx, ip)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &cancelReader{ReadSeekCloser: r, cancel: cancel}, nil
+}
+
+// ResolvePath returns the cid of the node under an ipfs path
+func ResolvePath(ipfs *core.IpfsNode, path string) (*cid.Cid, error) {
+	ip, err := coreapi.ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	api := coreapi.NewCoreAPI(ipfs)
+	ctx, cancel := context.WithTimeout(ipfs.Context(), catTimeout)
+	defer cancel()
+	node, err := api.ResolveNode(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+	return node.Cid(), nil
+}
+
+// cancelReader cancels its context when closed
+type cancelReader struct {
+	ReadSeekCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelReader) Close() error {
+	defer c.cancel()
+	return c.ReadSeekCloser.Close()
+}
+
 // PrintSwarmAddrs prints the addresses of the host
 func PrintSwarmAddrs(node *core.IpfsNode) error {
 	var lisAddrs []string
@@ -136,7 +199,11 @@ func PeersWithAddresses(addrs []string) (pis []pstore.PeerInfo, err error) {
 
 // AddFileToDirectory adds bytes as file to a virtual directory (dag) structure
 func AddFileToDirectory(ipfs *core.IpfsNode, dirb *uio.Directory, data []byte, fname string) error {
-	reader := bytes.NewReader(data)
+	return AddReaderToDirectory(ipfs, dirb, bytes.NewReader(data), fname)
+}
+
+// AddReaderToDirectory adds everything from reader as a file in a virtual directory
+func AddReaderToDirectory(ipfs *core.IpfsNode, dirb *uio.Directory, reader io.Reader, fname string) error {
 	str, err := coreunix.Add(ipfs, reader)
 	if err != nil {
 		return err
@@ -1,7 +1,8 @@
 package util_test
 
 import (
-	//. "github.com/textileio/textile-go/wallet/util"
+	"errors"
+	. "github.com/textileio/textile-go/wallet/util"
 	"testing"
 )
 
@@ -9,6 +10,15 @@ func Test_GetDataAtPath(t *testing.T) {
 	// TODO
 }
 
+func Test_IsLinkNotFound(t *testing.T) {
+	if !IsLinkNotFound(errors.New(`no link named "large" under QmUNLLsPACCz1vLxQVkXqqLX5R1X345qqfHbsf67hvA3Nn`)) {
+		t.Error("missing link error not detected")
+	}
+	if IsLinkNotFound(errors.New("context deadline exceeded")) || IsLinkNotFound(nil) {
+		t.Error("other errors should not be missing link errors")
+	}
+}
+
 func Test_PrintSwarmAddrs(t *testing.T) {
 	// TODO
 }
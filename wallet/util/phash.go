@@ -0,0 +1,40 @@
+package util
+
+import (
+	"github.com/disintegration/imaging"
+	"image"
+	"io"
+	"math/bits"
+)
+
+// DifferenceHash returns a 64 bit perceptual hash of an image's horizontal gradients,
+// which barely changes when the image is scaled, recompressed, or lightly edited
+func DifferenceHash(reader io.Reader) (uint64, error) {
+	img, _, err := image.Decode(reader)
+	if err != nil {
+		return 0, err
+	}
+	return differenceHash(img), nil
+}
+
+// HashDistance returns the number of bits that differ between two perceptual hashes
+func HashDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// differenceHash compares the brightness of neighboring pixels in a 9x8 grayscale copy
+func differenceHash(img image.Image) uint64 {
+	small := imaging.Grayscale(imaging.Resize(img, 9, 8, imaging.Box))
+	var hash uint64
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			left := small.Pix[small.PixOffset(x, y)]
+			right := small.Pix[small.PixOffset(x+1, y)]
+			hash <<= 1
+			if left > right {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
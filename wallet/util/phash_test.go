@@ -0,0 +1,60 @@
+package util_test
+
+import (
+	"bytes"
+	"github.com/disintegration/imaging"
+	. "github.com/textileio/textile-go/wallet/util"
+	"image"
+	"image/jpeg"
+	"os"
+	"testing"
+)
+
+func Test_DifferenceHash(t *testing.T) {
+	file, err := os.Open("../testdata/image.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := hashImage(t, img)
+	if hash == 0 {
+		t.Error("hash should not be empty")
+	}
+
+	// a smaller, recompressed copy should be a near duplicate
+	small := hashImage(t, imaging.Resize(img, 200, 0, imaging.Lanczos))
+	if dist := HashDistance(hash, small); dist > 6 {
+		t.Errorf("resized copy is %d bits away", dist)
+	}
+
+	// a mirror image should not be
+	flipped := hashImage(t, imaging.FlipH(img))
+	if dist := HashDistance(hash, flipped); dist < 20 {
+		t.Errorf("flipped copy is only %d bits away", dist)
+	}
+}
+
+func Test_HashDistance(t *testing.T) {
+	if HashDistance(0xff, 0xff) != 0 {
+		t.Error("equal hashes should have no distance")
+	}
+	if HashDistance(0, 0xf0f0) != 8 {
+		t.Error("distance should count differing bits")
+	}
+}
+
+func hashImage(t *testing.T, img image.Image) uint64 {
+	buff := new(bytes.Buffer)
+	if err := jpeg.Encode(buff, img, &jpeg.Options{Quality: 50}); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := DifferenceHash(buff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
@@ -6,6 +6,8 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rwcarlsen/goexif/exif"
 	"github.com/textileio/textile-go/wallet/model"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
 	"image"
 	"image/color/palette"
 	"image/draw"
@@ -13,92 +15,248 @@ import (
 	"image/jpeg"
 	"image/png"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 )
 
+// PhotoExtensions are the file extensions of formats DecodeImage can handle
+var PhotoExtensions = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+	".gif":  true,
+	".webp": true,
+	".tif":  true,
+	".tiff": true,
+	".bmp":  true,
+}
+
+// normalizedFormats maps each decodable format to the format it's re-encoded as.
+// webp, tiff and bmp are re-encoded as png, which is lossless, keeps any alpha,
+// and is readable everywhere (x/image has no webp encoder).
+var normalizedFormats = map[string]string{
+	"jpeg": "jpeg",
+	"png":  "png",
+	"webp": "png",
+	"tiff": "png",
+	"bmp":  "png",
+}
+
 type ThumbnailFormat int
 
 const (
 	JPEG ThumbnailFormat = iota
 	GIF
+	PNG
 )
 
-// DecodeImage returns a cleaned reader from an image file
+func (f ThumbnailFormat) String() string {
+	switch f {
+	case JPEG:
+		return "jpeg"
+	case GIF:
+		return "gif"
+	case PNG:
+		return "png"
+	default:
+		return "INVALID"
+	}
+}
+
+// GetThumbnailFormat returns the thumbnail format for a decoded image,
+// png is used when the image has transparency so it isn't lost
+func GetThumbnailFormat(reader io.ReadSeeker, format string) (ThumbnailFormat, error) {
+	switch format {
+	case "gif":
+		return GIF, nil
+	case "png":
+		if _, err := reader.Seek(0, 0); err != nil {
+			return JPEG, err
+		}
+		img, _, err := image.Decode(reader)
+		if err != nil {
+			return JPEG, err
+		}
+		if hasAlpha(img) {
+			return PNG, nil
+		}
+	}
+	return JPEG, nil
+}
+
+// DecodeImage returns a cleaned reader from an image file and its format,
+// which is the normalized format for inputs that are re-encoded
 func DecodeImage(file *os.File) (*bytes.Reader, string, error) {
-	img, format, err := image.Decode(file)
+	var buf bytes.Buffer
+	format, err := DecodeImageTo(file, &buf)
 	if err != nil {
 		return nil, "", err
 	}
+	return bytes.NewReader(buf.Bytes()), format, nil
+}
 
-	var reader *bytes.Reader
-	file.Seek(0, 0)
+// DecodeImageTo writes a cleaned copy of an image file to w and returns its format,
+// which is the normalized format for inputs that are re-encoded
+func DecodeImageTo(file *os.File, w io.Writer) (string, error) {
+	img, format, err := decodeImage(file)
+	if err != nil {
+		return "", err
+	}
+	if normalized, ok := normalizedFormats[format]; ok {
+		format = normalized
+	}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		return "", err
+	}
 	if format != "gif" {
 		// decode exif
 		exf := DecodeExif(file)
 		img, err = correctOrientation(img, exf)
 		if err != nil {
-			return nil, "", err
+			return "", err
 		}
 
 		// re-encoding will remove exif
-		reader, err = encodeSingleImage(img, format)
-		if err != nil {
-			return nil, "", err
+		if err := encodeImage(w, img, format); err != nil {
+			return "", err
 		}
 	} else {
-		fileb, err := ioutil.ReadAll(file)
+		// re-encoding every frame drops comment and application extensions
+		g, err := gif.DecodeAll(file)
 		if err != nil {
-			return nil, "", err
+			return "", err
+		}
+		if err := gif.EncodeAll(w, g); err != nil {
+			return "", err
 		}
-		reader = bytes.NewReader(fileb)
 	}
-	return reader, format, nil
+	return format, nil
 }
 
-// GetMetaData reads any available meta/exif data from a photo
-// TODO: get image size info
-func GetMetadata(reader io.Reader, path string, ext string, username string) (model.PhotoMetadata, error) {
-	var created time.Time
-	var lat, lon float64
-	x, err := exif.Decode(reader)
-	if err == nil {
-		// time taken
-		createdTmp, err := x.DateTime()
-		if err == nil {
-			created = createdTmp
-		}
-		// coords taken
-		latTmp, lonTmp, err := x.LatLong()
-		if err == nil {
-			lat, lon = latTmp, lonTmp
-		}
-	}
+// GetMetaData reads any available meta/exif data from an original photo
+func GetMetadata(reader io.ReadSeeker, path string, ext string, username string) (model.PhotoMetadata, error) {
 	meta := model.PhotoMetadata{
 		FileMetadata: model.FileMetadata{
 			Metadata: model.Metadata{
 				Username: username,
-				Created:  created,
 				Added:    time.Now(),
 			},
 			Name: strings.TrimSuffix(filepath.Base(path), ext),
 			Ext:  ext,
 		},
-		Latitude:  lat,
-		Longitude: lon,
 	}
-	return meta, nil
+
+	// exif, if any
+	if x, err := exif.Decode(reader); err == nil {
+		readExif(x, &meta)
+	}
+
+	// size info
+	if _, err := reader.Seek(0, 0); err != nil {
+		return meta, err
+	}
+	conf, format, err := decodeImageConfig(reader)
+	if err == nil {
+		meta.Format = format
+		meta.Width, meta.Height = conf.Width, conf.Height
+
+		// orientations 5-8 are rotated a quarter turn when corrected
+		if meta.Orientation >= 5 && meta.Orientation <= 8 {
+			meta.Width, meta.Height = meta.Height, meta.Width
+		}
+	}
+	size, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return meta, err
+	}
+	meta.Size = size
+	_, err = reader.Seek(0, 0)
+	return meta, err
 }
 
-// MakeThumbnail creates a jpeg|gif thumbnail from an image
+// readExif copies the exif fields we care about into meta
+func readExif(x *exif.Exif, meta *model.PhotoMetadata) {
+	// time taken
+	if created, err := x.DateTime(); err == nil {
+		meta.Created = created
+	}
+	// coords taken
+	if lat, lon, err := x.LatLong(); err == nil {
+		meta.Latitude, meta.Longitude = lat, lon
+	}
+	// camera
+	meta.Make = exifString(x, exif.Make)
+	meta.Model = exifString(x, exif.Model)
+	meta.Lens = exifString(x, exif.LensModel)
+	if tag, err := x.Get(exif.Orientation); err == nil {
+		if orient, err := tag.Int(0); err == nil {
+			meta.Orientation = orient
+		}
+	}
+	// exposure
+	if tag, err := x.Get(exif.FocalLength); err == nil {
+		if rat, err := tag.Rat(0); err == nil {
+			meta.FocalLength, _ = rat.Float64()
+		}
+	}
+	if tag, err := x.Get(exif.FNumber); err == nil {
+		if rat, err := tag.Rat(0); err == nil {
+			meta.FNumber, _ = rat.Float64()
+		}
+	}
+	if tag, err := x.Get(exif.ISOSpeedRatings); err == nil {
+		if iso, err := tag.Int(0); err == nil {
+			meta.ISO = iso
+		}
+	}
+	if tag, err := x.Get(exif.ExposureTime); err == nil {
+		if rat, err := tag.Rat(0); err == nil {
+			meta.ExposureTime = rat.RatString()
+		}
+	}
+}
+
+// exifString returns a trimmed string tag, or empty if missing
+func exifString(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+	val, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(val, "\x00"))
+}
+
+// GetPhotoDate returns when a photo was taken from exif, falling back to the file's mod time
+func GetPhotoDate(path string) (time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+	if exf := DecodeExif(file); exf != nil {
+		if date, err := exf.DateTime(); err == nil {
+			return date, nil
+		}
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// MakeThumbnail creates a jpeg|gif|png thumbnail from an image
 func MakeThumbnail(reader io.Reader, format ThumbnailFormat, width int) ([]byte, error) {
 	var result []byte
 	switch format {
 	case JPEG:
-		img, _, err := image.Decode(reader)
+		img, _, err := decodeImage(reader)
 		if err != nil {
 			return nil, err
 		}
@@ -108,6 +266,17 @@ func MakeThumbnail(reader io.Reader, format ThumbnailFormat, width int) ([]byte,
 			return nil, err
 		}
 		result = buff.Bytes()
+	case PNG:
+		img, _, err := decodeImage(reader)
+		if err != nil {
+			return nil, err
+		}
+		thumb := imaging.Resize(img, width, 0, imaging.Lanczos)
+		buff := new(bytes.Buffer)
+		if err = png.Encode(buff, thumb); err != nil {
+			return nil, err
+		}
+		result = buff.Bytes()
 	case GIF:
 		img, err := gif.DecodeAll(reader)
 		if err != nil {
@@ -133,6 +302,32 @@ func MakeThumbnail(reader io.Reader, format ThumbnailFormat, width int) ([]byte,
 	return result, nil
 }
 
+// MakeRenditions creates a thumbnail for each rendition narrower than the image, keyed by name
+func MakeRenditions(reader io.ReadSeeker, format ThumbnailFormat, renditions []model.Rendition) (map[string][]byte, error) {
+	if _, err := reader.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	conf, _, err := image.DecodeConfig(reader)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]byte)
+	for _, r := range renditions {
+		if r.Width >= conf.Width {
+			continue
+		}
+		if _, err := reader.Seek(0, 0); err != nil {
+			return nil, err
+		}
+		thumb, err := MakeThumbnail(reader, format, r.Width)
+		if err != nil {
+			return nil, err
+		}
+		result[r.Name] = thumb
+	}
+	return result, nil
+}
+
 // DecodeExif returns exif data from a reader if present
 func DecodeExif(reader io.Reader) *exif.Exif {
 	exf, err := exif.Decode(reader)
@@ -148,7 +343,9 @@ func correctOrientation(img image.Image, exf *exif.Exif) (image.Image, error) {
 		return img, nil
 	}
 	orient, err := exf.Get(exif.Orientation)
-	if err != nil {
+	if exif.IsTagNotPresentError(err) {
+		orient = nil
+	} else if err != nil {
 		return nil, err
 	}
 	if orient != nil {
@@ -164,21 +361,24 @@ func correctOrientation(img image.Image, exf *exif.Exif) (image.Image, error) {
 // encodeSingleImage creates a reader from an image
 func encodeSingleImage(img image.Image, format string) (*bytes.Reader, error) {
 	writer := &bytes.Buffer{}
-	var err error
+	if err := encodeImage(writer, img, format); err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(writer.Bytes()), nil
+}
+
+// encodeImage writes an image to w in format
+func encodeImage(w io.Writer, img image.Image, format string) error {
 	switch format {
 	case "jpeg":
-		err = jpeg.Encode(writer, img, &jpeg.Options{Quality: 100})
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 100})
 	case "png":
 		// NOTE: while PNGs don't technically have exif data,
 		// they can contain meta data with sensitive info
-		err = png.Encode(writer, img)
+		return png.Encode(w, img)
 	default:
-		err = errors.New("unrecognized image format")
+		return errors.New("unrecognized image format")
 	}
-	if err != nil {
-		return nil, err
-	}
-	return bytes.NewReader(writer.Bytes()), nil
 }
 
 // reverseOrientation transforms the given orientation to 1
@@ -205,6 +405,16 @@ func reverseOrientation(img image.Image, orientation string) *image.NRGBA {
 	return imaging.Clone(img)
 }
 
+// hasAlpha returns whether or not an image has any transparent pixels
+func hasAlpha(img image.Image) bool {
+	if o, ok := img.(interface {
+		Opaque() bool
+	}); ok {
+		return !o.Opaque()
+	}
+	return false
+}
+
 func imageToPaletted(img image.Image) *image.Paletted {
 	b := img.Bounds()
 	pm := image.NewPaletted(b, palette.Plan9)
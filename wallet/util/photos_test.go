@@ -2,9 +2,11 @@ package util_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/textileio/textile-go/wallet/model"
 	. "github.com/textileio/textile-go/wallet/util"
+	"image"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -13,34 +15,79 @@ import (
 )
 
 type testImage struct {
-	path    string
-	name    string
-	ext     string
-	format  string
-	hasExif bool
+	path       string
+	name       string
+	ext        string
+	format     string
+	normalized string
+	make       string
+	hasExif    bool
+	hasNotes   bool // carries a "private notes" marker in its meta data
 }
 
 var images = []testImage{
 	{
-		path:    "../testdata/image.jpg",
-		name:    "image",
-		ext:     ".jpg",
-		format:  "jpeg",
-		hasExif: true,
+		path:       "../testdata/image.jpg",
+		name:       "image",
+		ext:        ".jpg",
+		format:     "jpeg",
+		normalized: "jpeg",
+		make:       "Apple",
+		hasExif:    true,
+		hasNotes:   true,
 	},
 	{
-		path:    "../testdata/image.png",
-		name:    "image",
-		ext:     ".png",
-		format:  "png",
-		hasExif: false,
+		path:       "../testdata/image.png",
+		name:       "image",
+		ext:        ".png",
+		format:     "png",
+		normalized: "png",
+		hasExif:    false,
+		hasNotes:   true,
 	},
 	{
-		path:    "../testdata/image.gif",
-		name:    "image",
-		ext:     ".gif",
-		format:  "gif",
-		hasExif: false,
+		path:       "../testdata/image.gif",
+		name:       "image",
+		ext:        ".gif",
+		format:     "gif",
+		normalized: "gif",
+		hasExif:    false,
+		hasNotes:   true,
+	},
+	{
+		path:       "../testdata/image.webp",
+		name:       "image",
+		ext:        ".webp",
+		format:     "webp",
+		normalized: "png",
+		hasExif:    false,
+	},
+	{
+		path:       "../testdata/image_lossy.webp",
+		name:       "image_lossy",
+		ext:        ".webp",
+		format:     "webp",
+		normalized: "png",
+		hasExif:    false,
+		hasNotes:   true,
+	},
+	{
+		path:       "../testdata/image.tiff",
+		name:       "image",
+		ext:        ".tiff",
+		format:     "tiff",
+		normalized: "png",
+		make:       "Textile",
+		hasExif:    false,
+		hasNotes:   true,
+	},
+	{
+		path:       "../testdata/image.bmp",
+		name:       "image",
+		ext:        ".bmp",
+		format:     "bmp",
+		normalized: "png",
+		hasExif:    false,
 	},
 }
 
@@ -56,8 +103,14 @@ func Test_DecodeImage(t *testing.T) {
 			t.Fatal(err)
 		}
 		file.Close()
-		if format != i.format {
-			t.Errorf("wrong format")
+		if format != i.normalized {
+			t.Errorf("wrong format for %s: %s", i.format, format)
+		}
+
+		// ensure the output is in the normalized format
+		reader.Seek(0, 0)
+		if _, decoded, err := image.DecodeConfig(reader); err != nil || decoded != i.normalized {
+			t.Errorf("bad output for %s: %s, %v", i.format, decoded, err)
 		}
 
 		// ensure exif was removed
@@ -66,6 +119,25 @@ func Test_DecodeImage(t *testing.T) {
 		if exf2 != nil {
 			t.Error("exif data not removed")
 		}
+
+		// ensure other meta data was removed
+		if i.hasNotes {
+			original, err := ioutil.ReadFile(i.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(original, []byte("private notes")) {
+				t.Fatalf("%s fixture is missing its meta data marker", i.path)
+			}
+		}
+		reader.Seek(0, 0)
+		cleaned, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(cleaned, []byte("private notes")) {
+			t.Errorf("meta data not removed from %s", i.path)
+		}
 	}
 }
 
@@ -84,7 +156,7 @@ func Test_GetMetadata(t *testing.T) {
 			t.Fatal(err)
 		}
 		file.Close()
-		if meta.Name != "image" {
+		if meta.Name != i.name {
 			t.Error("bad photo meta name")
 		}
 		if meta.Ext != i.ext {
@@ -99,6 +171,35 @@ func Test_GetMetadata(t *testing.T) {
 		if (i.hasExif && meta.Longitude == 0) || (!i.hasExif && meta.Longitude != 0) {
 			t.Error("bad photo meta longitude")
 		}
+		if meta.Width == 0 || meta.Height == 0 {
+			t.Error("bad photo meta dimensions")
+		}
+		if meta.Format != i.format {
+			t.Error("bad photo meta format")
+		}
+		if meta.Size == 0 {
+			t.Error("bad photo meta size")
+		}
+		if meta.Make != i.make {
+			t.Errorf("bad photo meta make: %s", meta.Make)
+		}
+		if i.hasExif && (meta.Model == "" || meta.FocalLength == 0 || meta.ISO == 0 || meta.ExposureTime == "") {
+			t.Error("bad photo meta exposure")
+		}
+	}
+}
+
+func Test_GetMetadataBackwardCompatible(t *testing.T) {
+	old := `{"un":"bob","cts":"2018-05-21T11:07:48Z","ats":"2018-05-22T10:00:00Z","name":"image","ext":".jpg","lat":40.7,"lon":-74}`
+	var meta model.PhotoMetadata
+	if err := json.Unmarshal([]byte(old), &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.Username != "bob" || meta.Name != "image" || meta.Latitude != 40.7 || meta.Longitude != -74 {
+		t.Errorf("old photo meta decoded badly: %+v", meta)
+	}
+	if meta.Created.IsZero() || meta.Added.IsZero() {
+		t.Error("old photo meta dates decoded badly")
 	}
 }
 
@@ -132,3 +233,114 @@ func Test_MakeThumbnail(t *testing.T) {
 		}
 	}
 }
+
+func Test_MakeRenditions(t *testing.T) {
+	file, err := os.Open("../testdata/image.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, _, err := DecodeImage(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renditions := []model.Rendition{
+		{Name: "tiny", Width: 50},
+		{Name: "huge", Width: 100000},
+	}
+	rends, err := MakeRenditions(reader, JPEG, renditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rends["tiny"]) == 0 {
+		t.Error("missing tiny rendition")
+	}
+	if _, ok := rends["huge"]; ok {
+		t.Error("rendition wider than the original should be skipped")
+	}
+}
+
+func Test_DecodeImageTo(t *testing.T) {
+	for _, i := range images {
+		file, err := os.Open(i.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, _, err := DecodeImage(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Seek(0, 0)
+		tmp, err := ioutil.TempFile("", "decoded")
+		if err != nil {
+			t.Fatal(err)
+		}
+		format, err := DecodeImageTo(file, tmp)
+		file.Close()
+		tmp.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != i.normalized {
+			t.Errorf("wrong format for %s: %s", i.format, format)
+		}
+		written, err := ioutil.ReadFile(tmp.Name())
+		os.Remove(tmp.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := ioutil.ReadAll(reader)
+		if !bytes.Equal(written, expected) {
+			t.Errorf("decoding %s to a file differs from decoding to memory", i.format)
+		}
+	}
+}
+
+func Test_GetThumbnailFormat(t *testing.T) {
+	formats := map[string]ThumbnailFormat{
+		"../testdata/image.jpg":       JPEG,
+		"../testdata/image.png":       JPEG,
+		"../testdata/image.gif":       GIF,
+		"../testdata/image_alpha.png": PNG,
+	}
+	for path, expected := range formats {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, format, err := DecodeImage(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		thumbFormat, err := GetThumbnailFormat(reader, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if thumbFormat != expected {
+			t.Errorf("wrong thumbnail format for %s: %s", path, thumbFormat)
+		}
+	}
+}
+
+func Test_MakeThumbnailTransparent(t *testing.T) {
+	file, err := os.Open("../testdata/image_alpha.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	thumb, err := MakeThumbnail(file, PNG, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, format, err := image.Decode(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" {
+		t.Errorf("wrong thumbnail format: %s", format)
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a != 0 {
+		t.Error("thumbnail transparency not preserved")
+	}
+}
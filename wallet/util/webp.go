@@ -0,0 +1,113 @@
+package util
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"golang.org/x/image/webp"
+	"image"
+	"io"
+	"io/ioutil"
+)
+
+// webp extended format flags
+const (
+	webpAnimationBit = 1 << 1
+	webpAlphaBit     = 1 << 4
+)
+
+// decodeImage decodes an image, cleaning webp containers first
+func decodeImage(reader io.Reader) (image.Image, string, error) {
+	br := bufio.NewReader(reader)
+	if !isWebP(br) {
+		return image.Decode(br)
+	}
+	data, err := ioutil.ReadAll(br)
+	if err != nil {
+		return nil, "", err
+	}
+	img, err := webp.Decode(bytes.NewReader(cleanWebP(data)))
+	return img, "webp", err
+}
+
+// decodeImageConfig decodes an image's dimensions, cleaning webp containers first
+func decodeImageConfig(reader io.Reader) (image.Config, string, error) {
+	br := bufio.NewReader(reader)
+	if !isWebP(br) {
+		return image.DecodeConfig(br)
+	}
+	data, err := ioutil.ReadAll(br)
+	if err != nil {
+		return image.Config{}, "", err
+	}
+	conf, err := webp.DecodeConfig(bytes.NewReader(cleanWebP(data)))
+	return conf, "webp", err
+}
+
+// isWebP returns whether a reader starts with a webp riff header
+func isWebP(br *bufio.Reader) bool {
+	head, err := br.Peek(12)
+	if err != nil {
+		return false
+	}
+	return string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP"
+}
+
+// cleanWebP drops the exif, xmp, and icc chunks of an extended webp file, which the
+// decoder rejects, keeping only the image and its alpha. Files without alpha become
+// simple webp files. Anything else, e.g. animations, is returned as is.
+func cleanWebP(data []byte) []byte {
+	if len(data) < 30 || string(data[12:16]) != "VP8X" {
+		return data
+	}
+	var vp8x, alph, img []byte
+	for off := 12; off+8 <= len(data); {
+		fourcc := string(data[off : off+4])
+		size := int(binary.LittleEndian.Uint32(data[off+4 : off+8]))
+		end := off + 8 + size
+		if end > len(data) {
+			return data
+		}
+		switch fourcc {
+		case "VP8X":
+			vp8x = data[off+8 : end]
+		case "ALPH":
+			alph = data[off:end]
+		case "VP8 ", "VP8L":
+			img = data[off:end]
+		case "ANIM", "ANMF":
+			return data
+		}
+		off = end + size%2
+	}
+	if len(vp8x) != 10 || vp8x[0]&webpAnimationBit != 0 || img == nil {
+		return data
+	}
+
+	var body bytes.Buffer
+	body.WriteString("WEBP")
+	if alph != nil && string(img[:4]) == "VP8 " {
+		flags := make([]byte, 10)
+		copy(flags, vp8x)
+		flags[0] = webpAlphaBit
+		writeRIFFChunk(&body, "VP8X", flags)
+		writeRIFFChunk(&body, "ALPH", alph[8:])
+	}
+	writeRIFFChunk(&body, string(img[:4]), img[8:])
+
+	var out bytes.Buffer
+	out.WriteString("RIFF")
+	binary.Write(&out, binary.LittleEndian, uint32(body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+// writeRIFFChunk writes a chunk with its header and padding
+func writeRIFFChunk(w *bytes.Buffer, fourcc string, data []byte) {
+	w.WriteString(fourcc)
+	binary.Write(w, binary.LittleEndian, uint32(len(data)))
+	w.Write(data)
+	if len(data)%2 == 1 {
+		w.WriteByte(0)
+	}
+}
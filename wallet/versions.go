@@ -0,0 +1,123 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	trepo "github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet/model"
+	"github.com/textileio/textile-go/wallet/thread"
+	"github.com/textileio/textile-go/wallet/util"
+	"image"
+	"time"
+)
+
+var ErrNoEdits = errors.New("at least one edit is required")
+
+// EditPhoto adds a version of a photo in a thread with some edits applied. Edits are made on top of
+// the photo's latest version, but always rendered from the original so it's never degraded.
+// The id may be the original photo or any of its versions.
+func (w *Wallet) EditPhoto(id string, thrd *thread.Thread, edits []model.Edit) (*model.AddResult, error) {
+	if !w.started {
+		return nil, ErrStopped
+	}
+	if len(edits) == 0 {
+		return nil, ErrNoEdits
+	}
+
+	// find the original photo and the edits already made to it
+	original := id
+	if version := w.datastore.PhotoVersions().GetByTarget(thrd.Id, id); version != nil {
+		original = version.Original
+	}
+	query := fmt.Sprintf("pk='%s' and type=%d and target='%s'", thrd.Id, trepo.PhotoBlock, original)
+	blocks := w.datastore.Blocks().List("", 1, query)
+	if len(blocks) == 0 {
+		return nil, errors.New(fmt.Sprintf("could not find photo %s in thread %s", original, thrd.Name))
+	}
+	block := &blocks[0]
+	prior, err := w.PhotoEdits(original, thrd)
+	if err != nil {
+		return nil, err
+	}
+	edits = append(prior, edits...)
+	editsb, err := json.Marshal(edits)
+	if err != nil {
+		return nil, err
+	}
+
+	// versions are indexed alongside photo hashes so the same edits are only made once
+	// under the same location policy
+	policy, grid, err := w.ThreadLocationPolicy(thrd.Id)
+	if err != nil {
+		return nil, err
+	}
+	vhash := fmt.Sprintf("%s/%s/%d/%g", original, editsb, policy, grid)
+	added := w.existingPhoto(vhash)
+	if added == nil {
+		added, err = w.addPhotoVersion(original, block, thrd, edits, policy, grid, vhash)
+		if err != nil {
+			return nil, err
+		}
+		w.QueueUploadOrDiscard(added.RemoteRequest)
+	}
+	return thrd.AddVersion(original, added.Id, added.Key, editsb)
+}
+
+// PhotoEdits returns the edits of a photo's latest version in a thread, if any
+func (w *Wallet) PhotoEdits(original string, thrd *thread.Thread) ([]model.Edit, error) {
+	latest := w.datastore.PhotoVersions().Latest(thrd.Id, original)
+	if latest == nil {
+		return nil, nil
+	}
+	block := w.datastore.Blocks().Get(latest.BlockId)
+	if block == nil {
+		return nil, errors.New(fmt.Sprintf("could not find version block %s", latest.BlockId))
+	}
+	editsb, err := thrd.GetVersionEdits(block)
+	if err != nil {
+		return nil, err
+	}
+	var edits []model.Edit
+	if err := json.Unmarshal(editsb, &edits); err != nil {
+		return nil, err
+	}
+	return edits, nil
+}
+
+// addPhotoVersion renders edits on an original photo and adds the result
+func (w *Wallet) addPhotoVersion(original string, block *trepo.Block, thrd *thread.Thread, edits []model.Edit, policy trepo.LocationPolicy, grid float64, hash string) (*model.AddResult, error) {
+	reader, err := thrd.GetFileReader(fmt.Sprintf("%s/photo", original), block)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	edited, format, err := util.EditImage(reader, edits)
+	if err != nil {
+		return nil, err
+	}
+
+	// the version keeps the original's meta data, except for what the edits changed
+	// and the location, which follows the thread's policy like shared photos
+	meta, err := thrd.GetPhotoMetaData(original, block)
+	if err != nil {
+		return nil, err
+	}
+	applyLocationPolicy(meta, policy, grid)
+	conf, _, err := image.DecodeConfig(edited)
+	if err != nil {
+		return nil, err
+	}
+	meta.Width, meta.Height = conf.Width, conf.Height
+	meta.Format = format
+	meta.Size = edited.Size()
+	meta.Orientation = 0
+	meta.Added = time.Now()
+
+	added, err := w.addPhoto(edited, format, *meta, hash)
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("edited %s as %s", original, added.Id)
+	return added, nil
+}
@@ -2,6 +2,8 @@ package wallet
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -21,6 +23,7 @@ import (
 	"gx/ipfs/QmSwZMWwFZSUpe5muU2xgTUwppH24KfMwdPXiwbEp2c6G5/go-libp2p-swarm"
 	pstore "gx/ipfs/QmXauCuJzmzapetmC6W4TuDJLL1yFFrVzSHoWv8YdbmnxH/go-libp2p-peerstore"
 	libp2pn "gx/ipfs/QmXfkENeeBvh3zYA51MaSdGUdBjhQ99cP5WQe8zgr6wchG/go-libp2p-net"
+	"gx/ipfs/QmZoWKhxUmZ2seW4BzX6fJkNR8hh9PsGModr7q171yq2SS/go-libp2p-peer"
 	libp2pc "gx/ipfs/QmaPbCnUMBohSGo3KnxEa2bHqyJVVeEEcwtqJAYxerieBo/go-libp2p-crypto"
 	utilmain "gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/cmd/ipfs/util"
 	oldcmds "gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/commands"
@@ -29,42 +32,64 @@ import (
 	"gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/repo/fsrepo"
 	uio "gx/ipfs/QmcKwjeebv5SX3VFUGDFa4BNMYhy14RRaCzQP7JN3UQDpB/go-ipfs/unixfs/io"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
 var log = logging.MustGetLogger("wallet")
 
 type Config struct {
-	Version        string
-	RepoPath       string
-	CentralAPI     string
-	IsMobile       bool
-	IsServer       bool
-	SwarmPort      string
-	MasterMnemonic *string
+	Version           string
+	RepoPath          string
+	CentralAPI        string
+	IsMobile          bool
+	IsServer          bool
+	SwarmPort         string
+	MasterMnemonic    *string
+	MasterPassphrase  string
+	DatastorePassword string
+	Renditions        []model.Rendition
+	UploadChunkSize   int64
 }
 
 type Wallet struct {
 	context        oldcmds.Context
 	repoPath       string
 	gatewayAddr    string
+	apiAddr        string
 	cancel         context.CancelFunc
 	ipfs           *core.IpfsNode
 	datastore      trepo.Datastore
 	centralAPI     string
 	isMobile       bool
+	renditions     []model.Rendition
 	started        bool
+	password       string
+	locked         bool
 	threads        []*thread.Thread
+	threadsMux     sync.RWMutex
 	done           chan struct{}
 	lastRelayTouch time.Time
+	recent         map[string]time.Time
+	recentMux      sync.Mutex
+	gcMux          sync.Mutex
+	uploadMux      sync.Mutex
+	uploadChunk    int64
+	uploadSubs     []chan model.UploadUpdate
+	uploadSubsMux  sync.Mutex
+	secretMux      sync.Mutex
+	restoring      bool
+	mnemonic       string
 }
 
 const (
 	pingTimeout        = time.Second * 10
 	relayTouchInterval = time.Minute * 2
+	gatewaySecretSize  = 32
 )
 
 var ErrStarted = errors.New("node is already started")
@@ -72,22 +97,50 @@ var ErrStopped = errors.New("node is already stopped")
 var ErrOffline = errors.New("node is offline")
 var ErrThreadExists = errors.New("thread already exists")
 var ErrThreadLoaded = errors.New("thread is already loaded")
+var ErrThreadNotFound = errors.New("thread not found")
+var ErrLocked = errors.New("datastore is locked")
+var ErrBadPassword = errors.New("datastore password is incorrect")
+var ErrNotEncrypted = errors.New("datastore is not encrypted")
+var ErrBadRendition = errors.New("rendition name is reserved or duplicated")
 
 func NewWallet(config Config) (*Wallet, error) {
+	// check thumbnail renditions
+	if config.Renditions == nil {
+		config.Renditions = model.DefaultRenditions
+	}
+	if err := checkRenditions(config.Renditions); err != nil {
+		return nil, err
+	}
+
 	// get database handle
-	sqliteDB, err := db.Create(config.RepoPath, "")
+	sqliteDB, err := db.Create(config.RepoPath, config.DatastorePassword)
 	if err != nil {
 		return nil, err
 	}
 
 	// we may be running in an uninitialized state.
-	err = trepo.DoInit(config.RepoPath, config.IsMobile, config.Version,
+	var restoring bool
+	var mnemonic string
+	err = trepo.DoInit(config.RepoPath, config.IsMobile, config.Version, config.DatastorePassword,
 		sqliteDB.Config().Init, sqliteDB.Config().Configure, func() error {
-			_, id, secret, err := util.IDAndSecretFromMnemonic(nil)
+			mnem, id, secret, err := util.IDAndSecretFromMnemonic(config.MasterMnemonic, config.MasterPassphrase)
+			if err != nil {
+				return err
+			}
+			if err := sqliteDB.Profile().Init(id, secret); err != nil {
+				return err
+			}
+
+			// derived thread keys come from the same seed as the master key
+			seed, _, err := util.SeedFromMnemonic(&mnem, config.MasterPassphrase)
 			if err != nil {
 				return err
 			}
-			return sqliteDB.Profile().Init(id, secret)
+			restoring = config.MasterMnemonic != nil
+			if !restoring {
+				mnemonic = mnem
+			}
+			return sqliteDB.Profile().SetSeed(seed)
 		})
 	if err != nil && err != trepo.ErrRepoExists {
 		return nil, err
@@ -108,6 +161,15 @@ func NewWallet(config Config) (*Wallet, error) {
 		return nil, err
 	}
 
+	// save api address, adding one to repos without it
+	apiAddr, err := repo.GetConfigKey(tconfig.ApiAddressKey)
+	if addr, ok := apiAddr.(string); err != nil || !ok || addr == "" {
+		apiAddr = tconfig.NewApiAddress()
+		if err := tconfig.Update(repo, tconfig.ApiAddressKey, apiAddr); err != nil {
+			return nil, err
+		}
+	}
+
 	// if a specific swarm port was selected, set it in the config
 	if config.SwarmPort != "" {
 		log.Infof("using specified swarm port: %s", config.SwarmPort)
@@ -145,12 +207,44 @@ func NewWallet(config Config) (*Wallet, error) {
 		config.CentralAPI = ca
 	}
 
+	// an encrypted datastore stays locked until the right password is given
+	password := config.DatastorePassword
+	locked := sqliteDB.Config().IsEncrypted()
+	if locked && password != "" {
+		encrypted, err := db.IsEncrypted(config.RepoPath)
+		if err != nil {
+			return nil, err
+		}
+		if !encrypted {
+			log.Warning("datastore is not encrypted, ignoring password")
+			sqliteDB.Close()
+			sqliteDB, err = db.Create(config.RepoPath, "")
+			if err != nil {
+				return nil, err
+			}
+			password, locked = "", false
+		} else {
+			sqliteDB.Close()
+			return nil, ErrBadPassword
+		}
+	}
+	if locked {
+		log.Info("datastore is locked")
+	}
+
 	return &Wallet{
 		repoPath:    config.RepoPath,
 		gatewayAddr: gwAddr.(string),
+		apiAddr:     apiAddr.(string),
 		datastore:   sqliteDB,
 		centralAPI:  config.CentralAPI,
 		isMobile:    config.IsMobile,
+		renditions:  config.Renditions,
+		uploadChunk: config.UploadChunkSize,
+		password:    password,
+		locked:      locked,
+		restoring:   restoring,
+		mnemonic:    mnemonic,
 	}, nil
 }
 
@@ -159,13 +253,17 @@ func (w *Wallet) Start() (chan struct{}, error) {
 	if w.started {
 		return nil, ErrStarted
 	}
+	if w.locked {
+		return nil, ErrLocked
+	}
+	onlineCh := make(chan struct{})
 	defer func() {
 		w.done = make(chan struct{})
 		w.started = true
 		w.lastRelayTouch = time.Time{}
+		go w.runUploadQueue(onlineCh, w.done)
 	}()
 	log.Info("starting wallet...")
-	onlineCh := make(chan struct{})
 
 	// raise file descriptor limit
 	if err := utilmain.ManageFdLimit(); err != nil {
@@ -195,6 +293,16 @@ func (w *Wallet) Start() (chan struct{}, error) {
 			log.Errorf("failed to read listening addresses: %s", err)
 		}
 		log.Info("wallet is online")
+
+		// bring back derived threads when restoring from a mnemonic
+		if w.restoring {
+			w.restoring = false
+			go func() {
+				if _, err := w.RestoreThreads(); err != nil {
+					log.Errorf("error restoring threads: %s", err)
+				}
+			}()
+		}
 	}()
 
 	// setup threads
@@ -240,7 +348,9 @@ func (w *Wallet) Stop() error {
 	}
 
 	// wipe threads
+	w.threadsMux.Lock()
 	w.threads = nil
+	w.threadsMux.Unlock()
 
 	log.Info("wallet is stopped")
 
@@ -266,10 +376,124 @@ func (w *Wallet) GetGatewayAddress() string {
 	return w.gatewayAddr
 }
 
+// GetApiAddress returns the address of the local rest api
+func (w *Wallet) GetApiAddress() string {
+	return w.apiAddr
+}
+
+// Renditions returns the configured thumbnail renditions
+func (w *Wallet) Renditions() []model.Rendition {
+	return w.renditions
+}
+
 func (w *Wallet) GetRepoPath() string {
 	return w.repoPath
 }
 
+// Locked returns whether or not the datastore is waiting for a password
+func (w *Wallet) Locked() bool {
+	return w.locked
+}
+
+// Encrypted returns whether or not the datastore is encrypted with a password
+func (w *Wallet) Encrypted() (bool, error) {
+	if w.locked {
+		return true, nil
+	}
+	return db.IsEncrypted(w.repoPath)
+}
+
+// Unlock opens an encrypted datastore with password
+func (w *Wallet) Unlock(password string) error {
+	if !w.locked {
+		return nil
+	}
+	sqliteDB, err := db.Create(w.repoPath, password)
+	if err != nil {
+		return err
+	}
+	if sqliteDB.Config().IsEncrypted() {
+		sqliteDB.Close()
+		return ErrBadPassword
+	}
+	w.datastore.Close()
+	w.datastore = sqliteDB
+	w.password = password
+	w.locked = false
+	log.Info("datastore is unlocked")
+	return nil
+}
+
+// Lock closes an encrypted datastore and forgets its password, the wallet must be stopped
+func (w *Wallet) Lock() error {
+	if w.started {
+		return ErrStarted
+	}
+	if w.locked {
+		return nil
+	}
+	if w.password == "" {
+		return ErrNotEncrypted
+	}
+	w.datastore.Close()
+	w.password = ""
+	w.locked = true
+	log.Info("datastore is locked")
+	return nil
+}
+
+// EncryptDatastore migrates a plaintext datastore to one encrypted with password,
+// the wallet must be stopped
+func (w *Wallet) EncryptDatastore(password string) error {
+	if w.started {
+		return ErrStarted
+	}
+	if w.locked {
+		return ErrLocked
+	}
+	w.datastore.Close()
+	if err := db.Encrypt(w.repoPath, password); err != nil {
+		log.Errorf("error encrypting datastore: %s", err)
+
+		// the plaintext datastore is left in place, keep using it
+		sqliteDB, rerr := db.Create(w.repoPath, "")
+		if rerr != nil {
+			log.Errorf("error reopening datastore: %s", rerr)
+			return err
+		}
+		w.datastore = sqliteDB
+		return err
+	}
+	sqliteDB, err := db.Create(w.repoPath, password)
+	if err != nil {
+		return err
+	}
+	w.datastore = sqliteDB
+	w.password = password
+	log.Info("datastore is encrypted")
+	return nil
+}
+
+// ChangeDatastorePassword re-keys an encrypted datastore with a new password,
+// the current password must be given
+func (w *Wallet) ChangeDatastorePassword(current string, password string) error {
+	if err := w.touchDatastore(); err != nil {
+		return err
+	}
+	if w.password == "" {
+		return ErrNotEncrypted
+	}
+	if subtle.ConstantTimeCompare([]byte(current), []byte(w.password)) != 1 {
+		return ErrBadPassword
+	}
+	if err := w.datastore.Rekey(password); err != nil {
+		log.Errorf("error re-keying datastore: %s", err)
+		return err
+	}
+	w.password = password
+	return nil
+}
+
 // SignUp requests a new username and token from the central api and saves them locally
 func (w *Wallet) SignUp(reg *cmodels.Registration) error {
 	if err := w.touchDatastore(); err != nil {
@@ -376,6 +600,12 @@ func (w *Wallet) GetIPFSPeerId() (string, error) {
 	return w.ipfs.Identity.Pretty(), nil
 }
 
+// Mnemonic returns the master mnemonic phrase if it was generated while creating this wallet,
+// it is not stored and should be written down, along with any passphrase, to restore the wallet
+func (w *Wallet) Mnemonic() string {
+	return w.mnemonic
+}
+
 // GetMasterPrivKey returns the current user's master secret key
 func (w *Wallet) GetMasterPrivKey() (libp2pc.PrivKey, error) {
 	if err := w.touchDatastore(); err != nil {
@@ -417,11 +647,16 @@ func (w *Wallet) GetCentralUserAPI() string {
 	return fmt.Sprintf("%s/api/v1/users", w.centralAPI)
 }
 
+// Threads returns a copy of the loaded threads
 func (w *Wallet) Threads() []*thread.Thread {
-	return w.threads
+	w.threadsMux.RLock()
+	defer w.threadsMux.RUnlock()
+	return append([]*thread.Thread{}, w.threads...)
 }
 
 func (w *Wallet) GetThread(id string) *thread.Thread {
+	w.threadsMux.RLock()
+	defer w.threadsMux.RUnlock()
 	for _, thrd := range w.threads {
 		if thrd.Id == id {
 			return thrd
@@ -431,6 +666,8 @@ func (w *Wallet) GetThread(id string) *thread.Thread {
 }
 
 func (w *Wallet) GetThreadByName(name string) *thread.Thread {
+	w.threadsMux.RLock()
+	defer w.threadsMux.RUnlock()
 	for _, thrd := range w.threads {
 		if thrd.Name == name {
 			return thrd
@@ -472,8 +709,8 @@ func (w *Wallet) AddThread(name string, secret libp2pc.PrivKey) (*thread.Thread,
 	return thrd, nil
 }
 
-// AddThreadWithMnemonic adds a thread with a given name and mnemonic phrase
-func (w *Wallet) AddThreadWithMnemonic(name string, mnemonic *string) (*thread.Thread, string, error) {
+// AddThreadWithMnemonic adds a thread with a given name, mnemonic phrase and optional passphrase
+func (w *Wallet) AddThreadWithMnemonic(name string, mnemonic *string, passphrase string) (*thread.Thread, string, error) {
 	existing, err := w.getThreadModelByName(name)
 	if err != nil {
 		return nil, "", err
@@ -488,7 +725,7 @@ func (w *Wallet) AddThreadWithMnemonic(name string, mnemonic *string) (*thread.T
 	} else {
 		log.Debugf("generating keypair for: %s", name)
 	}
-	secret, mnem, err := util.PrivKeyFromMnemonic(mnemonic)
+	secret, mnem, err := util.PrivKeyFromMnemonic(mnemonic, passphrase)
 	if err != nil {
 		return nil, "", err
 	}
@@ -499,51 +736,243 @@ func (w *Wallet) AddThreadWithMnemonic(name string, mnemonic *string) (*thread.T
 	return thrd, mnem, nil
 }
 
+// AddDerivedThread adds a thread with a given name and a key derived from
+// the master seed at the next thread index, returning the index used
+func (w *Wallet) AddDerivedThread(name string) (*thread.Thread, int, error) {
+	existing, err := w.getThreadModelByName(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	if existing != nil {
+		return nil, 0, ErrThreadExists
+	}
+	index, err := w.datastore.Profile().GetThreadIndex()
+	if err != nil {
+		return nil, 0, err
+	}
+	thrd, err := w.RestoreThread(name, index)
+	if err != nil {
+		return nil, 0, err
+	}
+	return thrd, index, nil
+}
+
+// AddNewThread adds a thread with a key derived from the master seed, or from a new random
+// mnemonic in repos created before the seed was stored. The index is -1 when a mnemonic is returned.
+func (w *Wallet) AddNewThread(name string) (*thread.Thread, int, string, error) {
+	if _, err := w.masterSeed(); err == ErrNoSeed {
+		log.Warningf("no master seed, generating a mnemonic for: %s", name)
+		thrd, mnem, err := w.AddThreadWithMnemonic(name, nil, "")
+		return thrd, -1, mnem, err
+	} else if err != nil {
+		return nil, 0, "", err
+	}
+	thrd, index, err := w.AddDerivedThread(name)
+	return thrd, index, "", err
+}
+
+// RestoreThread adds a thread with a given name and a key derived from the master seed at index
+func (w *Wallet) RestoreThread(name string, index int) (*thread.Thread, error) {
+	thrd, err := w.restoreThread(name, index)
+	if err != nil {
+		return nil, err
+	}
+
+	// let a wallet restored from the same mnemonic find this thread
+	if w.Online() {
+		go func() {
+			if err := w.PublishThreadIndex(); err != nil {
+				log.Errorf("error publishing thread index: %s", err)
+			}
+		}()
+	}
+	return thrd, nil
+}
+
+// restoreThread adds a thread derived at index and records the index
+func (w *Wallet) restoreThread(name string, index int) (*thread.Thread, error) {
+	log.Debugf("deriving keypair at index %d for: %s", index, name)
+	seed, err := w.masterSeed()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := util.ThreadKeyFromSeed(seed, index)
+	if err != nil {
+		return nil, err
+	}
+	thrd, err := w.AddThread(name, secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := w.datastore.ThreadIndexes().Add(&trepo.ThreadIndex{ThreadId: thrd.Id, Index: index}); err != nil {
+		return nil, err
+	}
+
+	// make sure the next derived thread won't reuse this index
+	next, err := w.datastore.Profile().GetThreadIndex()
+	if err != nil {
+		return nil, err
+	}
+	if index >= next {
+		if err := w.datastore.Profile().SetThreadIndex(index + 1); err != nil {
+			return nil, err
+		}
+	}
+	return thrd, nil
+}
+
+// RemoveThread leaves a thread and deletes it with its blocks,
+// leaving photos that are no longer referenced for the next gc
+func (w *Wallet) RemoveThread(id string) error {
+	if err := w.touchDatastore(); err != nil {
+		return err
+	}
+	thrd := w.GetThread(id)
+	if thrd == nil {
+		return ErrThreadNotFound
+	}
+	if thrd.Listening() {
+		thrd.Unsubscribe()
+		<-thrd.LeftCh
+	}
+
+	// remove blocks along with their index entries
+	query := fmt.Sprintf("pk='%s'", id)
+	for _, block := range w.datastore.Blocks().List("", -1, query) {
+		if err := w.datastore.Search().Delete(block.Id); err != nil {
+			log.Warningf("error removing search entry for %s: %s", block.Id, err)
+		}
+		if err := w.datastore.PhotoLocations().Delete(block.Id); err != nil {
+			log.Warningf("error removing location for %s: %s", block.Id, err)
+		}
+		if err := w.datastore.Timeline().Delete(block.Id); err != nil {
+			log.Warningf("error removing timeline entry for %s: %s", block.Id, err)
+		}
+		if err := w.datastore.PhotoVersions().Delete(block.Id); err != nil {
+			log.Warningf("error removing version for %s: %s", block.Id, err)
+		}
+		if err := w.datastore.Blocks().Delete(block.Id); err != nil {
+			return err
+		}
+	}
+	if err := w.datastore.ThreadLocations().Delete(id); err != nil {
+		return err
+	}
+	derived := w.datastore.ThreadIndexes().Get(id) != nil
+	if err := w.datastore.ThreadIndexes().Delete(id); err != nil {
+		return err
+	}
+	if err := w.datastore.Threads().Delete(id); err != nil {
+		return err
+	}
+	w.threadsMux.Lock()
+	for i, t := range w.threads {
+		if t == thrd {
+			w.threads = append(w.threads[:i], w.threads[i+1:]...)
+			break
+		}
+	}
+	w.threadsMux.Unlock()
+	log.Infof("removed thread %s", thrd.Name)
+
+	// a restored wallet should not bring the thread back
+	if derived && w.Online() {
+		go func() {
+			if err := w.PublishThreadIndex(); err != nil {
+				log.Errorf("error publishing thread index: %s", err)
+			}
+		}()
+	}
+	return nil
+}
+
 // PublishThreads publishes HEAD for each thread
 func (w *Wallet) PublishThreads() {
-	for _, t := range w.threads {
+	for _, t := range w.Threads() {
 		go func(thrd *thread.Thread) {
 			thrd.PostHead()
 		}(t)
 	}
 }
 
-// AddPhoto add a photo to the local ipfs node
+// AddPhoto add a photo to the local ipfs node, or returns the existing target for a duplicate
 func (w *Wallet) AddPhoto(path string) (*model.AddResult, error) {
-	// get a key to encrypt with
-	key, err := crypto.GenerateAESKey()
+	// read file from disk
+	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 
-	// read file from disk
-	file, err := os.Open(path)
+	// return the existing target if this photo was already added
+	hash, err := hashPhoto(file)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	if existing := w.existingPhoto(hash); existing != nil {
+		return existing, nil
+	}
+
+	// decode image to a temp file, it may be large
+	clean, err := ioutil.TempFile(filepath.Join(w.repoPath, "tmp"), "clean")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(clean.Name())
+	defer clean.Close()
+	format, err := util.DecodeImageTo(file, clean)
+	if err != nil {
+		return nil, err
+	}
+
+	// get username, ignoring if not present (not signed in)
+	username, _ := w.datastore.Profile().GetUsername()
+
+	// path info
+	fpath := file.Name()
+	ext := strings.ToLower(filepath.Ext(fpath))
+
+	// get metadata from the original, since decoding removed exif
+	meta, err := util.GetMetadata(file, fpath, ext, username)
+	if err != nil {
+		return nil, err
+	}
+	return w.addPhoto(clean, format, meta, hash)
+}
 
-	// decode image
-	reader, format, err := util.DecodeImage(file)
+// addPhoto encrypts and pins a decoded photo with its thumbnails and meta data,
+// indexing it under the hash of its source
+func (w *Wallet) addPhoto(reader io.ReadSeeker, format string, meta model.PhotoMetadata, hash string) (*model.AddResult, error) {
+	// get a key to encrypt with
+	key, err := crypto.GenerateAESKey()
+	if err != nil {
+		return nil, err
+	}
+
+	// hash its look to find near duplicates later
+	reader.Seek(0, 0)
+	phash, err := util.DifferenceHash(reader)
 	if err != nil {
 		return nil, err
 	}
 
 	// make a thumbnail
 	reader.Seek(0, 0)
-	var thumbFormat util.ThumbnailFormat
-	if format == "gif" {
-		thumbFormat = util.GIF
-	} else {
-		thumbFormat = util.JPEG
+	thumbFormat, err := util.GetThumbnailFormat(reader, format)
+	if err != nil {
+		return nil, err
 	}
+	reader.Seek(0, 0)
 	thumb, err := util.MakeThumbnail(reader, thumbFormat, model.ThumbnailWidth)
 	if err != nil {
 		return nil, err
 	}
+	renditions, err := util.MakeRenditions(reader, thumbFormat, w.renditions)
+	if err != nil {
+		return nil, err
+	}
 
-	// get username and master pub key, ignoring if not present (not signed in)
-	username, _ := w.datastore.Profile().GetUsername()
+	// get master pub key, ignoring if not present (not signed in)
 	mpk, _ := w.GetMasterPubKey()
 	var mpkb []byte
 	if mpk != nil {
@@ -553,27 +982,31 @@ func (w *Wallet) AddPhoto(path string) (*model.AddResult, error) {
 		}
 	}
 
-	// path info
-	fpath := file.Name()
-	ext := strings.ToLower(filepath.Ext(fpath))
-
-	// get metadata
-	reader.Seek(0, 0)
-	meta, err := util.GetMetadata(reader, fpath, ext, username)
-	if err != nil {
-		return nil, err
+	meta.ThumbFormat = thumbFormat.String()
+	meta.Renditions = make(map[string]int)
+	for _, r := range w.renditions {
+		if _, ok := renditions[r.Name]; ok {
+			meta.Renditions[r.Name] = r.Width
+		}
 	}
 	metab, err := json.Marshal(meta)
 	if err != nil {
 		return nil, err
 	}
 
-	// encrypt files
+	// stream encrypt the photo to disk, it may be large
 	reader.Seek(0, 0)
-	photocypher, err := util.GetEncryptedReaderBytes(reader, key)
+	photocypher, err := ioutil.TempFile(filepath.Join(w.repoPath, "tmp"), "photo")
 	if err != nil {
 		return nil, err
 	}
+	defer os.Remove(photocypher.Name())
+	defer photocypher.Close()
+	if err := crypto.EncryptAESStream(reader, photocypher, key); err != nil {
+		return nil, err
+	}
+
+	// encrypt files
 	thumbcypher, err := crypto.EncryptAES(thumb, key)
 	if err != nil {
 		return nil, err
@@ -586,10 +1019,18 @@ func (w *Wallet) AddPhoto(path string) (*model.AddResult, error) {
 	if err != nil {
 		return nil, err
 	}
+	rendcyphers := make(map[string][]byte)
+	for name, rend := range renditions {
+		rendcyphers[name], err = crypto.EncryptAES(rend, key)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// create a virtual directory for the photo
 	dirb := uio.NewDirectory(w.ipfs.DAG)
-	err = util.AddFileToDirectory(w.ipfs, dirb, photocypher, "photo")
+	photocypher.Seek(0, 0)
+	err = util.AddReaderToDirectory(w.ipfs, dirb, photocypher, "photo")
 	if err != nil {
 		return nil, err
 	}
@@ -605,23 +1046,38 @@ func (w *Wallet) AddPhoto(path string) (*model.AddResult, error) {
 	if err != nil {
 		return nil, err
 	}
+	for _, r := range w.renditions {
+		if cypher, ok := rendcyphers[r.Name]; ok {
+			if err := util.AddFileToDirectory(w.ipfs, dirb, cypher, r.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
 
 	// pin the directory
 	dir, err := dirb.GetNode()
 	if err != nil {
 		return nil, err
 	}
+	id := dir.Cid().Hash().B58String()
+	w.markRecent(id)
 	if err := util.PinDirectory(w.ipfs, dir, []string{"photo"}); err != nil {
 		return nil, err
 	}
-	id := dir.Cid().Hash().B58String()
+	if err := w.indexPhotoHash(hash, id, key); err != nil {
+		return nil, err
+	}
+	if err := w.indexPerceptualHash(id, phash); err != nil {
+		return nil, err
+	}
 
 	// create and init a new multipart request
 	request := &net.MultipartRequest{}
 	request.Init(filepath.Join(w.repoPath, "tmp"), id)
 
 	// add files to request
-	if err := request.AddFile(photocypher, "photo"); err != nil {
+	photocypher.Seek(0, 0)
+	if err := request.AddFileReader(photocypher, "photo"); err != nil {
 		return nil, err
 	}
 	if err := request.AddFile(thumbcypher, "thumb"); err != nil {
@@ -633,6 +1089,13 @@ func (w *Wallet) AddPhoto(path string) (*model.AddResult, error) {
 	if err := request.AddFile(mpkcypher, "pk"); err != nil {
 		return nil, err
 	}
+	for _, r := range w.renditions {
+		if cypher, ok := rendcyphers[r.Name]; ok {
+			if err := request.AddFile(cypher, r.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
 
 	// finish request
 	if err := request.Finish(); err != nil {
@@ -669,12 +1132,57 @@ func (w *Wallet) GetDataAtPath(path string) ([]byte, error) {
 	return util.GetDataAtPath(w.ipfs, path)
 }
 
-// GetIPFSPubKeyString returns the base64 encoded public ipfs peer key
-func (w *Wallet) GetIPFSPubKeyString() (string, error) {
+// ResolvePath returns the cid of the content under an ipfs path
+func (w *Wallet) ResolvePath(path string) (string, error) {
 	if !w.started {
 		return "", ErrStopped
 	}
-	pkb, err := w.ipfs.PrivateKey.GetPublic().Bytes()
+	id, err := util.ResolvePath(w.ipfs, path)
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// GatewaySecret returns the key used to sign gateway urls, creating it on first use
+func (w *Wallet) GatewaySecret() ([]byte, error) {
+	w.secretMux.Lock()
+	defer w.secretMux.Unlock()
+	if err := w.touchDatastore(); err != nil {
+		return nil, err
+	}
+	secret, err := w.datastore.Config().GetGatewaySecret()
+	if err != nil {
+		return nil, err
+	}
+	if secret != nil {
+		return secret, nil
+	}
+	secret = make([]byte, gatewaySecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := w.datastore.Config().SetGatewaySecret(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// GetIPFSPubKey returns the public ipfs peer key
+func (w *Wallet) GetIPFSPubKey() (libp2pc.PubKey, error) {
+	if !w.started {
+		return nil, ErrStopped
+	}
+	return w.ipfs.PrivateKey.GetPublic(), nil
+}
+
+// GetIPFSPubKeyString returns the base64 encoded public ipfs peer key
+func (w *Wallet) GetIPFSPubKeyString() (string, error) {
+	pk, err := w.GetIPFSPubKey()
+	if err != nil {
+		return "", err
+	}
+	pkb, err := pk.Bytes()
 	if err != nil {
 		log.Errorf("error getting pub key bytes: %s", err)
 		return "", err
@@ -811,11 +1319,13 @@ func (w *Wallet) Subscribe(topic string) (*floodsub.Subscription, error) {
 	return w.ipfs.Floodsub.Subscribe(topic)
 }
 
-// WaitForInvite to join
-// TODO: needs cleanup to handle a generic invite
-func (w *Wallet) WaitForInvite() {
+// WaitForInvite waits at our own peer id for an invite matching the pairing request,
+// rejecting any invites that cannot prove knowledge of the request code.
+// Returns the pending pairing, whose short authentication string must be confirmed
+// by the user with AcceptPairing before the invited thread is joined.
+func (w *Wallet) WaitForInvite(req *PairingRequest) (*Pairing, error) {
 	if !w.Online() {
-		return
+		return nil, ErrOffline
 	}
 	// we're in a lonesome state here, we can just sub to our own
 	// peer id and hope somebody sends us a priv key to join a thread with
@@ -823,59 +1333,153 @@ func (w *Wallet) WaitForInvite() {
 	sub, err := w.ipfs.Floodsub.Subscribe(self)
 	if err != nil {
 		log.Errorf("error creating subscription: %s", err)
-		return
+		return nil, err
 	}
+	defer sub.Cancel()
 	log.Infof("waiting for invite at own peer id: %s\n", self)
 
-	ctx, cancel := context.WithCancel(context.Background())
-	cancelCh := make(chan struct{})
-	go func() {
-		for {
-			msg, err := sub.Next(ctx)
-			if err == io.EOF || err == context.Canceled {
-				log.Debugf("wait subscription ended: %s", err)
-				return
-			} else if err != nil {
-				log.Debugf(err.Error())
-				return
-			}
-			from := msg.GetFrom().Pretty()
-			log.Infof("got pairing request from: %s\n", from)
-
-			// get private peer key and decrypt the phrase
-			skb, err := crypto.Decrypt(w.ipfs.PrivateKey, msg.GetData())
-			if err != nil {
-				log.Errorf("error decrypting msg data: %s", err)
-				return
-			}
-			secret, err := libp2pc.UnmarshalPrivateKey(skb)
-			if err != nil {
-				log.Errorf("error unmarshaling mobile private key: %s", err)
-				return
-			}
+	ctx, cancel := context.WithDeadline(w.ipfs.Context(), req.Expires)
+	defer cancel()
+	for {
+		msg, err := sub.Next(ctx)
+		if err == context.DeadlineExceeded {
+			return nil, ErrPairingExpired
+		} else if err != nil {
+			log.Debugf("wait subscription ended: %s", err)
+			return nil, err
+		}
+		from := msg.GetFrom()
+		log.Infof("got pairing request from: %s\n", from.Pretty())
 
-			// create a new album for the room
-			// TODO: let user name this or take phone's name, e.g., bob's iphone
-			// TODO: or auto name it, cause this means only one pairing can happen
-			_, err = w.AddThread("mobile", secret)
-			if err != nil {
-				log.Errorf("error adding mobile thread: %s", err)
-				return
-			}
+		// decrypt the invite with our private peer key
+		plain, err := crypto.Decrypt(w.ipfs.PrivateKey, msg.GetData())
+		if err != nil {
+			log.Warningf("rejected invite from %s: %s", from.Pretty(), err)
+			continue
+		}
+		invite := new(PairingInvite)
+		if err := json.Unmarshal(plain, invite); err != nil {
+			log.Warningf("rejected invite from %s: %s", from.Pretty(), err)
+			continue
+		}
+		secret, inviter, err := req.Verify(invite, from)
+		if err == ErrPairingExpired {
+			return nil, err
+		} else if err != nil {
+			log.Warningf("rejected invite from %s: %s", from.Pretty(), err)
+			continue
+		}
 
-			// we're done
-			close(cancelCh)
+		// let the inviter know which code we derived so it can be shown on both devices
+		sas, err := PairingSAS(req.Code, req.PubKey, inviter)
+		if err != nil {
+			return nil, err
 		}
-	}()
+		ackb, err := json.Marshal(&PairingAck{SAS: sas})
+		if err != nil {
+			return nil, err
+		}
+		ackcypher, err := crypto.Encrypt(inviter, ackb)
+		if err != nil {
+			return nil, err
+		}
+		if err := w.Publish(from.Pretty(), ackcypher); err != nil {
+			log.Errorf("error publishing pairing ack: %s", err)
+		}
+		return &Pairing{SAS: sas, Inviter: inviter, secret: secret}, nil
+	}
+}
+
+// AcceptPairing joins the thread carried by a pairing after the user has confirmed
+// that its short authentication string matches the one shown on the other device
+func (w *Wallet) AcceptPairing(pairing *Pairing) (*thread.Thread, error) {
+	if pairing.accepted {
+		return nil, ErrPairingExpired
+	}
+	pairing.accepted = true
+
+	// create a new album for the room
+	// TODO: let user name this or take phone's name, e.g., bob's iphone
+	// TODO: or auto name it, cause this means only one pairing can happen
+	thrd, err := w.AddThread("mobile", pairing.secret)
+	if err != nil {
+		log.Errorf("error adding mobile thread: %s", err)
+		return nil, err
+	}
+	return thrd, nil
+}
+
+// PairDevice invites the device listening at the given peer key to join a thread.
+// The code is the pairing request code displayed by the other device.
+// Returns the short authentication string to show the user once the other device has
+// acknowledged the invite, the user then confirms it on the other device.
+func (w *Wallet) PairDevice(pk libp2pc.PubKey, code string, thrd *thread.Thread) (string, error) {
+	if !w.Online() {
+		return "", ErrOffline
+	}
+
+	// get the topic to pair with from the pub key
+	peerID, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		return "", err
+	}
+	topic := peerID.Pretty()
+
+	// listen for the confirmation at our own peer id
+	own := w.ipfs.PrivateKey.GetPublic()
+	sub, err := w.ipfs.Floodsub.Subscribe(w.ipfs.Identity.Pretty())
+	if err != nil {
+		return "", err
+	}
+	defer sub.Cancel()
+
+	// encrypt an authenticated invite with the device's pub key
+	invite, err := NewPairingInvite(code, pk, own, thrd.PrivKey)
+	if err != nil {
+		return "", err
+	}
+	inviteb, err := json.Marshal(invite)
+	if err != nil {
+		return "", err
+	}
+	invitecypher, err := crypto.Encrypt(pk, inviteb)
+	if err != nil {
+		return "", err
+	}
+	if err := w.Publish(topic, invitecypher); err != nil {
+		return "", err
+	}
+	log.Infof("published pairing invite to device: %s", topic)
+
+	// wait for the device to confirm
+	sas, err := PairingSAS(code, pk, own)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(w.ipfs.Context(), pairingAckTimeout)
+	defer cancel()
 	for {
-		select {
-		case <-cancelCh:
-			cancel()
-			return
-		case <-w.ipfs.Context().Done():
-			cancel()
-			return
+		msg, err := sub.Next(ctx)
+		if err == context.DeadlineExceeded {
+			return "", ErrPairingExpired
+		} else if err != nil {
+			return "", err
+		}
+		if msg.GetFrom() != peerID {
+			continue
 		}
+		plain, err := crypto.Decrypt(w.ipfs.PrivateKey, msg.GetData())
+		if err != nil {
+			continue
+		}
+		ack := new(PairingAck)
+		if err := json.Unmarshal(plain, ack); err != nil {
+			continue
+		}
+		if ack.SAS != sas {
+			return "", ErrPairingMismatch
+		}
+		return sas, nil
 	}
 }
 
@@ -956,13 +1560,7 @@ func (w *Wallet) getThreadByBlock(block *trepo.Block) (*thread.Thread, error) {
 	if block == nil {
 		return nil, errors.New("block is empty")
 	}
-	var thrd *thread.Thread
-	for _, t := range w.threads {
-		if t.Id == block.ThreadPubKey {
-			thrd = t
-			break
-		}
-	}
+	thrd := w.GetThread(block.ThreadPubKey)
 	if thrd == nil {
 		return nil, errors.New(fmt.Sprintf("could not find thread: %s", block.ThreadPubKey))
 	}
@@ -970,8 +1568,12 @@ func (w *Wallet) getThreadByBlock(block *trepo.Block) (*thread.Thread, error) {
 }
 
 func (w *Wallet) loadThread(model *trepo.Thread) (*thread.Thread, error) {
-	if w.GetThreadByName(model.Name) != nil {
-		return nil, ErrThreadLoaded
+	w.threadsMux.Lock()
+	defer w.threadsMux.Unlock()
+	for _, t := range w.threads {
+		if t.Name == model.Name {
+			return nil, ErrThreadLoaded
+		}
 	}
 	id := model.Id // save value locally
 	threadConfig := &thread.Config{
@@ -981,6 +1583,16 @@ func (w *Wallet) loadThread(model *trepo.Thread) (*thread.Thread, error) {
 		RepoPath: w.repoPath,
 		Ipfs:     func() *core.IpfsNode { return w.ipfs },
 		Blocks:   func() trepo.BlockStore { return w.datastore.Blocks() },
+		Search:   func() trepo.SearchStore { return w.datastore.Search() },
+		Locations: func() trepo.PhotoLocationStore {
+			return w.datastore.PhotoLocations()
+		},
+		Timeline: func() trepo.TimelineStore {
+			return w.datastore.Timeline()
+		},
+		Versions: func() trepo.PhotoVersionStore {
+			return w.datastore.PhotoVersions()
+		},
 		GetHead: func() (string, error) {
 			m := w.datastore.Threads().Get(id)
 			if m == nil {
@@ -1011,9 +1623,12 @@ func (w *Wallet) loadThread(model *trepo.Thread) (*thread.Thread, error) {
 
 // touchDB ensures that we have a good db connection
 func (w *Wallet) touchDatastore() error {
+	if w.locked {
+		return ErrLocked
+	}
 	if err := w.datastore.Ping(); err != nil {
 		log.Debug("re-opening datastore...")
-		sqliteDB, err := db.Create(w.repoPath, "")
+		sqliteDB, err := db.Create(w.repoPath, w.password)
 		if err != nil {
 			log.Errorf("error re-opening datastore: %s", err)
 			return err
@@ -1022,3 +1637,18 @@ func (w *Wallet) touchDatastore() error {
 	}
 	return nil
 }
+
+// checkRenditions ensures rendition names are unique and don't collide with photo files
+func checkRenditions(renditions []model.Rendition) error {
+	names := make(map[string]bool)
+	for _, name := range model.PhotoFiles {
+		names[name] = true
+	}
+	for _, r := range renditions {
+		if names[r.Name] || r.Name == "" || r.Width <= 0 {
+			return ErrBadRendition
+		}
+		names[r.Name] = true
+	}
+	return nil
+}
@@ -1,16 +1,31 @@
 package wallet_test
 
 import (
+	"encoding/json"
+	"errors"
 	"github.com/segmentio/ksuid"
 	cmodels "github.com/textileio/textile-go/central/models"
+	trepo "github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/repo/db"
 	util "github.com/textileio/textile-go/util/testing"
 	. "github.com/textileio/textile-go/wallet"
+	"github.com/textileio/textile-go/wallet/model"
+	wutil "github.com/textileio/textile-go/wallet/util"
 	libp2pc "gx/ipfs/QmaPbCnUMBohSGo3KnxEa2bHqyJVVeEEcwtqJAYxerieBo/go-libp2p-crypto"
+	"io/ioutil"
+	"math"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 var repo = "testdata/.ipfs"
+var pairRepo = "testdata/.ipfs_pair"
+var restoreRepo = "testdata/.ipfs_restore"
+var encryptRepo = "testdata/.ipfs_encrypt"
+var mnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
 
 var wallet *Wallet
 var addedId string
@@ -28,8 +43,9 @@ var centralReg = &cmodels.Registration{
 func TestNewWallet(t *testing.T) {
 	os.RemoveAll(repo)
 	config := Config{
-		RepoPath:   repo,
-		CentralAPI: util.CentralApiURL,
+		RepoPath:       repo,
+		CentralAPI:     util.CentralApiURL,
+		MasterMnemonic: &mnemonic,
 	}
 	var err error
 	wallet, err = NewWallet(config)
@@ -62,6 +78,26 @@ func TestWallet_GetGatewayAddress(t *testing.T) {
 	// TODO
 }
 
+func TestWallet_GetApiAddress(t *testing.T) {
+	data, err := ioutil.ReadFile(filepath.Join(wallet.GetRepoPath(), "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf struct {
+		Addresses struct{ API string }
+		Textile   struct{ API string }
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if wallet.GetApiAddress() == "" || conf.Textile.API != wallet.GetApiAddress() {
+		t.Errorf("api address not saved in the config: %s", conf.Textile.API)
+	}
+	if conf.Addresses.API == wallet.GetApiAddress() {
+		t.Error("api address should not replace the ipfs api address")
+	}
+}
+
 func TestWallet_GetRepoPath(t *testing.T) {
 	// TODO
 }
@@ -172,6 +208,178 @@ func TestWallet_AddThreadWithMnemonic(t *testing.T) {
 	// TODO
 }
 
+func TestWallet_AddDerivedThread(t *testing.T) {
+	thrd, index, err := wallet.AddDerivedThread("derived")
+	if err != nil {
+		t.Errorf("add derived thread failed: %s", err)
+		return
+	}
+	if index != 0 {
+		t.Errorf("add derived thread got bad index: %d", index)
+	}
+	thrd2, index2, err := wallet.AddDerivedThread("derived2")
+	if err != nil {
+		t.Errorf("add second derived thread failed: %s", err)
+		return
+	}
+	if index2 != 1 {
+		t.Errorf("add second derived thread got bad index: %d", index2)
+	}
+	if thrd.Id == thrd2.Id {
+		t.Error("derived threads should have different ids")
+	}
+	if _, _, err := wallet.AddDerivedThread("derived"); err != ErrThreadExists {
+		t.Errorf("add existing derived thread returned wrong error: %v", err)
+	}
+}
+
+func TestWallet_RestoreThread(t *testing.T) {
+	seed, _, err := wutil.SeedFromMnemonic(&mnemonic, "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	sk, err := wutil.ThreadKeyFromSeed(seed, 1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	thrd := wallet.GetThreadByName("derived2")
+	if thrd == nil || !thrd.PrivKey.Equals(sk) {
+		t.Error("derived thread key should be reproducible from the mnemonic")
+	}
+	restored, err := wallet.RestoreThread("restored", 5)
+	if err != nil {
+		t.Errorf("restore thread failed: %s", err)
+		return
+	}
+	_, index, err := wallet.AddDerivedThread("derived3")
+	if err != nil {
+		t.Errorf("add derived thread after restore failed: %s", err)
+		return
+	}
+	if index != 6 {
+		t.Errorf("derived thread after restore got bad index: %d", index)
+	}
+	if restored.Id == "" {
+		t.Error("restored thread got bad id")
+	}
+}
+
+func TestWallet_AddNewThreadWithoutSeed(t *testing.T) {
+	// repos created before the master seed was stored don't have one
+	ds, err := db.Create(repo, "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer ds.Close()
+	seed, err := ds.Profile().GetSeed()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err := ds.Profile().SetSeed(nil); err != nil {
+		t.Error(err)
+		return
+	}
+	defer ds.Profile().SetSeed(seed)
+
+	if _, _, err := wallet.AddDerivedThread("seedless"); err != ErrNoSeed {
+		t.Errorf("add derived thread without a seed returned wrong error: %v", err)
+	}
+	thrd, index, mnem, err := wallet.AddNewThread("seedless")
+	if err != nil {
+		t.Errorf("add new thread without a seed failed: %s", err)
+		return
+	}
+	if thrd == nil || index != -1 || mnem == "" {
+		t.Error("add new thread without a seed should fall back to a mnemonic")
+	}
+}
+
+func TestWallet_RestoreFromMnemonic(t *testing.T) {
+	if wallet.Mnemonic() != "" {
+		t.Error("a given mnemonic should not be returned")
+	}
+	id, err := wallet.GetId()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, pass := range []string{"", "TREZOR"} {
+		os.RemoveAll(restoreRepo)
+		restored, err := NewWallet(Config{
+			RepoPath:         restoreRepo,
+			CentralAPI:       util.CentralApiURL,
+			MasterMnemonic:   &mnemonic,
+			MasterPassphrase: pass,
+		})
+		if err != nil {
+			t.Errorf("restore wallet failed: %s", err)
+			return
+		}
+		rid, err := restored.GetId()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if pass == "" && rid != id {
+			t.Error("restored wallet got a different id")
+		}
+		if pass != "" && rid == id {
+			t.Error("restore with a passphrase should yield a different id")
+		}
+	}
+	os.RemoveAll(restoreRepo)
+
+	bad := "abandon abandon abandon"
+	if _, err := NewWallet(Config{RepoPath: restoreRepo, MasterMnemonic: &bad}); err == nil {
+		t.Error("restore with a bad mnemonic should fail")
+	}
+	os.RemoveAll(restoreRepo)
+}
+
+func TestWallet_DatastorePassword(t *testing.T) {
+	os.RemoveAll(encryptRepo)
+	defer os.RemoveAll(encryptRepo)
+	config := Config{RepoPath: encryptRepo, CentralAPI: util.CentralApiURL}
+	enc, err := NewWallet(config)
+	if err != nil {
+		t.Errorf("create wallet failed: %s", err)
+		return
+	}
+	if err := enc.EncryptDatastore("password"); err != nil {
+		t.Errorf("encrypt datastore failed: %s", err)
+		return
+	}
+	if err := enc.ChangeDatastorePassword("wrong", "changed"); err != ErrBadPassword {
+		t.Errorf("change password with a wrong password returned wrong error: %v", err)
+	}
+	if err := enc.ChangeDatastorePassword("password", "changed"); err != nil {
+		t.Errorf("change password failed: %s", err)
+		return
+	}
+	if err := enc.Lock(); err != nil {
+		t.Errorf("lock datastore failed: %s", err)
+		return
+	}
+
+	config.DatastorePassword = "password"
+	if _, err := NewWallet(config); err != ErrBadPassword {
+		t.Errorf("open with a wrong password returned wrong error: %v", err)
+	}
+	config.DatastorePassword = "changed"
+	opened, err := NewWallet(config)
+	if err != nil {
+		t.Errorf("open with password failed: %s", err)
+		return
+	}
+	if opened.Locked() {
+		t.Error("datastore should be unlocked")
+	}
+}
+
 func TestWallet_PublishThreads(t *testing.T) {
 	// TODO
 }
@@ -192,6 +400,20 @@ func TestWallet_AddPhoto(t *testing.T) {
 	}
 }
 
+func TestWallet_AddPhotoDuplicate(t *testing.T) {
+	added, err := wallet.AddPhoto("testdata/image.jpg")
+	if err != nil {
+		t.Errorf("add duplicate photo failed: %s", err)
+		return
+	}
+	if !added.Duplicate || added.Id != addedId {
+		t.Errorf("add duplicate photo got new id: %s", added.Id)
+	}
+	if added.RemoteRequest != nil {
+		t.Error("add duplicate photo should not create a remote request")
+	}
+}
+
 func TestWallet_GetBlock(t *testing.T) {
 	// TODO
 }
@@ -240,6 +462,535 @@ func TestWallet_WaitForInvite(t *testing.T) {
 	// TODO
 }
 
+func TestWallet_PairDevice(t *testing.T) {
+	// a second device waiting to be paired
+	os.RemoveAll(pairRepo)
+	defer os.RemoveAll(pairRepo)
+	device, err := NewWallet(Config{RepoPath: pairRepo, CentralAPI: util.CentralApiURL})
+	if err != nil {
+		t.Errorf("create device wallet failed: %s", err)
+		return
+	}
+	online, err := device.Start()
+	if err != nil {
+		t.Errorf("start device wallet failed: %s", err)
+		return
+	}
+	<-online
+	defer device.Stop()
+
+	// connect the devices directly
+	addr, err := localSwarmAddress(device)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := wallet.ConnectPeer([]string{addr}); err != nil {
+		t.Errorf("connect device failed: %s", err)
+		return
+	}
+
+	// the device shows its code and key, e.g., in a qr code
+	pk, err := device.GetIPFSPubKey()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	req, err := NewPairingRequest(pk, DefaultPairingTTL)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	pairings := make(chan *Pairing, 1)
+	errs := make(chan error, 1)
+	go func() {
+		pairing, err := device.WaitForInvite(req)
+		if err != nil {
+			errs <- err
+			return
+		}
+		pairings <- pairing
+	}()
+	time.Sleep(time.Second * 2) // let the subscription propagate
+
+	// invite the device to the test thread
+	thrd := wallet.GetThreadByName("test")
+	if thrd == nil {
+		t.Error("could not find test thread")
+		return
+	}
+	sas, err := wallet.PairDevice(pk, req.Code, thrd)
+	if err != nil {
+		t.Errorf("pair device failed: %s", err)
+		return
+	}
+	var pairing *Pairing
+	select {
+	case pairing = <-pairings:
+	case err := <-errs:
+		t.Errorf("wait for invite failed: %s", err)
+		return
+	}
+	if pairing.SAS != sas {
+		t.Errorf("devices derived different codes: %s, %s", pairing.SAS, sas)
+	}
+
+	// nothing is joined until the user confirms the codes match
+	if device.GetThreadByName("mobile") != nil {
+		t.Error("device joined thread before the code was confirmed")
+	}
+	joined, err := device.AcceptPairing(pairing)
+	if err != nil {
+		t.Errorf("accept pairing failed: %s", err)
+		return
+	}
+	if joined.Id != thrd.Id {
+		t.Error("device joined the wrong thread")
+	}
+	if _, err := device.AcceptPairing(pairing); err != ErrPairingExpired {
+		t.Errorf("accept pairing again returned wrong error: %v", err)
+	}
+}
+
+func TestWallet_ImportDirectory(t *testing.T) {
+	thrd := wallet.GetThreadByName("test")
+	if thrd == nil {
+		t.Error("could not find test thread")
+		return
+	}
+	res, err := wallet.ImportDirectory("testdata", thrd, ImportOptions{Caption: "imported"})
+	if err != nil {
+		t.Errorf("import directory failed: %s", err)
+		return
+	}
+	if res.Total != 8 || res.Added != 8 || len(res.Failed) != 0 {
+		t.Errorf("import directory got bad result: %+v", res)
+	}
+
+	// importing again should only find duplicates
+	progress := make(chan model.ImportUpdate, 16)
+	res, err = wallet.ImportDirectory("testdata", thrd, ImportOptions{Progress: progress})
+	if err != nil {
+		t.Errorf("import directory again failed: %s", err)
+		return
+	}
+	if res.Added != 0 || res.Duplicates != 8 {
+		t.Errorf("import directory again got bad result: %+v", res)
+	}
+	if len(progress) != 16 {
+		t.Errorf("import directory sent %d progress updates", len(progress))
+	}
+}
+
+func TestWallet_SharePhotoLocation(t *testing.T) {
+	thrd := wallet.GetThreadByName("derived")
+	if thrd == nil {
+		t.Error("could not find derived thread")
+		return
+	}
+	if err := wallet.SetThreadLocationPolicy(thrd.Id, trepo.LocationCoarsen, 1); err != nil {
+		t.Errorf("set thread location policy failed: %s", err)
+		return
+	}
+	shared, err := wallet.SharePhoto(addedId, thrd, "coarse")
+	if err != nil {
+		t.Errorf("share photo failed: %s", err)
+		return
+	}
+	block, err := wallet.GetBlock(shared.Id)
+	if err != nil {
+		t.Errorf("get shared block failed: %s", err)
+		return
+	}
+	if block.Target == addedId {
+		t.Error("shared photo should have a location variant target")
+		return
+	}
+	metab, err := thrd.GetFileData(block.Target+"/meta", block)
+	if err != nil {
+		t.Errorf("get shared meta failed: %s", err)
+		return
+	}
+	var meta model.PhotoMetadata
+	if err := json.Unmarshal(metab, &meta); err != nil {
+		t.Error(err)
+		return
+	}
+	if meta.Latitude-math.Floor(meta.Latitude) != 0.5 || meta.Longitude-math.Floor(meta.Longitude) != 0.5 {
+		t.Errorf("shared photo location was not coarsened: %f, %f", meta.Latitude, meta.Longitude)
+	}
+	if meta.Width == 0 || meta.Make == "" {
+		t.Error("shared photo lost other meta data")
+	}
+	if _, err := thrd.GetFileData(block.Target+"/thumb", block); err != nil {
+		t.Errorf("shared photo lost its thumb: %s", err)
+	}
+
+	// sharing again reuses the variant
+	again, err := wallet.SharePhoto(addedId, thrd, "")
+	if err != nil {
+		t.Errorf("share photo again failed: %s", err)
+		return
+	}
+	if !again.Duplicate || again.Id != shared.Id {
+		t.Error("share photo again should be a duplicate")
+	}
+}
+
+func TestWallet_Search(t *testing.T) {
+	thrd := wallet.GetThreadByName("test")
+	if thrd == nil {
+		t.Error("could not find test thread")
+		return
+	}
+	entries, err := wallet.Search(&trepo.SearchQuery{Text: "import", ThreadId: thrd.Id})
+	if err != nil {
+		t.Errorf("search failed: %s", err)
+		return
+	}
+	if len(entries) != 8 {
+		t.Errorf("search found %d imported photos", len(entries))
+	}
+	entries, err = wallet.Search(&trepo.SearchQuery{Text: "coarse apple"})
+	if err != nil {
+		t.Errorf("search failed: %s", err)
+		return
+	}
+	if len(entries) != 1 || entries[0].Caption != "coarse" {
+		t.Error("search did not find shared photo by caption and camera")
+	}
+	count, err := wallet.RebuildSearchIndex()
+	if err != nil {
+		t.Errorf("rebuild search index failed: %s", err)
+		return
+	}
+	if count != 9 {
+		t.Errorf("rebuild search index indexed %d photos", count)
+	}
+}
+
+func TestWallet_PhotosInBounds(t *testing.T) {
+	locations, err := wallet.PhotosInBounds(-90, -180, 90, 180, nil)
+	if err != nil {
+		t.Errorf("photos in bounds failed: %s", err)
+		return
+	}
+	if len(locations) != 2 {
+		t.Errorf("photos in bounds found %d photos", len(locations))
+	}
+	derived := wallet.GetThreadByName("derived")
+	locations, err = wallet.PhotosInBounds(-90, -180, 90, 180, []string{derived.Id})
+	if err != nil {
+		t.Errorf("photos in bounds failed: %s", err)
+		return
+	}
+	if len(locations) != 1 || locations[0].Latitude-math.Floor(locations[0].Latitude) != 0.5 {
+		t.Error("photos in bounds should find the coarsened location in the derived thread")
+	}
+	if _, err := wallet.PhotosInBounds(10, 0, -10, 0, nil); err != ErrBadBounds {
+		t.Errorf("photos in bad bounds returned wrong error: %v", err)
+	}
+}
+
+func TestWallet_PhotoClusters(t *testing.T) {
+	clusters, err := wallet.PhotoClusters(0, -90, -180, 90, 180, nil)
+	if err != nil {
+		t.Errorf("photo clusters failed: %s", err)
+		return
+	}
+	if len(clusters) != 1 || clusters[0].Count != 2 || clusters[0].BlockId == "" {
+		t.Errorf("photo clusters got bad result: %+v", clusters)
+	}
+	if _, err := wallet.PhotoClusters(23, -90, -180, 90, 180, nil); err != ErrBadZoom {
+		t.Errorf("photo clusters at bad zoom returned wrong error: %v", err)
+	}
+}
+
+func TestWallet_Timeline(t *testing.T) {
+	var count int
+	var cursor string
+	for {
+		page, err := wallet.Timeline(trepo.TimelineDay, cursor, 1, 2, nil)
+		if err != nil {
+			t.Errorf("timeline failed: %s", err)
+			return
+		}
+		for _, group := range page.Groups {
+			if len(group.Photos) == 0 || len(group.Photos) > 2 || len(group.Photos) > group.Count {
+				t.Errorf("timeline group %s has %d photos", group.Key, len(group.Photos))
+			}
+			count += group.Count
+		}
+		if page.Next == "" {
+			break
+		}
+		cursor = page.Next
+	}
+	if count != 9 {
+		t.Errorf("timeline counted %d photos", count)
+	}
+	if _, err := wallet.Timeline(trepo.TimelineYear, "2018-05", 0, 0, nil); err != ErrBadTimelineCursor {
+		t.Errorf("timeline with bad cursor returned wrong error: %v", err)
+	}
+}
+
+func TestWallet_SimilarPhotos(t *testing.T) {
+	similar, err := wallet.SimilarPhotos(addedId, DefaultSimilarThreshold)
+	if err != nil {
+		t.Errorf("similar photos failed: %s", err)
+		return
+	}
+	var variant bool
+	for _, photo := range similar {
+		if photo.Id == addedId {
+			t.Error("similar photos should not include the photo itself")
+		}
+		if photo.Distance == 0 {
+			variant = true
+		}
+	}
+	if !variant {
+		t.Error("similar photos should find the location variant of the photo")
+	}
+	if _, err := wallet.SimilarPhotos(addedId, 65); err != ErrBadSimilarThreshold {
+		t.Errorf("similar photos with bad threshold returned wrong error: %v", err)
+	}
+}
+
+func TestWallet_SimilarPhotoClusters(t *testing.T) {
+	thrd := wallet.GetThreadByName("test")
+	if thrd == nil {
+		t.Error("could not find test thread")
+		return
+	}
+	clusters, err := wallet.SimilarPhotoClusters(thrd.Id, 0)
+	if err != nil {
+		t.Errorf("similar photo clusters failed: %s", err)
+		return
+	}
+	for _, cluster := range clusters {
+		if len(cluster.Photos) < 2 {
+			t.Error("similar photo clusters should have more than one photo")
+		}
+	}
+}
+
+func TestWallet_EditPhoto(t *testing.T) {
+	thrd := wallet.GetThreadByName("test")
+	if thrd == nil {
+		t.Error("could not find test thread")
+		return
+	}
+	edited, err := wallet.EditPhoto(addedId, thrd, []model.Edit{{Op: "rotate", Angle: 90}})
+	if err != nil {
+		t.Errorf("edit photo failed: %s", err)
+		return
+	}
+	version, err := wallet.GetBlock(edited.Id)
+	if err != nil {
+		t.Errorf("get version block failed: %s", err)
+		return
+	}
+	if version.Type != trepo.VersionBlock || version.Target == addedId {
+		t.Error("edit photo should add a version block with a new target")
+	}
+	block, err := wallet.GetBlockByTarget(addedId)
+	if err != nil {
+		t.Errorf("get original block failed: %s", err)
+		return
+	}
+	original, err := thrd.GetPhotoMetaData(addedId, block)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	meta, err := thrd.GetPhotoMetaData(version.Target, version)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if meta.Width != original.Height || meta.Height != original.Width || meta.Make != original.Make {
+		t.Error("version meta data should be rotated but otherwise kept")
+	}
+
+	// editing the version builds on its edits
+	again, err := wallet.EditPhoto(version.Target, thrd, []model.Edit{{Op: "flip", Axis: "h"}})
+	if err != nil {
+		t.Errorf("edit version failed: %s", err)
+		return
+	}
+	edits, err := wallet.PhotoEdits(addedId, thrd)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(edits) != 2 || edits[0].Op != "rotate" || edits[1].Op != "flip" {
+		t.Errorf("latest version has wrong edits: %+v", edits)
+	}
+	if len(thrd.Versions(addedId)) != 2 {
+		t.Error("photo should have two versions")
+	}
+
+	// listings show the latest version
+	latest, err := wallet.GetBlock(again.Id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var found bool
+	for _, block := range thrd.Blocks("", -1) {
+		if block.Target == latest.Target {
+			found = true
+		}
+		if block.Target == addedId || block.Target == version.Target {
+			t.Error("thread blocks should not list replaced versions")
+		}
+	}
+	if !found {
+		t.Error("thread blocks should list the latest version")
+	}
+
+	if _, err := wallet.EditPhoto(addedId, thrd, nil); err != ErrNoEdits {
+		t.Errorf("edit photo without edits returned wrong error: %v", err)
+	}
+
+	// versions follow the thread's location policy
+	if err := wallet.SetThreadLocationPolicy(thrd.Id, trepo.LocationStrip, 0); err != nil {
+		t.Errorf("set thread location policy failed: %s", err)
+		return
+	}
+	defer wallet.ResetThreadLocationPolicy(thrd.Id)
+	stripped, err := wallet.EditPhoto(addedId, thrd, []model.Edit{{Op: "rotate", Angle: 180}})
+	if err != nil {
+		t.Errorf("edit photo with strip policy failed: %s", err)
+		return
+	}
+	version, err = wallet.GetBlock(stripped.Id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	meta, err = thrd.GetPhotoMetaData(version.Target, version)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if meta.Latitude != 0 || meta.Longitude != 0 {
+		t.Errorf("version location was not stripped: %f, %f", meta.Latitude, meta.Longitude)
+	}
+}
+
+func TestWallet_AddPhotoToThreadLocation(t *testing.T) {
+	thrd := wallet.GetThreadByName("derived3")
+	if thrd == nil {
+		t.Error("could not find thread derived3")
+		return
+	}
+	if err := wallet.SetThreadLocationPolicy(thrd.Id, trepo.LocationStrip, 0); err != nil {
+		t.Errorf("set thread location policy failed: %s", err)
+		return
+	}
+	added, err := wallet.AddPhoto("testdata/image.jpg")
+	if err != nil {
+		t.Errorf("add photo failed: %s", err)
+		return
+	}
+	shared, err := wallet.AddPhotoToThread(added.Id, added.Key, thrd, "stripped")
+	if err != nil {
+		t.Errorf("add photo to thread failed: %s", err)
+		return
+	}
+	block, err := wallet.GetBlock(shared.Id)
+	if err != nil {
+		t.Errorf("get added block failed: %s", err)
+		return
+	}
+	if block.Target == addedId {
+		t.Error("added photo should have a location variant target")
+		return
+	}
+	meta, err := thrd.GetPhotoMetaData(block.Target, block)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if meta.Latitude != 0 || meta.Longitude != 0 {
+		t.Errorf("added photo location was not stripped: %f, %f", meta.Latitude, meta.Longitude)
+	}
+}
+
+func TestWallet_RemoveThread(t *testing.T) {
+	thrd := wallet.GetThreadByName("derived3")
+	if thrd == nil {
+		t.Error("could not find thread derived3")
+		return
+	}
+	shared, err := wallet.SharePhoto(addedId, thrd, "gone soon")
+	if err != nil {
+		t.Errorf("share photo failed: %s", err)
+		return
+	}
+	if err := wallet.RemoveThread(thrd.Id); err != nil {
+		t.Errorf("remove thread failed: %s", err)
+		return
+	}
+	if wallet.GetThread(thrd.Id) != nil || wallet.GetThreadByName("derived3") != nil {
+		t.Error("removed thread should not be loaded")
+	}
+	if _, err := wallet.GetBlock(shared.Id); err == nil {
+		t.Error("removed thread's blocks should be deleted")
+	}
+	if err := wallet.RemoveThread(thrd.Id); err != ErrThreadNotFound {
+		t.Errorf("remove thread again returned wrong error: %v", err)
+	}
+}
+
+func TestWallet_ThreadsConcurrently(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			for _, thrd := range wallet.Threads() {
+				wallet.GetThread(thrd.Id)
+			}
+			wallet.GetThreadByName("concurrent")
+		}
+	}()
+	thrd, _, err := wallet.AddThreadWithMnemonic("concurrent", nil, "")
+	if err != nil {
+		t.Errorf("add thread failed: %s", err)
+	} else if err := wallet.RemoveThread(thrd.Id); err != nil {
+		t.Errorf("remove thread failed: %s", err)
+	}
+	<-done
+}
+
+func TestWallet_GC(t *testing.T) {
+	res, err := wallet.GC()
+	if err != nil {
+		t.Errorf("gc failed: %s", err)
+		return
+	}
+	if res == nil {
+		t.Error("gc returned no result")
+		return
+	}
+	// the recently added photo must survive
+	if _, err := wallet.GetDataAtPath(addedId + "/thumb"); err != nil {
+		t.Errorf("gc removed a recently added photo: %s", err)
+	}
+
+	// so must the unpinned original of a live target, which spans many chunks
+	photo, err := wallet.GetDataAtPath(addedId + "/photo")
+	if err != nil {
+		t.Errorf("gc removed the original of a live target: %s", err)
+		return
+	}
+	if len(photo) <= 256*1024 {
+		t.Errorf("expected a multi-chunk original, got %d bytes", len(photo))
+	}
+}
+
 func TestWallet_SignOut(t *testing.T) {
 	err := wallet.SignOut()
 	if err != nil {
@@ -283,3 +1034,29 @@ func TestWallet_SignInAgain(t *testing.T) {
 func Test_Teardown(t *testing.T) {
 	os.RemoveAll(wallet.GetRepoPath())
 }
+
+// localSwarmAddress returns a loopback address for a started wallet's ipfs node
+func localSwarmAddress(w *Wallet) (string, error) {
+	id, err := w.GetIPFSPeerId()
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(filepath.Join(w.GetRepoPath(), "config"))
+	if err != nil {
+		return "", err
+	}
+	var conf struct {
+		Addresses struct {
+			Swarm []string
+		}
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return "", err
+	}
+	for _, addr := range conf.Addresses.Swarm {
+		if strings.HasPrefix(addr, "/ip4/0.0.0.0/tcp/") {
+			return strings.Replace(addr, "0.0.0.0", "127.0.0.1", 1) + "/ipfs/" + id, nil
+		}
+	}
+	return "", errors.New("no ip4 swarm address")
+}
@@ -0,0 +1,62 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	trepo "github.com/textileio/textile-go/repo"
+	"github.com/textileio/textile-go/wallet/thread"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var ErrWatchExists = errors.New("folder is already watched")
+
+// AddWatch maps a folder to a thread, returning the cleaned absolute path
+func (w *Wallet) AddWatch(path string, thrd *thread.Thread) (string, error) {
+	if err := w.touchDatastore(); err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", errors.New(fmt.Sprintf("not a directory: %s", abs))
+	}
+	if w.datastore.Watches().Get(abs) != nil {
+		return "", ErrWatchExists
+	}
+	watch := &trepo.Watch{Path: abs, ThreadId: thrd.Id, Date: time.Now()}
+	if err := w.datastore.Watches().Add(watch); err != nil {
+		return "", err
+	}
+	return abs, nil
+}
+
+// Watches lists watched folders
+func (w *Wallet) Watches() ([]trepo.Watch, error) {
+	if err := w.touchDatastore(); err != nil {
+		return nil, err
+	}
+	return w.datastore.Watches().List(), nil
+}
+
+// RemoveWatch stops mapping a folder to a thread, returning the cleaned absolute path
+func (w *Wallet) RemoveWatch(path string) (string, error) {
+	if err := w.touchDatastore(); err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if w.datastore.Watches().Get(abs) == nil {
+		return "", errors.New(fmt.Sprintf("folder is not watched: %s", abs))
+	}
+	return abs, w.datastore.Watches().Delete(abs)
+}